@@ -3,12 +3,16 @@ module github.com/kube-object-storage/lib-bucket-provisioner
 go 1.13
 
 require (
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/go-logr/logr v1.2.3
 	github.com/google/go-cmp v0.5.5
 	github.com/google/uuid v1.1.2
+	github.com/prometheus/client_golang v1.10.0
+	github.com/prometheus/procfs v0.7.3 // indirect
 	k8s.io/api v0.23.5
 	k8s.io/apimachinery v0.23.5
 	k8s.io/client-go v0.23.5
 	k8s.io/code-generator v0.20.1
 	k8s.io/klog/v2 v2.60.1
+	k8s.io/utils v0.0.0-20211116205334-6203023598ed
 )
@@ -0,0 +1,72 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const BucketClassKind = "BucketClass"
+
+func BucketClassGVK() schema.GroupVersionKind {
+	return GroupKindVersion(BucketClassKind)
+}
+
+// BucketQuota bounds the content of a bucket. Enforcing the quota is up to the provisioner.
+type BucketQuota struct {
+	// MaxObjects is the maximum number of objects in the bucket
+	// +optional
+	MaxObjects *int64 `json:"maxObjects,omitempty"`
+	// MaxSize is the maximum total size of the objects in the bucket
+	// +optional
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +k8s:openapi-gen=true
+// +kubebuilder:resource:scope=Cluster
+
+// BucketClass holds bucket specific settings which are layered over the StorageClass of the
+// claims referencing it. No typed client, lister or informer is generated for it: the controller
+// reads BucketClasses through the REST client when it syncs a claim, and does not watch them.
+type BucketClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Parameters are merged into the parameters of the claim's StorageClass, taking precedence
+	// over them. The StorageClass parameter "bucketName" cannot be set by a BucketClass.
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// ReclaimPolicy, when set, overrides the reclaimPolicy of the claim's StorageClass
+	// +optional
+	ReclaimPolicy *corev1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+	// Quota is passed to the provisioner when provisioning buckets of this class
+	// +optional
+	Quota *BucketQuota `json:"quota,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// BucketClassList contains a list of BucketClass
+type BucketClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BucketClass `json:"items"`
+}
@@ -41,6 +41,88 @@ const (
 	AwsKeyField        = "AWS_ACCESS_KEY_ID"
 	AwsSecretField     = "AWS_SECRET_ACCESS_KEY"
 	StorageClassBucket = "bucketName"
+	// StorageClassProvisionTimeout is the key of an optional storage class parameter which bounds
+	// how long the controller waits for the provisioner to provision or grant access to a bucket.
+	// The value must be a duration string, eg. "5m".
+	StorageClassProvisionTimeout = "provisionTimeout"
+	// StorageClassTags is the key of an optional storage class parameter listing fixed tags passed
+	// to the provisioner for the bucket, as comma-separated key=value pairs, eg. "team=storage,env=prod".
+	StorageClassTags = "tags"
+	// StorageClassTagLabels is the key of an optional storage class parameter listing the keys of
+	// the claim's labels or annotations passed to the provisioner as bucket tags, comma-separated.
+	StorageClassTagLabels = "tagLabels"
+	// StorageClassRequireTLSEndpoint is the key of an optional storage class parameter which,
+	// when "true", fails claims whose bucket endpoint does not use TLS, or when "false", allows
+	// them. It overrides the controller's default for claims of the class.
+	StorageClassRequireTLSEndpoint = "requireTLSEndpoint"
+	// StorageClassMaxConcurrentProvisions is the key of an optional storage class parameter which
+	// limits how many calls to the provisioner's Provision or Grant methods for claims of the
+	// class run at once, eg. "1" to serialize them. The value must be a positive integer.
+	StorageClassMaxConcurrentProvisions = "maxConcurrentProvisions"
+	// StorageClassMaxClaims is the key of an optional storage class parameter which limits how
+	// many claims of the class may be bound or pending at once, eg. for a backend shared with
+	// limited capacity. Claims over the limit wait in Pending until others are deleted. The value
+	// must be a positive integer.
+	StorageClassMaxClaims = "maxClaims"
+	// StorageClassKeepConfigMapOnRevoke is the key of an optional storage class parameter which,
+	// when "true", keeps the configmap of a deleted claim whose access to the bucket was revoked
+	// rather than the bucket deleted, since the bucket's endpoint remains valid. The secret is
+	// still deleted.
+	StorageClassKeepConfigMapOnRevoke = "keepConfigMapOnRevoke"
+	// StorageClassDetectRegion is the key of an optional storage class parameter which, when
+	// "true", sets the BUCKET_REGION of a claim's configmap from the endpoint's host, eg.
+	// "s3.eu-west-1.amazonaws.com", if the provisioner does not set the region.
+	StorageClassDetectRegion = "detectRegion"
+	// StorageClassAllowBrownfieldUpdates is the key of an optional storage class parameter which,
+	// when "true", passes additionalConfig changes of bound claims of an existing bucket to the
+	// provisioner's Update method. Otherwise such changes are rejected, as the bucket may be
+	// shared with other claims.
+	StorageClassAllowBrownfieldUpdates = "allowBrownfieldUpdates"
+	// StorageClassBucketNameCollision is the key of an optional storage class parameter deciding
+	// what happens when the provisioner reports that the bucket name of a new claim is taken, eg.
+	// by the bucket of another claim: BucketNameCollisionFail fails the claim, and
+	// BucketNameCollisionUniquify retries it with a unique suffix appended to the name. Claims of
+	// classes which do not set it are retried with the same name.
+	StorageClassBucketNameCollision = "bucketNameCollision"
+	// StorageClassAllowBucketAdoption is the key of an optional storage class parameter which, when
+	// "true", allows claims of the class to adopt an existing bucket with the AdoptBucketAnnotation.
+	StorageClassAllowBucketAdoption = "allowBucketAdoption"
+	// BucketNameCollisionFail is the StorageClassBucketNameCollision value failing claims whose
+	// bucket name is taken.
+	BucketNameCollisionFail = "Fail"
+	// BucketNameCollisionUniquify is the StorageClassBucketNameCollision value retrying claims
+	// whose bucket name is taken with a unique suffix appended to the name.
+	BucketNameCollisionUniquify = "Uniquify"
+	// AdditionalConfigVersioning is the key of an optional claim additionalConfig entry which,
+	// when "true", asks the provisioner to enable object versioning on the bucket. The value
+	// must be a boolean.
+	AdditionalConfigVersioning = "versioning"
+	// AdditionalConfigLifecycle is the key of an optional claim additionalConfig entry holding the
+	// lifecycle rules of the bucket, eg. to expire objects, as a JSON list of rules. It is only
+	// supported by provisioners which apply lifecycle rules.
+	AdditionalConfigLifecycle = "lifecycle"
+	// AdditionalConfigAccess is the key of an optional claim additionalConfig entry requesting the
+	// access granted by the claim's credentials, "read" or "readwrite". Claims which do not set it
+	// are granted read-write access.
+	AdditionalConfigAccess = "access"
+	// DefaultStorageClassAnnotation marks the storage class used by claims which do not name one, taking precedence
+	// over a storage class marked as the cluster's default with the standard
+	// "storageclass.kubernetes.io/is-default-class" annotation.
+	DefaultStorageClassAnnotation = "objectbucket.io/is-default-class"
+	// SkipLabelPropagationAnnotation, when "true" on a claim, restricts the labels which the
+	// controller adds to the claim and its generated resources to the one identifying the
+	// provisioner, eg. when other labels would be matched by selectors in the claim's namespace.
+	SkipLabelPropagationAnnotation = "objectbucket.io/skip-label-propagation"
+	// ReconcileNowAnnotation requests that a claim be reconciled immediately, without the backoff
+	// of its previous failures, when it is set to a new value, eg. the current time. Values which
+	// do not change, including the value found when the controller starts, are ignored.
+	ReconcileNowAnnotation = "objectbucket.io/reconcile-now"
+	// AdoptBucketAnnotation names an existing bucket which a claim adopts, eg. a bucket created
+	// outside of the controller which is migrated under its management. The claim is granted
+	// access to the bucket, as for a storage class naming a bucket, so the bucket is not deleted
+	// with the claim. It is only honored if the claim's storage class sets the
+	// StorageClassAllowBucketAdoption parameter.
+	AdoptBucketAnnotation = "objectbucket.io/adopt-bucket"
 )
 
 // AccessKeys is an Authentication type for passing AWS S3 style key pairs from the provisioner to the reconciler
@@ -87,6 +169,13 @@ type Endpoint struct {
 	Region               string            `json:"region"`
 	SubRegion            string            `json:"subRegion"`
 	AdditionalConfigData map[string]string `json:"additionalConfig"`
+	// AdditionalBucketHosts are hostnames of replicas of BucketHost, eg. further gateways of a
+	// highly available object store, which serve the bucket on the same port
+	AdditionalBucketHosts []string `json:"additionalBucketHosts,omitempty"`
+	// ClientBucketName, when set, is the name by which clients address the bucket, eg. for a
+	// backend which prefixes BucketName internally. It is written to the configmap as BUCKET_NAME
+	// in place of BucketName.
+	ClientBucketName string `json:"clientBucketName,omitempty"`
 }
 
 // Connection encapsulates Endpoint and Authentication data to simplify the expected return values of the Provision()
@@ -104,9 +193,23 @@ type ObjectBucketSpec struct {
 	StorageClassName string                                `json:"storageClassName"`
 	ReclaimPolicy    *corev1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy"`
 	ClaimRef         *corev1.ObjectReference               `json:"claimRef"`
-	*Connection      `json:",inline"`
+	// Provisioning records whether the bucket was created by Provision or access to an existing
+	// bucket granted by Grant, deciding whether the bucket is deleted or access to it revoked when
+	// the claim is deleted. It is empty for OBs created before it was recorded.
+	Provisioning ObjectBucketProvisioning `json:"provisioning,omitempty"`
+	*Connection  `json:",inline"`
 }
 
+// ObjectBucketProvisioning is the operation by which the bucket of an ObjectBucket was obtained.
+type ObjectBucketProvisioning string
+
+const (
+	// ObjectBucketProvisioningDynamic indicates a new (greenfield) bucket created by Provision.
+	ObjectBucketProvisioningDynamic ObjectBucketProvisioning = "dynamic"
+	// ObjectBucketProvisioningGrant indicates access to an existing (brownfield) bucket granted by Grant.
+	ObjectBucketProvisioningGrant ObjectBucketProvisioning = "grant"
+)
+
 // ObjectBucketStatusPhase is set by the controller to save the state of the provisioning process.
 type ObjectBucketStatusPhase string
 
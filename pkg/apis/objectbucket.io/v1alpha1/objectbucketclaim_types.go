@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
@@ -30,11 +31,16 @@ func ObjectBucketClaimGVK() schema.GroupVersionKind {
 // ObjectBucketClaimSpec defines the desired state of ObjectBucketClaim
 type ObjectBucketClaimSpec struct {
 
-	// StorageClass names the StorageClass object representing the desired provisioner and parameters
-	// +required
-	// +kubebuilder:validation:MinLength=1
+	// StorageClass names the StorageClass object representing the desired provisioner and parameters.
+	// If omitted, the default StorageClass is used.
+	// +optional
 	StorageClassName string `json:"storageClassName,omitempty"`
 
+	// BucketClassName names a cluster-scoped BucketClass whose parameters, reclaim policy and
+	// quota are layered over the StorageClass.
+	// +optional
+	BucketClassName string `json:"bucketClassName,omitempty"`
+
 	// BucketName (not recommended) the name of the bucket.  Caution!
 	// In-store bucket names may collide across namespaces.  If you define
 	// the name yourself, try to make it as unique as possible.
@@ -75,9 +81,70 @@ const (
 	ObjectBucketClaimStatusPhaseFailed = "Failed"
 )
 
+const (
+	// ObjectBucketClaimConditionSecretGenerated indicates whether a secret containing the bucket's credentials was
+	// generated for the claim.  It is False when the provisioner returned no credentials, eg. for anonymous access to
+	// a public bucket.
+	ObjectBucketClaimConditionSecretGenerated = "SecretGenerated"
+	// ObjectBucketClaimConditionUpdateDegraded indicates that an update of the claim's additionalConfig was applied by
+	// the provisioner but could neither be recorded in the claim's objectBucket nor reverted, leaving the bucket's
+	// configuration inconsistent with the objectBucket.
+	ObjectBucketClaimConditionUpdateDegraded = "UpdateDegraded"
+	// ObjectBucketClaimConditionBackedOff indicates that the claim has failed to become bound for so long that the
+	// controller retries it only infrequently.
+	ObjectBucketClaimConditionBackedOff = "BackedOff"
+	// ObjectBucketClaimConditionResourceCreationForbidden indicates that the API server forbade creating the claim's
+	// secret or configmap, eg. because a resource quota is exceeded or an admission webhook rejected it.  The claim is
+	// retried with a growing backoff until an administrator resolves the cause.
+	ObjectBucketClaimConditionResourceCreationForbidden = "ResourceCreationForbidden"
+	// ObjectBucketClaimConditionWaitingForCapacity indicates that the provisioner reported the object store to be at
+	// capacity, so the claim's bucket is not yet provisioned.  The claim is retried with a growing backoff.
+	ObjectBucketClaimConditionWaitingForCapacity = "WaitingForCapacity"
+	// ObjectBucketClaimConditionCredentialsDrifted indicates that the credentials in the claim's secret differ from
+	// those of its objectBucket, eg. because an external secret manager rotated them.  The secret is left as it is.
+	ObjectBucketClaimConditionCredentialsDrifted = "CredentialsDrifted"
+	// ObjectBucketClaimConditionBucketMissing indicates that the provisioner reported the claim's bucket to be missing
+	// from the object store, eg. because it was deleted out-of-band.
+	ObjectBucketClaimConditionBucketMissing = "BucketMissing"
+)
+
+// ObjectBucketClaimProvisioningMode is how a claim obtained its bucket.
+type ObjectBucketClaimProvisioningMode string
+
+const (
+	// ObjectBucketClaimProvisioningModeDynamic indicates that a new bucket was provisioned for the claim.
+	ObjectBucketClaimProvisioningModeDynamic ObjectBucketClaimProvisioningMode = "Dynamic"
+	// ObjectBucketClaimProvisioningModeGrant indicates that the claim was granted access to an existing bucket named
+	// by its storage class.
+	ObjectBucketClaimProvisioningModeGrant ObjectBucketClaimProvisioningMode = "Grant"
+)
+
 // ObjectBucketClaimStatus defines the observed state of ObjectBucketClaim
 type ObjectBucketClaimStatus struct {
 	Phase ObjectBucketClaimStatusPhase `json:"phase,omitempty"`
+	// Reason is a brief CamelCase string describing why the claim is in its current phase, eg.
+	// ProvisioningBucket while the claim is Pending
+	Reason string `json:"reason,omitempty"`
+	// Message is a human-readable description of why the claim is in its current phase
+	Message string `json:"message,omitempty"`
+	// Code is the backend-specific code of the last error returned by the provisioner while
+	// provisioning the bucket, eg. QuotaExceeded, cleared once the claim is bound
+	Code string `json:"code,omitempty"`
+	// ReclaimPolicy is the reclaim policy which is applied to the bucket when the claim is deleted:
+	// Delete if the bucket is deleted, or Retain if only access to the bucket is revoked
+	ReclaimPolicy corev1.PersistentVolumeReclaimPolicy `json:"reclaimPolicy,omitempty"`
+	// ProvisionDurationSeconds is how long the provisioner took to provision the bucket, or to grant
+	// access to it, when the claim was last provisioned
+	ProvisionDurationSeconds float64 `json:"provisionDurationSeconds,omitempty"`
+	// ProvisioningMode is how the claim obtained its bucket when it was last provisioned: Dynamic if
+	// a new bucket was provisioned, or Grant if access to an existing bucket was granted
+	ProvisioningMode ObjectBucketClaimProvisioningMode `json:"provisioningMode,omitempty"`
+	// ConfigMapName and SecretName are the names of the configmap and secret generated for the
+	// claim, which may differ from the claim's name, eg. when they are written to another namespace
+	ConfigMapName string `json:"configMapName,omitempty"`
+	SecretName    string `json:"secretName,omitempty"`
+	// Conditions describe the state of the resources generated for the claim
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +genclient
@@ -86,6 +153,7 @@ type ObjectBucketClaimStatus struct {
 // +kubebuilder:resource:shortName=obc;obcs
 // +kubebuilder:printcolumn:name="StorageClass",type="string",JSONPath=".spec.storageClassName",description="StorageClass"
 // +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Phase"
+// +kubebuilder:printcolumn:name="Provisioning-Mode",type="string",JSONPath=".status.provisioningMode",description="How the claim obtained its bucket",priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // ObjectBucketClaim is the Schema for the objectbucketclaims API
@@ -58,6 +58,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&ObjectBucketClaimList{},
 		&ObjectBucket{},
 		&ObjectBucketList{},
+		&BucketClass{},
+		&BucketClassList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -17,7 +17,9 @@ limitations under the License.
 package errors
 
 import (
+	stderrors "errors"
 	"fmt"
+	"time"
 )
 
 // BucketExistsErr SHOULD be returned by the Provision() method when bucket creation fails due a name collision in the
@@ -38,8 +40,107 @@ func NewBucketExistsError(msg string) *BucketExistsErr {
 	}
 }
 
-// IsBucketExists returns true if the error is of type BucketExistsErr
-func IsBucketExists(e error) (is bool) {
-	_, is = e.(BucketExistsErr)
-	return is
-}
\ No newline at end of file
+// IsBucketExists returns true if the error is of type BucketExistsErr, or a pointer to one as
+// returned by NewBucketExistsError
+func IsBucketExists(e error) bool {
+	switch e.(type) {
+	case BucketExistsErr, *BucketExistsErr:
+		return true
+	}
+	return false
+}
+// RequeueAfterErr MAY be returned by the Provision() and Grant() methods when the bucket is not yet
+// ready, eg. because the object store creates it asynchronously, and the provisioner knows when to
+// check back. The claim is retried after the given duration rather than after the controller's
+// default backoff.
+type RequeueAfterErr struct {
+	errString string
+	after     time.Duration
+}
+
+// Error implements the Error interface
+func (e RequeueAfterErr) Error() string {
+	return fmt.Sprintf("%v, requeue after %v", e.errString, e.after)
+}
+
+// NewRequeueAfterError is a simple constructor for a RequeueAfterErr
+func NewRequeueAfterError(msg string, after time.Duration) *RequeueAfterErr {
+	return &RequeueAfterErr{
+		errString: msg,
+		after:     after,
+	}
+}
+
+// RequeueAfter returns the duration after which to retry, and true, if the error is of type
+// RequeueAfterErr or a pointer to one
+func RequeueAfter(e error) (time.Duration, bool) {
+	switch e := e.(type) {
+	case RequeueAfterErr:
+		return e.after, true
+	case *RequeueAfterErr:
+		return e.after, true
+	}
+	return 0, false
+}
+
+// ProvisionerErr MAY be returned by the Provision() and Grant() methods to describe a failure with
+// a backend-specific code, eg. "QuotaExceeded", and details. The code is recorded in the status of
+// the claim and in the event reporting the failure, for machine-readable diagnostics. The claim is
+// retried as for any other error.
+type ProvisionerErr struct {
+	code    string
+	details string
+}
+
+// Error implements the Error interface
+func (e ProvisionerErr) Error() string {
+	return fmt.Sprintf("%v: %v", e.code, e.details)
+}
+
+// NewProvisionerError is a simple constructor for a ProvisionerErr
+func NewProvisionerError(code, details string) *ProvisionerErr {
+	return &ProvisionerErr{
+		code:    code,
+		details: details,
+	}
+}
+
+// Code returns the code and details of the error, and true, if the error is of type
+// ProvisionerErr or a pointer to one
+func Code(e error) (code, details string, ok bool) {
+	switch e := e.(type) {
+	case ProvisionerErr:
+		return e.code, e.details, true
+	case *ProvisionerErr:
+		return e.code, e.details, true
+	}
+	return "", "", false
+}
+
+// PreserveBucketErr MAY be returned by the Provision() method, together with the ObjectBucket it
+// created, and by the validators of a bucket, eg. ValidateCredentials(), when the bucket must not be
+// deleted in the cleanup of the failure, eg. because it existed before and was adopted. Access to
+// the bucket is then revoked rather than the bucket deleted.
+type PreserveBucketErr struct {
+	errString string
+}
+
+// Error implements the Error interface
+func (e PreserveBucketErr) Error() string {
+	return fmt.Sprintf("%v", e.errString)
+}
+
+// NewPreserveBucket is a simple constructor for a PreserveBucketErr
+func NewPreserveBucket(msg string) *PreserveBucketErr {
+	return &PreserveBucketErr{
+		errString: msg,
+	}
+}
+
+// IsPreserveBucket returns true if the error is of type PreserveBucketErr, or a pointer to one, or
+// wraps one
+func IsPreserveBucket(e error) bool {
+	var value PreserveBucketErr
+	var pointer *PreserveBucketErr
+	return stderrors.As(e, &value) || stderrors.As(e, &pointer)
+}
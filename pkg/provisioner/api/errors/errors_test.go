@@ -0,0 +1,175 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package errors
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIsBucketExists(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "constructed error",
+			err:  NewBucketExistsError("bucket exists"),
+			want: true,
+		},
+		{
+			name: "error value",
+			err:  BucketExistsErr{errString: "bucket exists"},
+			want: true,
+		},
+		{
+			name: "other error",
+			err:  fmt.Errorf("bucket exists"),
+			want: false,
+		},
+		{
+			name: "nil error",
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsBucketExists(tt.err); got != tt.want {
+				t.Errorf("IsBucketExists() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequeueAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		want   time.Duration
+		wantOk bool
+	}{
+		{
+			name:   "constructed error",
+			err:    NewRequeueAfterError("bucket is being created", 30*time.Second),
+			want:   30 * time.Second,
+			wantOk: true,
+		},
+		{
+			name:   "error value",
+			err:    RequeueAfterErr{errString: "bucket is being created", after: time.Minute},
+			want:   time.Minute,
+			wantOk: true,
+		},
+		{
+			name: "other error",
+			err:  fmt.Errorf("bucket is being created"),
+		},
+		{
+			name: "nil error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RequeueAfter(tt.err)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("RequeueAfter() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    string
+		wantDetails string
+		wantOk      bool
+	}{
+		{
+			name:        "constructed error",
+			err:         NewProvisionerError("QuotaExceeded", "account has 100 buckets"),
+			wantCode:    "QuotaExceeded",
+			wantDetails: "account has 100 buckets",
+			wantOk:      true,
+		},
+		{
+			name:        "error value",
+			err:         ProvisionerErr{code: "AccessDenied", details: "invalid credentials"},
+			wantCode:    "AccessDenied",
+			wantDetails: "invalid credentials",
+			wantOk:      true,
+		},
+		{
+			name: "other error",
+			err:  fmt.Errorf("QuotaExceeded"),
+		},
+		{
+			name: "nil error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, details, ok := Code(tt.err)
+			if code != tt.wantCode || details != tt.wantDetails || ok != tt.wantOk {
+				t.Errorf("Code() = %q, %q, %v, want %q, %q, %v", code, details, ok, tt.wantCode, tt.wantDetails, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestIsPreserveBucket(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "constructed error",
+			err:  NewPreserveBucket("bucket was adopted"),
+			want: true,
+		},
+		{
+			name: "error value",
+			err:  PreserveBucketErr{errString: "bucket was adopted"},
+			want: true,
+		},
+		{
+			name: "wrapped error",
+			err:  fmt.Errorf("credentials do not work: %w", NewPreserveBucket("bucket was adopted")),
+			want: true,
+		},
+		{
+			name: "other error",
+			err:  fmt.Errorf("bucket was adopted"),
+			want: false,
+		},
+		{
+			name: "nil error",
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPreserveBucket(tt.err); got != tt.want {
+				t.Errorf("IsPreserveBucket() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package api
 
 import (
+	"context"
+
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
@@ -41,6 +43,9 @@ type Provisioner interface {
 	// The Provision implementation may opt to specify the ObjectBucket spec's ReclaimPolicy in
 	// cases where the provisioner wishes to set a different value from the one specified in the
 	// ObjectBucketClaim's StorageClass.
+	// The Provision implementation may likewise specify the ObjectBucket spec's StorageClassName in
+	// order to categorize the ObjectBucket under a different logical class than the
+	// ObjectBucketClaim's. If left empty, the ObjectBucketClaim's StorageClass is used.
 	// The Provision implementation must be idempotent.
 	// The Provision implementation does not need to clean up bucket or user resources when
 	// returning an error.
@@ -55,10 +60,171 @@ type Provisioner interface {
 	Revoke(ob *v1alpha1.ObjectBucket) error
 }
 
+// Updater may optionally be implemented by a Provisioner which supports changes to the
+// additionalConfig of a bound OBC. Update is called with the OBC's ObjectBucket, whose Endpoint's
+// AdditionalConfigData has been set to the OBC's new additionalConfig. If the ObjectBucket cannot
+// then be updated, Update is called again with the previous additionalConfig to revert the change.
+// The Update implementation must be idempotent. Provisioners which do not implement Updater have
+// Provision or Grant called again instead.
+type Updater interface {
+	Update(ob *v1alpha1.ObjectBucket) error
+}
+
+// PreProvisioner may optionally be implemented by a Provisioner which needs to validate an OBC or
+// its namespace, eg. require a billing annotation on the namespace, before a bucket is provisioned
+// or access to it is granted. PreProvision is called before each call to Provision or Grant for
+// an OBC which is not yet bound. If PreProvision returns an error, the OBC is marked Failed with
+// the error's message.
+type PreProvisioner interface {
+	PreProvision(obc *v1alpha1.ObjectBucketClaim, namespace *corev1.Namespace) error
+}
+
+// BucketNameValidator may optionally be implemented by a Provisioner which enforces a naming policy
+// on buckets, eg. that the names of buckets start with the name of the OBC's namespace.
+// ValidateBucketName is called with the name of the bucket, whether composed from the OBC or named
+// by its StorageClass, before Provision or Grant is called for an OBC which is not yet bound. If it
+// returns an error, the OBC is marked Failed with the error's message and is not retried.
+type BucketNameValidator interface {
+	ValidateBucketName(obc *v1alpha1.ObjectBucketClaim, name string) error
+}
+
+// CredentialsValidator may optionally be implemented by a Provisioner which can check that the
+// credentials of a bucket work, eg. by a smoke test against its object store. When the controller
+// is created with the WithCredentialsValidation option, ValidateCredentials is called with the
+// ObjectBucket returned by Provision or Grant, if it has credentials, before the OBC's Secret is
+// written. If it returns an error, the new bucket is deleted or access to the existing bucket
+// revoked, and the OBC is marked Failed, rather than handing non-working credentials to its
+// consumers. Access to a new bucket is revoked rather than the bucket deleted if the error is a
+// PreserveBucketErr.
+type CredentialsValidator interface {
+	ValidateCredentials(ob *v1alpha1.ObjectBucket) error
+}
+
+// ReleaseHandler may optionally be implemented by a Provisioner which reacts to the teardown of a
+// bucket, eg. by archiving it. OnReleased is called with the ObjectBucket once the OB is marked
+// Released after its OBC is deleted, before the bucket is deleted or access to it revoked and
+// before the OBC's resources are deleted. It is called once, when the OB transitions into Released.
+// An error is logged but does not block the deletion.
+type ReleaseHandler interface {
+	OnReleased(ob *v1alpha1.ObjectBucket) error
+}
+
+// BucketChecker may optionally be implemented by a Provisioner which can tell whether the bucket of
+// an ObjectBucket still exists in the object store, to detect buckets deleted out-of-band. When the
+// controller is created with the WithBucketExistenceCheck option, BucketExists is called
+// periodically with the ObjectBucket of each bound OBC. If it returns false, the OBC's
+// BucketMissing condition is set and a Warning event is recorded. An error is logged and the
+// bucket is checked again in the next period.
+type BucketChecker interface {
+	BucketExists(ob *v1alpha1.ObjectBucket) (bool, error)
+}
+
+// CapacityChecker may optionally be implemented by a Provisioner whose object store has a global
+// capacity limit, to apply backpressure rather than failing or overcommitting. HasCapacity is
+// called with the options of each new bucket before Provision. If it returns false, the OBC is
+// kept Pending with a WaitingForCapacity condition and retried with a growing backoff.
+type CapacityChecker interface {
+	HasCapacity(options *BucketOptions) (bool, error)
+}
+
+// ConfigMapDataBuilder may optionally be implemented by a Provisioner which needs control over the
+// data of the ConfigMap generated for each OBC, eg. to publish multiple endpoints. The returned
+// data is merged with the standard keys derived from the ObjectBucket's Endpoint, overriding them
+// where keys collide. Keys must be valid ConfigMap keys.
+type ConfigMapDataBuilder interface {
+	BuildConfigMapData(ob *v1alpha1.ObjectBucket) (map[string]string, error)
+}
+
+// Preflighter may optionally be implemented by a Provisioner which can check that it is correctly
+// wired, eg. that its backend is reachable and its credentials are valid. When the controller is
+// created with the WithPreflight option, Preflight is called by Start before any OBC is processed,
+// and Start fails if it returns an error. The context is canceled when the preflight times out or
+// the controller is stopped.
+type Preflighter interface {
+	Preflight(ctx context.Context) error
+}
+
+// LifecycleHandler may optionally be implemented by a Provisioner which holds expensive resources
+// for the lifetime of the controller, eg. a pool of connections to its object store. Init is
+// called by Start before any OBC is processed, with a context canceled when the controller is
+// stopped, and Start fails if it returns an error. Shutdown is called once Start has been stopped
+// and its workers have finished the OBCs they were processing, those still queued being left to
+// the next leader, or when Start fails after Init succeeded. An error returned by Shutdown is
+// logged. Both are only called for the Provisioner the controller was started with, not for one
+// passed to SetProvisioner.
+type LifecycleHandler interface {
+	Init(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+// ControllerVersion is the semantic version of the controller of this library. It is raised when
+// the controller changes in a way a provisioner may depend on, eg. when the Provisioner interface
+// gains a method or a parameter, so that a VersionRequirer can demand it.
+const ControllerVersion = "1.0.0"
+
+// VersionRequirer may optionally be implemented by a Provisioner which depends on a minimum version
+// of the controller, eg. because it implements an interface method added in that version.
+// RequiredControllerVersion returns a semantic version, eg. "1.2.0", and Start fails if it is newer
+// than ControllerVersion or cannot be parsed, rather than running the provisioner against a
+// controller it may subtly misbehave with.
+type VersionRequirer interface {
+	RequiredControllerVersion() string
+}
+
+// AccessMode is the access to a bucket granted by the credentials generated for an OBC.
+type AccessMode string
+
+const (
+	// AccessModeRead grants read-only access to the bucket's objects
+	AccessModeRead AccessMode = "read"
+	// AccessModeReadWrite grants read and write access to the bucket's objects, the default
+	AccessModeReadWrite AccessMode = "readwrite"
+)
+
+// LifecycleRule is a rule of a bucket's lifecycle policy. Each rule has an ID and expires or
+// transitions objects, or both.
+type LifecycleRule struct {
+	// ID identifies the rule, unique within the policy
+	ID string `json:"id"`
+	// Prefix limits the rule to objects whose keys start with it, all objects if empty
+	Prefix string `json:"prefix,omitempty"`
+	// ExpirationDays is the age in days after which objects are deleted, never if 0
+	ExpirationDays int `json:"expirationDays,omitempty"`
+	// TransitionDays is the age in days after which objects are moved to the
+	// TransitionStorageClass, never if 0
+	TransitionDays int `json:"transitionDays,omitempty"`
+	// TransitionStorageClass is the object store's storage class, eg. "GLACIER", to which objects
+	// are moved
+	TransitionStorageClass string `json:"transitionStorageClass,omitempty"`
+}
+
+// LifecycleApplier may optionally be implemented by a Provisioner which supports the lifecycle
+// rules requested by an OBC's "lifecycle" additionalConfig entry. ApplyLifecycle is called with
+// the ObjectBucket and the validated rules after a bucket is provisioned, and again when the
+// entry of a bound OBC is changed, with nil rules if it was removed. The rules replace the
+// bucket's lifecycle policy. The ApplyLifecycle implementation must be idempotent. OBCs requesting
+// lifecycle rules from provisioners which do not implement LifecycleApplier are rejected.
+type LifecycleApplier interface {
+	ApplyLifecycle(ob *v1alpha1.ObjectBucket, rules []LifecycleRule) error
+}
+
+// AdditionalConfigSchema maps each additionalConfig key supported by a provisioner to a function
+// validating the key's value. A nil function accepts any value.
+type AdditionalConfigSchema map[string]func(value string) error
+
+// AdditionalConfigValidator may optionally be implemented by a Provisioner which supports a known
+// set of OBC additionalConfig keys. The OBC's additionalConfig is validated against the returned
+// schema before a bucket is provisioned, access to it is granted, or it is updated. Unknown keys
+// and invalid values are reported by a warning event, or, if the controller is strict, the OBC is
+// rejected.
+type AdditionalConfigValidator interface {
+	AdditionalConfigSchema() AdditionalConfigSchema
+}
+
 // BucketOptions wraps all pertinent data that the Provisioner requires to create a
 // bucket and the Reconciler requires to abstract that bucket in kubernetes
 type BucketOptions struct {
-	// ReclaimPolicy is the reclaimPolicy of the OBC's storage class
+	// ReclaimPolicy is the reclaimPolicy of the OBC's storage class, or of its bucket class if set
 	ReclaimPolicy *corev1.PersistentVolumeReclaimPolicy
 	// BucketName is the name of the bucket within the object store
 	BucketName string
@@ -66,6 +232,28 @@ type BucketOptions struct {
 	UserID string
 	// ObjectBucketClaim is a copy of the reconciler's OBC
 	ObjectBucketClaim *v1alpha1.ObjectBucketClaim
-	// Parameters is a complete copy of the OBC's storage class Parameters field
+	// Parameters is a complete copy of the OBC's storage class Parameters field, merged with the
+	// parameters of the OBC's bucket class, if any
 	Parameters map[string]string
+	// Quota is the quota of the OBC's bucket class, nil if none
+	Quota *v1alpha1.BucketQuota
+	// Tags are to be applied to the bucket as native bucket tags, eg. for cost allocation. They
+	// are taken from the storage class's "tags" parameter and from the OBC's labels and
+	// annotations named by its "tagLabels" parameter. Nil if there are no tags.
+	Tags map[string]string
+	// Versioning is whether object versioning is to be enabled on the bucket, as requested by the
+	// OBC's "versioning" additionalConfig entry
+	Versioning bool
+	// AccessMode is the access to the bucket to be granted by the credentials generated for the
+	// OBC, as requested by its "access" additionalConfig entry
+	AccessMode AccessMode
+	// Lifecycle holds the lifecycle rules requested by the OBC's "lifecycle" additionalConfig
+	// entry, nil if none. They are applied by the controller calling ApplyLifecycle once the bucket
+	// is provisioned.
+	Lifecycle []LifecycleRule
+	// Progress may be called by Provision or Grant to report a step of a slow request, eg.
+	// "applying lifecycle policy". The step is shown as the message of the OBC's status. Updates
+	// of the OBC are rate limited, so not every step is necessarily shown. Progress is safe to
+	// call from any goroutine, and does nothing once Provision or Grant has returned.
+	Progress func(message string)
 }
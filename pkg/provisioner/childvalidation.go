@@ -0,0 +1,79 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ChildValidator checks the data of the secret or configmap generated for a claim before it is
+// created. kind is either "secret" or "configmap". An error rejects the bucket, failing the claim.
+type ChildValidator func(kind string, data map[string]string) error
+
+// MaxChildSize returns a ChildValidator rejecting secrets and configmaps whose keys and values
+// total more than limit bytes.
+func MaxChildSize(limit int) ChildValidator {
+	return func(kind string, data map[string]string) error {
+		size := 0
+		for k, v := range data {
+			size += len(k) + len(v)
+		}
+		if size > limit {
+			return fmt.Errorf("%s data is %d bytes, exceeding the limit of %d bytes", kind, size, limit)
+		}
+		return nil
+	}
+}
+
+// ForbiddenChildKeys returns a ChildValidator rejecting secrets and configmaps which contain any of
+// the given keys.
+func ForbiddenChildKeys(keys ...string) ChildValidator {
+	forbidden := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		forbidden[k] = true
+	}
+	return func(kind string, data map[string]string) error {
+		var found []string
+		for k := range data {
+			if forbidden[k] {
+				found = append(found, k)
+			}
+		}
+		if len(found) > 0 {
+			sort.Strings(found)
+			return fmt.Errorf("%s contains forbidden keys %q", kind, found)
+		}
+		return nil
+	}
+}
+
+// validateChildren runs the child validators on the data of the secret, unless none is generated,
+// and of the configmap of a claim.
+func (c *obcController) validateChildren(secretData, configMapData map[string]string, secretGenerated bool) error {
+	for _, validate := range c.childValidators {
+		if secretGenerated {
+			if err := validate("secret", secretData); err != nil {
+				return err
+			}
+		}
+		if err := validate("configmap", configMapData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+)
+
+// classLimiter bounds the number of concurrent Provision and Grant calls for each storage class
+// which sets the "maxConcurrentProvisions" parameter, so that a rate-sensitive backend is not
+// overwhelmed while claims of other classes are processed in parallel.
+type classLimiter struct {
+	lock sync.Mutex
+	// slots holds a semaphore for each limited class, whose capacity is the class's limit
+	slots map[string]chan struct{}
+}
+
+func newClassLimiter() *classLimiter {
+	return &classLimiter{slots: make(map[string]chan struct{})}
+}
+
+// maxConcurrentProvisionsForClass returns the limit on concurrent provisioner calls set by the
+// class, or 0 if unlimited.
+func maxConcurrentProvisionsForClass(class *storagev1.StorageClass) (int, error) {
+	value, ok := class.Parameters[v1alpha1.StorageClassMaxConcurrentProvisions]
+	if !ok {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("StorageClass %q parameter %q must be a positive integer, got %q", class.Name, v1alpha1.StorageClassMaxConcurrentProvisions, value)
+	}
+	return limit, nil
+}
+
+// acquire blocks until a provisioner call for the class may start, and returns the function which
+// must be called once the call has returned. A limit of 0 does not block. If the class's limit has
+// changed, calls already in flight are not counted against the new limit.
+func (l *classLimiter) acquire(class string, limit int) (release func()) {
+	provisionsInFlight.WithLabelValues(class).Inc()
+	done := func() { provisionsInFlight.WithLabelValues(class).Dec() }
+	if limit == 0 {
+		return done
+	}
+
+	l.lock.Lock()
+	slots, ok := l.slots[class]
+	if !ok || cap(slots) != limit {
+		slots = make(chan struct{}, limit)
+		l.slots[class] = slots
+	}
+	l.lock.Unlock()
+
+	slots <- struct{}{}
+	return func() {
+		<-slots
+		done()
+	}
+}
+
+// maxClaimsForClass returns the limit on bound and pending claims set by the class, or 0 if
+// unlimited.
+func maxClaimsForClass(class *storagev1.StorageClass) (int, error) {
+	value, ok := class.Parameters[v1alpha1.StorageClassMaxClaims]
+	if !ok {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("StorageClass %q parameter %q must be a positive integer, got %q", class.Name, v1alpha1.StorageClassMaxClaims, value)
+	}
+	return limit, nil
+}
+
+// claimsOfClassAhead counts the other claims of the class which hold one of its maxClaims places
+// ahead of the given claim: those which are bound, and those pending since before it, so that
+// pending claims are admitted in the order they were created.
+func (c *obcController) claimsOfClassAhead(obc *v1alpha1.ObjectBucketClaim, className string) (int, error) {
+	obcs, err := c.obcLister.List(labels.Everything())
+	if err != nil {
+		return 0, fmt.Errorf("error listing OBCs of storage class %q: %v", className, err)
+	}
+	ahead := 0
+	for _, other := range obcs {
+		if other.Spec.StorageClassName != className || (other.Namespace == obc.Namespace && other.Name == obc.Name) {
+			continue
+		}
+		switch other.Status.Phase {
+		case v1alpha1.ObjectBucketClaimStatusPhaseBound:
+			ahead++
+		case v1alpha1.ObjectBucketClaimStatusPhasePending:
+			if createdBefore(other, obc) {
+				ahead++
+			}
+		}
+	}
+	return ahead, nil
+}
+
+// createdBefore orders claims by creation time, and claims created in the same second by key.
+func createdBefore(a, b *v1alpha1.ObjectBucketClaim) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+}
+
+// waitForClassQuota keeps a claim which would exceed its class's maxClaims Pending, and returns
+// the error by which it is retried with the rate limiter's backoff.
+func (c *obcController) waitForClassQuota(obc *v1alpha1.ObjectBucketClaim, className string, limit int) error {
+	quotaErr := fmt.Errorf("StorageClass %q allows at most %d bound or pending claims", className, limit)
+	log.Info("waiting for storage class quota", "class", className, "maxClaims", limit)
+	c.recorder.Event(obc, corev1.EventTypeWarning, reasonClassQuotaExceeded, quotaErr.Error())
+	if _, err := updateObjectBucketClaimProgress(c.libClientset, obc, reasonClassQuotaExceeded, quotaErr.Error()); err != nil {
+		log.Error(err, "error recording progress")
+	}
+	return quotaErr
+}
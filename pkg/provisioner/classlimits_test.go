@@ -0,0 +1,149 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+)
+
+func TestMaxConcurrentProvisionsForClass(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		want       int
+		wantErr    bool
+	}{
+		{
+			name: "unlimited",
+		},
+		{
+			name:       "limited",
+			parameters: map[string]string{v1alpha1.StorageClassMaxConcurrentProvisions: "2"},
+			want:       2,
+		},
+		{
+			name:       "zero",
+			parameters: map[string]string{v1alpha1.StorageClassMaxConcurrentProvisions: "0"},
+			wantErr:    true,
+		},
+		{
+			name:       "not a number",
+			parameters: map[string]string{v1alpha1.StorageClassMaxConcurrentProvisions: "few"},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := maxConcurrentProvisionsForClass(newTestStorageClass(tt.parameters))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("maxConcurrentProvisionsForClass() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("maxConcurrentProvisionsForClass() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxClaimsForClass(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		want       int
+		wantErr    bool
+	}{
+		{
+			name: "unlimited",
+		},
+		{
+			name:       "limited",
+			parameters: map[string]string{v1alpha1.StorageClassMaxClaims: "10"},
+			want:       10,
+		},
+		{
+			name:       "negative",
+			parameters: map[string]string{v1alpha1.StorageClassMaxClaims: "-1"},
+			wantErr:    true,
+		},
+		{
+			name:       "not a number",
+			parameters: map[string]string{v1alpha1.StorageClassMaxClaims: "many"},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := maxClaimsForClass(newTestStorageClass(tt.parameters))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("maxClaimsForClass() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("maxClaimsForClass() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassLimiter(t *testing.T) {
+	const (
+		limited   = "throttled"
+		unlimited = "local"
+	)
+	l := newClassLimiter()
+	inFlight := func(class string) float64 {
+		return testutil.ToFloat64(provisionsInFlight.WithLabelValues(class))
+	}
+
+	release := l.acquire(limited, 1)
+	// other classes are not blocked by the limited class
+	releaseUnlimited := l.acquire(unlimited, 0)
+	if got := inFlight(unlimited); got != 1 {
+		t.Errorf("wanted 1 call in flight for class %q, got %v", unlimited, got)
+	}
+	releaseUnlimited()
+
+	acquired := make(chan func())
+	go func() {
+		acquired <- l.acquire(limited, 1)
+	}()
+	select {
+	case <-acquired:
+		t.Fatalf("wanted second call for class %q to wait for the first", limited)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got := inFlight(limited); got != 2 {
+		t.Errorf("wanted 2 calls in flight for class %q, got %v", limited, got)
+	}
+
+	release()
+	select {
+	case release = <-acquired:
+		release()
+	case <-time.After(5 * time.Second):
+		t.Fatalf("wanted second call for class %q to start once the first returned", limited)
+	}
+	if got := inFlight(limited); got != 0 {
+		t.Errorf("wanted no calls in flight for class %q, got %v", limited, got)
+	}
+}
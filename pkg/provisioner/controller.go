@@ -18,33 +18,48 @@ package provisioner
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/apimachinery/pkg/util/wait"
+	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
 	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
 
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned"
 	informers "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/informers/externalversions/objectbucket.io/v1alpha1"
 	listers "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/listers/objectbucket.io/v1alpha1"
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
+	bucketerrors "github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api/errors"
 )
 
 type controller interface {
 	Start(<-chan struct{}) error
 	SetLabels(map[string]string)
+	SetProvisioner(api.Provisioner)
+	DeleteAllManaged(context.Context) error
 }
 
 // Provisioner is a CRD Controller responsible for executing the Reconcile() function
@@ -58,16 +73,181 @@ type obcController struct {
 	obcHasSynced cache.InformerSynced
 	obHasSynced  cache.InformerSynced
 	queue        workqueue.RateLimitingInterface
+	// priorityQueue, when non-nil, holds the keys of claims being deleted, which are processed
+	// ahead of the keys in queue. Keys which fail to sync are requeued on the queue they were
+	// taken from.
+	priorityQueue workqueue.Interface
+	// priorityLimiter rate limits the keys requeued on priorityQueue
+	priorityLimiter workqueue.RateLimiter
+	// priorityLock serializes taking keys from priorityQueue and guards inFlight
+	priorityLock sync.Mutex
+	// inFlight holds the keys being processed when priorityQueue is used. Unlike a single queue,
+	// two queues do not prevent the same key from being processed by two workers at once.
+	inFlight map[string]bool
 	// static label containing provisioner name and provisioner-specific labels which are all added
 	// to the OB, OBC, configmap and secret
 	provisionerLabels map[string]string
-	provisioner       api.Provisioner
-	provisionerName   string
+	// obLabels are added to the OB only, with provisionerLabels taking precedence
+	obLabels map[string]string
+	// labelsLock guards provisionerLabels, which SetLabels may modify while workers are reading it
+	labelsLock sync.RWMutex
+	// provisionerLock guards provisioner. Syncs read the provisioner once, with currentProvisioner,
+	// so that one replaced by SetProvisioner is used until the end of the syncs which started with it.
+	provisionerLock sync.RWMutex
+	provisioner     api.Provisioner
+	provisionerName string
+	// workers tracks the workers started by Start, which waits for them to finish the keys they
+	// are processing
+	workers sync.WaitGroup
+	// stopCh is the channel Start was given, once closed workers take no further keys
+	stopCh <-chan struct{}
+	// activeKeys holds the key each busy worker is processing, by worker ID, guarded by activeLock
+	activeKeys map[int]string
+	activeLock sync.Mutex
+	// keyFunc and splitKey convert OBCs to and from workqueue keys
+	keyFunc  cache.KeyFunc
+	splitKey KeySplitFunc
+	recorder record.EventRecorder
+	// provisionTimeout is the default bound on Provision and Grant calls, 0 meaning no bound
+	provisionTimeout time.Duration
+	// deleteTimeout, when set, bounds Delete and Revoke calls in place of provisionTimeout
+	deleteTimeout *time.Duration
+	// provisionerVersion, when set, is annotated on the OB, OBC, configmap and secret
+	provisionerVersion string
+	// namespace to which the controller is restricted, empty for all namespaces
+	namespace string
+	// reconcileChildren enables reverting changes made to the generated configmaps and secrets
+	reconcileChildren bool
+	// childInformerFactory, when non-nil, provides informers of the generated configmaps and secrets
+	childInformerFactory kubeinformers.SharedInformerFactory
+	childrenHaveSynced   []cache.InformerSynced
+	// classInformerFactory, when non-nil, provides an informer of storage classes whose changes
+	// are applied to bound claims
+	classInformerFactory kubeinformers.SharedInformerFactory
+	classHasSynced       cache.InformerSynced
+	// unbound claims older than stuckClaimAge which fail to sync are requeued after
+	// stuckClaimBackoff rather than by the rate limiter. Disabled if 0.
+	stuckClaimAge     time.Duration
+	stuckClaimBackoff time.Duration
+	// namespaceDeletionRetries is the number of failed deletions after which a claim in a
+	// terminating namespace is released without cleaning up its bucket. Disabled if 0.
+	namespaceDeletionRetries int
+	// deletionFailures counts the failed deletions of claims by UID, guarded by
+	// deletionFailuresLock. It is kept apart from the queue's requeue count, which is
+	// reset whenever a claim is forgotten or requeued after a delay.
+	deletionFailuresLock sync.Mutex
+	deletionFailures     map[types.UID]int
+	// getBucketClass gets the BucketClass with the given name
+	getBucketClass func(name string) (*v1alpha1.BucketClass, error)
+	// every stuckPendingInterval, claims Pending for longer than stuckPendingAge without an OB are
+	// reported and requeued. Disabled if 0.
+	stuckPendingAge      time.Duration
+	stuckPendingInterval time.Duration
+	// every unboundResyncInterval, claims which are not Bound are requeued. Disabled if 0.
+	unboundResyncInterval time.Duration
+	// createdByKey and createdByValue label the OBs created by the controller
+	createdByKey   string
+	createdByValue string
+	// reconcileOrphansOnStartup has Start clean up the OBs whose claim no longer exists
+	reconcileOrphansOnStartup bool
+	// orphanSelector restricts the OBs which may be cleaned up as orphans
+	orphanSelector labels.Selector
+	// every bucketCheckInterval, the buckets of bound claims are checked at the rate allowed by
+	// bucketCheckLimiter, and missing new buckets provisioned again if reprovisionMissingBuckets
+	// is set. Disabled if 0.
+	bucketCheckInterval       time.Duration
+	bucketCheckLimiter        flowcontrol.RateLimiter
+	reprovisionMissingBuckets bool
+	// strictAdditionalConfig rejects claims whose additionalConfig does not match the provisioner's
+	// schema, rather than only warning about them
+	strictAdditionalConfig bool
+	// progressInterval is the minimum interval between updates of a claim with the progress
+	// reported by the provisioner
+	progressInterval time.Duration
+	// keys added while queue holds maxQueueDepth keys are deferred by queueDeferral. Disabled if 0.
+	maxQueueDepth int
+	queueDeferral time.Duration
+	// eventCoalescing delays keys queued by events, jittered, so that bursts of events for a claim
+	// collapse into a single reconcile. Disabled if 0.
+	eventCoalescing time.Duration
+	// requireTLSEndpoint fails claims whose endpoint does not use TLS, unless overridden by the
+	// storage class
+	requireTLSEndpoint bool
+	// classLimiter bounds the concurrent provisioner calls of storage classes which set a limit
+	classLimiter *classLimiter
+	// metricsBindAddress, when set, is the address on which Start serves metrics
+	metricsBindAddress string
+	// debugHandlers has the metrics server also serve profiles and the active workers
+	debugHandlers bool
+	// bulkDeletion enables DeleteAllManaged
+	bulkDeletion bool
+	// preflight has Start call the provisioner's Preflight, bounded by preflightTimeout if set
+	preflight        bool
+	preflightTimeout time.Duration
+	// childrenDeletedFirst deletes the secret and configmap of a deleted claim before its OB
+	childrenDeletedFirst bool
+	// watchStorageClasses enables reconciling bound claims when their storage class changes
+	watchStorageClasses bool
+	// configMapFirst creates the configmap of a claim before its secret
+	configMapFirst bool
+	// validateCredentials has a CredentialsValidator check the credentials of buckets before they
+	// are written
+	validateCredentials bool
+	// childValidators check the data of the secret and configmap of a claim before they are created
+	childValidators []ChildValidator
+	// syncReclaimPolicy applies changes of the reclaim policy of storage classes to bound OBs
+	syncReclaimPolicy bool
+	// redactedParameters are the storage class parameters whose values are not logged
+	redactedParameters map[string]bool
+	// previousProvisionerNames are the names of this provisioner before a rename, whose deleted
+	// claims are cleaned up
+	previousProvisionerNames map[string]bool
+	// unsupportedReported holds the UIDs of claims of other provisioners on which an event was
+	// recorded, guarded by unsupportedLock
+	unsupportedLock     sync.Mutex
+	unsupportedReported map[types.UID]bool
+	// each attempt to sync the caches in Start times out after cacheSyncTimeout, doubling with
+	// each of the cacheSyncRetries. Start waits indefinitely if 0.
+	cacheSyncTimeout time.Duration
+	cacheSyncRetries int
+	// resourceNamespace, when set, is the namespace of the generated configmaps and secrets in
+	// place of the claim's namespace
+	resourceNamespace string
+	// forbiddenBackoff delays the retries of claims whose resources may not be created
+	forbiddenBackoff workqueue.RateLimiter
+	// classifyError decides how claims whose reconcile returned an error are requeued
+	classifyError ErrorClassifier
+	// conflictRetries is the number of times updates of a claim or OB which conflict with a
+	// concurrent update are retried against the latest version, before the claim is requeued
+	conflictRetries int
+	// secretDrift, when SecretDriftFlag, keeps the credentials of secrets changed outside the controller
+	// rather than restoring those returned by the provisioner
+	secretDrift SecretDriftPolicy
+	// hashLongNames provisions claims whose generated names would be too long under names
+	// truncated and suffixed with a hash, rather than rejecting them
+	hashLongNames bool
+	// shutdownSummary, when set, is called with the summary logged when Start stops
+	shutdownSummary func(ShutdownSummary)
+	// clock is read for the age of claims, the times of status conditions and the interval between
+	// progress updates
+	clock clock.Clock
 }
 
 var _ controller = &obcController{}
 
-func NewController(provisionerName string, provisioner api.Provisioner, clientset kubernetes.Interface, crdClientSet versioned.Interface, obcInformer informers.ObjectBucketClaimInformer, obInformer informers.ObjectBucketInformer) *obcController {
+// ShutdownSummary describes the state of the provisioner's claims when the controller stops.
+type ShutdownSummary struct {
+	// Bound, Pending, Failed and Released count the claims labeled as managed by the provisioner
+	// in each phase. Claims the controller never synced are not labeled and are not counted.
+	Bound    int
+	Pending  int
+	Failed   int
+	Released int
+	// Queued is the number of keys which remained queued and were not processed
+	Queued int
+}
+
+func NewController(provisionerName string, provisioner api.Provisioner, clientset kubernetes.Interface, crdClientSet versioned.Interface, obcInformer informers.ObjectBucketClaimInformer, obInformer informers.ObjectBucketInformer, options ...Option) *obcController {
 	ctrl := &obcController{
 		clientset:    clientset,
 		libClientset: crdClientSet,
@@ -80,8 +260,37 @@ func NewController(provisionerName string, provisioner api.Provisioner, clientse
 		provisionerLabels: map[string]string{
 			provisionerLabelKey: labelValue(provisionerName),
 		},
-		provisionerName: provisionerName,
-		provisioner:     provisioner,
+		provisionerName:     provisionerName,
+		provisioner:         provisioner,
+		keyFunc:             cache.MetaNamespaceKeyFunc,
+		splitKey:            cache.SplitMetaNamespaceKey,
+		getBucketClass:      bucketClassGetter(crdClientSet),
+		progressInterval:    defaultProgressInterval,
+		classLimiter:        newClassLimiter(),
+		clock:               clock.RealClock{},
+		unsupportedReported: map[types.UID]bool{},
+		deletionFailures:    map[types.UID]int{},
+		forbiddenBackoff:    newForbiddenRateLimiter(),
+		classifyError:       DefaultErrorClassifier,
+		activeKeys:          map[int]string{},
+		createdByKey:        createdByLabelKey,
+		createdByValue:      createdByLabelValue,
+		orphanSelector:      labels.Everything(),
+	}
+	for _, option := range options {
+		option(ctrl)
+	}
+	if ctrl.recorder == nil {
+		ctrl.recorder = newEventRecorder(clientset, provisionerName)
+	}
+	if ctrl.provisionerVersion != "" {
+		provisionerInfo.WithLabelValues(ctrl.provisionerVersion).Set(1)
+	}
+	if ctrl.reconcileChildren {
+		ctrl.watchChildren()
+	}
+	if ctrl.watchStorageClasses {
+		ctrl.watchClasses()
 	}
 
 	obcInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -95,8 +304,16 @@ func NewController(provisionerName string, provisioner api.Provisioner, clientse
 			}
 			// if old and new both have deletionTimestamps we can also ignore the
 			// update since these events are occurring on an obc marked for deletion,
-			// eg. extra finalizers being added and deleted.
-			if newObc.ObjectMeta.DeletionTimestamp != nil && oldObc.ObjectMeta.DeletionTimestamp != nil {
+			// eg. extra finalizers being added and deleted. The removal of the library's
+			// finalizer by someone else is handled, as the obc may then vanish before it is
+			// cleaned up.
+			if newObc.ObjectMeta.DeletionTimestamp != nil && oldObc.ObjectMeta.DeletionTimestamp != nil &&
+				(hasFinalizer(newObc) || !hasFinalizer(oldObc)) {
+				return
+			}
+
+			if reconcileRequested(oldObc, newObc) {
+				ctrl.enqueueOBCNow(new)
 				return
 			}
 
@@ -107,62 +324,742 @@ func NewController(provisionerName string, provisioner api.Provisioner, clientse
 			// handle this update
 			ctrl.enqueueOBC(new)
 		},
-		DeleteFunc: func(obj interface{}) {
-			// Since a finalizer is added to the obc and thus the obc will remain
-			// visible, we do not need to handle delete events here. Instead, obc
-			// deletes are indicated by the deletionTimestamp being non-nil.
-			return
-		},
+		// Since a finalizer is added to the obc and thus the obc will remain
+		// visible, we do not need to handle delete events here. Instead, obc
+		// deletes are indicated by the deletionTimestamp being non-nil. Only
+		// claims of other provisioners, which have no finalizer, and claims
+		// whose finalizer was removed by others are forgotten.
+		DeleteFunc: ctrl.forgetDeletedClaim,
 	})
 	return ctrl
 }
 
 func (c *obcController) Start(stopCh <-chan struct{}) error {
 	defer utilruntime.HandleCrash()
+	c.stopCh = stopCh
 	defer c.queue.ShutDown()
+	if c.priorityQueue != nil {
+		defer c.priorityQueue.ShutDown()
+	}
+	if err := c.checkControllerVersion(); err != nil {
+		return err
+	}
+	if handler, ok := c.lifecycleHandler(); ok {
+		if err := c.initProvisioner(handler, stopCh); err != nil {
+			return err
+		}
+		// deferred so that it runs once the workers finished the keys they were processing
+		defer c.shutdownProvisioner(handler)
+	}
+	if c.metricsBindAddress != "" {
+		_, shutdownMetrics, err := serveMetrics(c.metricsBindAddress, c.debugHandlers, c.ActiveWorkers, stopCh)
+		if err != nil {
+			return err
+		}
+		// stopCh is left open when Start fails, so the server is shut down on any return
+		defer shutdownMetrics()
+	}
+	if c.preflight {
+		if err := c.runPreflight(stopCh); err != nil {
+			return err
+		}
+	}
+	if c.childInformerFactory != nil {
+		c.childInformerFactory.Start(stopCh)
+	}
+	if c.classInformerFactory != nil {
+		c.classInformerFactory.Start(stopCh)
+	}
 
-	if !cache.WaitForCacheSync(stopCh, c.obcHasSynced, c.obHasSynced) {
-		return fmt.Errorf("failed to wait for caches to sync ")
+	hasSynced := append([]cache.InformerSynced{c.obcHasSynced, c.obHasSynced}, c.childrenHaveSynced...)
+	if c.classHasSynced != nil {
+		hasSynced = append(hasSynced, c.classHasSynced)
+	}
+	if err := c.waitForCacheSync(stopCh, hasSynced...); err != nil {
+		return err
+	}
+	if c.reconcileOrphansOnStartup {
+		c.reconcileOrphanedBuckets()
 	}
 	count := 1
 	if threadiness, set := os.LookupEnv("LIB_BUCKET_PROVISIONER_THREADS"); set {
 		count, _ = strconv.Atoi(threadiness)
 	}
+	c.workers.Add(count)
 	for i := 0; i < count; i++ {
-		go wait.Until(c.runWorker, time.Second, stopCh)
+		go func(worker int) {
+			defer c.workers.Done()
+			wait.Until(func() { c.runWorker(worker) }, time.Second, stopCh)
+		}(i)
+	}
+	if c.stuckPendingInterval > 0 {
+		go wait.Until(c.checkStuckPendingClaims, c.stuckPendingInterval, stopCh)
+	}
+	if c.bucketCheckInterval > 0 {
+		go wait.Until(c.checkBoundBuckets, c.bucketCheckInterval, stopCh)
+	}
+	if c.unboundResyncInterval > 0 {
+		go wait.Until(c.resyncUnboundClaims, c.unboundResyncInterval, stopCh)
 	}
 	<-stopCh
+	c.reportShutdownSummary()
+	// the workers only finish the keys they are processing, leaving those still queued to the
+	// next leader rather than provisioning or deleting buckets for them after stopping
+	c.queue.ShutDown()
+	if c.priorityQueue != nil {
+		c.priorityQueue.ShutDown()
+	}
+	c.workers.Wait()
+	return nil
+}
+
+// lifecycleHandler returns the provisioner if it implements api.LifecycleHandler.
+func (c *obcController) lifecycleHandler() (api.LifecycleHandler, bool) {
+	handler, ok := c.currentProvisioner().(api.LifecycleHandler)
+	return handler, ok
+}
+
+// initProvisioner calls the provisioner's Init, with a context canceled when the controller is
+// stopped.
+func (c *obcController) initProvisioner(handler api.LifecycleHandler, stopCh <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := handler.Init(ctx); err != nil {
+		log.Error(err, "provisioner initialization failed")
+		return fmt.Errorf("provisioner initialization failed: %v", err)
+	}
+	log.Info("provisioner initialized")
+	return nil
+}
+
+// shutdownProvisioner calls the provisioner's Shutdown. An error is logged, since the controller
+// is stopping anyway.
+func (c *obcController) shutdownProvisioner(handler api.LifecycleHandler) {
+	if err := handler.Shutdown(context.Background()); err != nil {
+		log.Error(err, "provisioner shutdown failed")
+		return
+	}
+	log.Info("provisioner shut down")
+}
+
+// reportShutdownSummary logs the phases of the managed claims, counted from the lister's cache,
+// and the number of keys left queued, and passes them to the shutdownSummary callback if set.
+func (c *obcController) reportShutdownSummary() {
+	summary := ShutdownSummary{Queued: c.queue.Len()}
+	if c.priorityQueue != nil {
+		summary.Queued += c.priorityQueue.Len()
+	}
+	selector := labels.SelectorFromSet(labels.Set{provisionerLabelKey: labelValue(c.provisionerName)})
+	obcs, err := c.obcLister.List(selector)
+	if err != nil {
+		log.Error(err, "error listing claims for the shutdown summary")
+	}
+	for _, obc := range obcs {
+		switch obc.Status.Phase {
+		case v1alpha1.ObjectBucketClaimStatusPhaseBound:
+			summary.Bound++
+		case v1alpha1.ObjectBucketClaimStatusPhasePending:
+			summary.Pending++
+		case v1alpha1.ObjectBucketClaimStatusPhaseFailed:
+			summary.Failed++
+		case v1alpha1.ObjectBucketClaimStatusPhaseReleased:
+			summary.Released++
+		}
+	}
+	log.Info("controller stopped", "bound", summary.Bound, "pending", summary.Pending, "failed", summary.Failed,
+		"released", summary.Released, "queued", summary.Queued)
+	if c.shutdownSummary != nil {
+		c.shutdownSummary(summary)
+	}
+}
+
+// checkControllerVersion returns an error if the provisioner, if it implements api.VersionRequirer,
+// requires a newer controller than api.ControllerVersion.
+func (c *obcController) checkControllerVersion() error {
+	requirer, ok := c.currentProvisioner().(api.VersionRequirer)
+	if !ok {
+		return nil
+	}
+	required, err := version.ParseSemantic(requirer.RequiredControllerVersion())
+	if err != nil {
+		return fmt.Errorf("provisioner requires an invalid controller version: %v", err)
+	}
+	if !version.MustParseSemantic(api.ControllerVersion).AtLeast(required) {
+		return fmt.Errorf("provisioner requires controller version %s, but this controller is version %s", required, api.ControllerVersion)
+	}
+	log.Info("provisioner is compatible with controller", "required", required.String(), "version", api.ControllerVersion)
+	return nil
+}
+
+// runPreflight calls the provisioner's Preflight, if it implements it, bounded by the preflight
+// timeout and canceled when stopCh is closed.
+func (c *obcController) runPreflight(stopCh <-chan struct{}) error {
+	preflighter, ok := c.currentProvisioner().(api.Preflighter)
+	if !ok {
+		log.Info("provisioner does not implement Preflight, skipping preflight")
+		return nil
+	}
+	stopCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go func() {
+		select {
+		case <-stopCh:
+			stop()
+		case <-stopCtx.Done():
+		}
+	}()
+	ctx := stopCtx
+	if c.preflightTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(stopCtx, c.preflightTimeout)
+		defer cancel()
+	}
+
+	if err := preflighter.Preflight(ctx); err != nil {
+		preflightSucceeded.Set(0)
+		log.Error(err, "provisioner preflight failed")
+		return fmt.Errorf("provisioner preflight failed: %v", err)
+	}
+	preflightSucceeded.Set(1)
+	log.Info("provisioner preflight succeeded")
 	return nil
 }
 
-// add provisioner-specific labels to the existing static label in the obcController struct.
+// waitForCacheSync waits for the informers' caches to sync, or until the stop channel is closed.
+// With a cache sync timeout, each attempt is bounded by the timeout, which doubles after each
+// failed attempt, and an error is returned once the retries are exhausted. The informers keep
+// listing with their own backoff meanwhile, so a brief API server outage is waited out while an
+// unreachable API server eventually fails Start.
+func (c *obcController) waitForCacheSync(stopCh <-chan struct{}, hasSynced ...cache.InformerSynced) error {
+	if c.cacheSyncTimeout == 0 {
+		if !cache.WaitForCacheSync(stopCh, hasSynced...) {
+			return fmt.Errorf("failed to wait for caches to sync ")
+		}
+		return nil
+	}
+	timeout := c.cacheSyncTimeout
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		go func() {
+			select {
+			case <-stopCh:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		synced := cache.WaitForCacheSync(ctx.Done(), hasSynced...)
+		cancel()
+		if synced {
+			return nil
+		}
+		select {
+		case <-stopCh:
+			return fmt.Errorf("stopped while waiting for caches to sync")
+		default:
+		}
+		if attempt >= c.cacheSyncRetries {
+			return fmt.Errorf("failed to wait for caches to sync after %d attempts", attempt+1)
+		}
+		log.Info("caches did not sync in time, retrying", "timeout", timeout, "attempt", attempt+1)
+		timeout *= 2
+	}
+}
+
+// add provisioner-specific labels to the existing static label in the obcController struct. If the
+// labels change, the managed claims are queued so that their existing OBs, secrets and configmaps
+// are relabeled.
 func (c *obcController) SetLabels(labels map[string]string) {
+	c.labelsLock.Lock()
+	changed := len(changedEntries(c.provisionerLabels, labels)) > 0
 	for k, v := range labels {
 		c.provisionerLabels[k] = v
 	}
+	c.labelsLock.Unlock()
+	if changed {
+		c.enqueueManagedClaims()
+	}
+}
+
+// enqueueManagedClaims queues the claims labeled as managed by the provisioner. Claims are only
+// listed once the informer has started, so nothing is queued for labels set before Start, which
+// are applied as the claims are first synced.
+func (c *obcController) enqueueManagedClaims() {
+	logger := callerLogger()
+	selector := labels.SelectorFromSet(labels.Set{provisionerLabelKey: labelValue(c.provisionerName)})
+	obcs, err := c.obcLister.List(selector)
+	if err != nil {
+		logger.Error(err, "error listing claims")
+		return
+	}
+	for _, obc := range obcs {
+		key, err := c.keyFunc(obc)
+		if err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+		c.queue.Add(key)
+	}
+	logger.V(1).Info("requeued managed claims to apply labels", "count", len(obcs))
+}
+
+// SetProvisioner replaces the provisioner. It does not wait for in-flight syncs, which finish with
+// the provisioner they read when they started; syncs started afterwards use the new provisioner.
+func (c *obcController) SetProvisioner(p api.Provisioner) {
+	c.provisionerLock.Lock()
+	defer c.provisionerLock.Unlock()
+	c.provisioner = p
+}
+
+// currentProvisioner returns the provisioner, which SetProvisioner may replace concurrently.
+func (c *obcController) currentProvisioner() api.Provisioner {
+	c.provisionerLock.RLock()
+	defer c.provisionerLock.RUnlock()
+	return c.provisioner
+}
+
+// DeleteAllManaged deletes every OBC labeled as managed by the provisioner, in the controller's
+// namespace or in all namespaces. Each OBC is cleaned up by the controller as if it were deleted by
+// a user, according to its reclaim policy; this method does not wait for the cleanup. Deleting the
+// remaining OBCs continues when one cannot be deleted, and the errors are returned together.
+func (c *obcController) DeleteAllManaged(ctx context.Context) error {
+	if !c.bulkDeletion {
+		return fmt.Errorf("deleting all managed OBCs is not enabled, see WithBulkDeletion")
+	}
+	selector := provisionerLabelKey + "=" + labelValue(c.provisionerName)
+	obcs, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(c.namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return fmt.Errorf("error listing managed OBCs: %v", err)
+	}
+	log.Info("deleting all managed OBCs", "count", len(obcs.Items))
+
+	var errs []error
+	for i, obc := range obcs.Items {
+		if err = ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		key := namespacedKey(obc.Namespace, obc.Name)
+		if obc.DeletionTimestamp == nil {
+			err = c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Delete(ctx, obc.Name, metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				log.Error(err, "error deleting OBC", "obc", key)
+				errs = append(errs, fmt.Errorf("error deleting OBC %q: %v", key, err))
+				continue
+			}
+		}
+		log.Info("deleted OBC", "obc", key, "progress", fmt.Sprintf("%d/%d", i+1, len(obcs.Items)))
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// labels returns a copy of the provisioner labels. The copy is safe to use and pass to helpers
+// while SetLabels is concurrently modifying the controller's labels.
+func (c *obcController) labels() map[string]string {
+	c.labelsLock.RLock()
+	defer c.labelsLock.RUnlock()
+	labels := make(map[string]string, len(c.provisionerLabels))
+	for k, v := range c.provisionerLabels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// labelsForClaim returns a copy of the labels to add to the claim and its generated resources: the
+// provisioner labels or, for a claim opting out of their propagation, only the label identifying
+// the provisioner, which the controller needs to select the claims it manages.
+func (c *obcController) labelsForClaim(obc *v1alpha1.ObjectBucketClaim) map[string]string {
+	labels := c.labels()
+	if skipLabelPropagation(obc) {
+		return map[string]string{provisionerLabelKey: labels[provisionerLabelKey]}
+	}
+	return labels
+}
+
+// updateClaimWith applies mutate to a copy of the claim and updates it. When the update conflicts
+// with a concurrent update of the claim, the claim is read again and mutate is applied to the
+// latest version, up to conflictRetries times. The claim is not updated if mutate leaves it
+// unchanged. The input obc is returned on error.
+//
+// The update carries the resourceVersion of obc, so it is rejected if the claim changed since the
+// reconcile read it. A conflicting update is only retried if the claim's spec is unchanged, eg.
+// when only its metadata was edited: the change being written was decided from the spec the
+// reconcile read, and writing it over a newer spec would silently override the user's edit. The
+// conflict is returned instead, so that the claim is requeued and reconciled against its new spec.
+func (c *obcController) updateClaimWith(obc *v1alpha1.ObjectBucketClaim, mutate func(*v1alpha1.ObjectBucketClaim)) (*v1alpha1.ObjectBucketClaim, error) {
+	updateOBC := obc.DeepCopy()
+	mutate(updateOBC)
+	if apiequality.Semantic.DeepEqual(updateOBC, obc) {
+		// nothing to write, eg. when a bound claim is synced again
+		return obc, nil
+	}
+	result, err := updateClaim(c.libClientset, updateOBC)
+	for i := 0; i < c.conflictRetries && errors.IsConflict(err); i++ {
+		logD.Info("conflict updating OBC, retrying with the latest version", "attempt", i+1)
+		current, getErr := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+		if getErr != nil {
+			return obc, fmt.Errorf("failed to get OBC %s/%s after an update conflict: %v", obc.Namespace, obc.Name, getErr)
+		}
+		if !apiequality.Semantic.DeepEqual(current.Spec, obc.Spec) {
+			log.Info("OBC spec changed during the reconcile, requeuing rather than overwriting it")
+			return obc, err
+		}
+		mutate(current)
+		result, err = updateClaim(c.libClientset, current)
+	}
+	if err != nil {
+		return obc, err
+	}
+	return result, nil
+}
+
+// updateClaimPhase updates the claim's status with the given phase. When the update conflicts with
+// a concurrent update of the claim, the status, which only the controller writes, is set on the
+// latest version of the claim, up to conflictRetries times. The input obc is returned on error.
+func (c *obcController) updateClaimPhase(obc *v1alpha1.ObjectBucketClaim, phase v1alpha1.ObjectBucketClaimStatusPhase) (*v1alpha1.ObjectBucketClaim, error) {
+	result, err := updateObjectBucketClaimPhase(c.libClientset, obc, phase)
+	for i := 0; i < c.conflictRetries && errors.IsConflict(err); i++ {
+		logD.Info("conflict updating OBC status, retrying with the latest version", "attempt", i+1)
+		current, getErr := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+		if getErr != nil {
+			return obc, fmt.Errorf("failed to get OBC %s/%s after a status update conflict: %v", obc.Namespace, obc.Name, getErr)
+		}
+		obc.Status.DeepCopyInto(&current.Status)
+		result, err = updateObjectBucketClaimPhase(c.libClientset, current, phase)
+	}
+	if err != nil {
+		return obc, err
+	}
+	return result, nil
+}
+
+// updateBucketPhase updates the OB's status with the given phase, retrying conflicting updates
+// like updateClaimPhase. The input ob is returned on error.
+func (c *obcController) updateBucketPhase(ob *v1alpha1.ObjectBucket, phase v1alpha1.ObjectBucketStatusPhase) (*v1alpha1.ObjectBucket, error) {
+	result, err := updateObjectBucketPhase(c.libClientset, ob, phase)
+	for i := 0; i < c.conflictRetries && errors.IsConflict(err); i++ {
+		logD.Info("conflict updating OB status, retrying with the latest version", "attempt", i+1)
+		current, getErr := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), ob.Name, metav1.GetOptions{})
+		if getErr != nil {
+			return ob, fmt.Errorf("failed to get OB %s after a status update conflict: %v", ob.Name, getErr)
+		}
+		ob.Status.DeepCopyInto(&current.Status)
+		result, err = updateObjectBucketPhase(c.libClientset, current, phase)
+	}
+	if err != nil {
+		return ob, err
+	}
+	return result, nil
+}
+
+// annotations returns the annotations added to the OBC and all resources generated for it.
+func (c *obcController) annotations() map[string]string {
+	if c.provisionerVersion == "" {
+		return nil
+	}
+	return map[string]string{provisionerVersionAnnotation: c.provisionerVersion}
 }
 
 func (c *obcController) enqueueOBC(obj interface{}) {
 	var key string
 	var err error
-	if key, err = cache.MetaNamespaceKeyFunc(obj); err != nil {
+	if key, err = c.keyFunc(obj); err != nil {
 		utilruntime.HandleError(err)
 		return
 	}
+	if obc, ok := obj.(*v1alpha1.ObjectBucketClaim); ok && c.priorityQueue != nil && obc.DeletionTimestamp != nil {
+		c.addPriority(key)
+		return
+	}
+	if c.maxQueueDepth > 0 && c.queue.Len() >= c.maxQueueDepth {
+		queueDeferralsTotal.Inc()
+		c.queue.AddAfter(key, c.queueDeferral)
+		return
+	}
+	if c.eventCoalescing > 0 {
+		// a key which is already waiting keeps its earlier deadline, so the events which arrive
+		// meanwhile are merged into it
+		c.queue.AddAfter(key, wait.Jitter(c.eventCoalescing, 0.5))
+		return
+	}
 	c.queue.AddRateLimited(key)
 }
 
-func (c *obcController) runWorker() {
-	for c.processNextItemInQueue() {
+// enqueueOBCNow queues the claim for an immediate reconcile, resetting the rate limiter's backoff of
+// its previous failures.
+func (c *obcController) enqueueOBCNow(obj interface{}) {
+	key, err := c.keyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	log.Info("reconcile requested, queuing claim immediately", "key", key)
+	c.queue.Forget(key)
+	c.queue.Add(key)
+}
+
+// watchChildren requeues the owning OBC when a generated configmap or secret is changed, so that
+// the change is reverted.
+func (c *obcController) watchChildren() {
+	selector := provisionerLabelKey + "=" + labelValue(c.provisionerName)
+	namespace := c.namespace
+	if c.resourceNamespace != "" {
+		namespace = c.resourceNamespace
+	}
+	c.childInformerFactory = kubeinformers.NewSharedInformerFactoryWithOptions(
+		c.clientset,
+		0,
+		kubeinformers.WithNamespace(namespace),
+		kubeinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = selector
+		}))
+
+	handler := cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, new interface{}) {
+			if old.(metav1.Object).GetResourceVersion() == new.(metav1.Object).GetResourceVersion() {
+				// periodic re-sync can be ignored
+				return
+			}
+			c.enqueueOwner(new)
+		},
+	}
+	for _, informer := range []cache.SharedIndexInformer{
+		c.childInformerFactory.Core().V1().Secrets().Informer(),
+		c.childInformerFactory.Core().V1().ConfigMaps().Informer(),
+	} {
+		informer.AddEventHandler(handler)
+		c.childrenHaveSynced = append(c.childrenHaveSynced, informer.HasSynced)
+	}
+}
+
+// watchClasses requeues the bound OBCs of a storage class when the class is changed or recreated,
+// so that its new parameters are passed to the provisioner. Storage class parameters are immutable,
+// so a class is usually changed by deleting and recreating it.
+func (c *obcController) watchClasses() {
+	c.classInformerFactory = kubeinformers.NewSharedInformerFactory(c.clientset, 0)
+	informer := c.classInformerFactory.Storage().V1().StorageClasses().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueClaimsOfClass,
+		UpdateFunc: func(old, new interface{}) {
+			oldClass := old.(*storagev1.StorageClass)
+			newClass := new.(*storagev1.StorageClass)
+			if oldClass.ResourceVersion == newClass.ResourceVersion {
+				// periodic re-sync can be ignored
+				return
+			}
+			if reflect.DeepEqual(oldClass.Parameters, newClass.Parameters) &&
+				reflect.DeepEqual(oldClass.ReclaimPolicy, newClass.ReclaimPolicy) {
+				return
+			}
+			c.enqueueClaimsOfClass(new)
+		},
+	})
+	c.classHasSynced = informer.HasSynced
+}
+
+// enqueueClaimsOfClass enqueues the bound OBCs of the given storage class, if it is handled by
+// this provisioner. Unbound OBCs are already retried until they are provisioned.
+func (c *obcController) enqueueClaimsOfClass(obj interface{}) {
+	class, ok := obj.(*storagev1.StorageClass)
+	if !ok || !c.supportedProvisioner(class.Provisioner) {
+		return
+	}
+	obcs, err := c.obcLister.List(labels.Everything())
+	if err != nil {
+		log.Error(err, "error listing OBCs of storage class", "class", class.Name)
+		return
+	}
+	for _, obc := range obcs {
+		if obc.Spec.StorageClassName == class.Name && obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseBound {
+			c.enqueueOBC(obc)
+		}
+	}
+}
+
+// enqueueOwner enqueues the OBC which controls the given object, if any.
+func (c *obcController) enqueueOwner(obj interface{}) {
+	child, ok := obj.(metav1.Object)
+	if !ok {
+		return
+	}
+	namespace, name := child.GetNamespace(), ""
+	if owner := metav1.GetControllerOf(child); owner != nil && owner.Kind == v1alpha1.ObjectBucketClaimKind {
+		name = owner.Name
+	} else if key, ok := child.GetAnnotations()[claimAnnotation]; ok {
+		// the child is in another resource namespace than its claim
+		var err error
+		if namespace, name, err = cache.SplitMetaNamespaceKey(key); err != nil {
+			utilruntime.HandleError(err)
+			return
+		}
+	} else {
+		return
+	}
+	obc, err := c.obcLister.ObjectBucketClaims(namespace).Get(name)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			utilruntime.HandleError(err)
+		}
+		return
+	}
+	c.enqueueOBC(obc)
+}
+
+// wakeToken is added to queue to wake a worker waiting on it when a key is added to
+// priorityQueue.
+type wakeToken struct{}
+
+// addPriority adds the key to priorityQueue and wakes a worker to take it.
+func (c *obcController) addPriority(key interface{}) {
+	c.priorityQueue.Add(key)
+	// a worker blocked waiting on queue would not notice the key
+	c.queue.Add(wakeToken{})
+}
+
+// requeuer puts keys which failed to sync back on the queue they were taken from.
+type requeuer interface {
+	AddRateLimited(item interface{})
+	AddAfter(item interface{}, duration time.Duration)
+	Forget(item interface{})
+}
+
+// priorityRequeuer requeues keys taken from priorityQueue, which is not rate limited itself.
+type priorityRequeuer struct {
+	c *obcController
+}
+
+func (p priorityRequeuer) AddRateLimited(item interface{}) {
+	p.AddAfter(item, p.c.priorityLimiter.When(item))
+}
+
+func (p priorityRequeuer) AddAfter(item interface{}, duration time.Duration) {
+	time.AfterFunc(duration, func() { p.c.addPriority(item) })
+}
+
+func (p priorityRequeuer) Forget(item interface{}) {
+	p.c.priorityLimiter.Forget(item)
+}
+
+// nextPriorityItem takes the next key from priorityQueue without blocking, returning false if
+// there is none.
+func (c *obcController) nextPriorityItem() (interface{}, bool) {
+	if c.priorityQueue == nil {
+		return nil, false
+	}
+	c.priorityLock.Lock()
+	defer c.priorityLock.Unlock()
+	// as long as workers only take keys while holding the lock, Get does not block when Len > 0
+	if c.priorityQueue.Len() == 0 {
+		return nil, false
+	}
+	obj, shutdown := c.priorityQueue.Get()
+	return obj, !shutdown
+}
+
+// startProcessing marks the key as being processed, returning false if it is already being
+// processed by another worker.
+func (c *obcController) startProcessing(key string) bool {
+	if c.priorityQueue == nil {
+		return true
+	}
+	c.priorityLock.Lock()
+	defer c.priorityLock.Unlock()
+	if c.inFlight[key] {
+		return false
+	}
+	c.inFlight[key] = true
+	return true
+}
+
+func (c *obcController) finishProcessing(key string) {
+	if c.priorityQueue == nil {
+		return
 	}
+	c.priorityLock.Lock()
+	defer c.priorityLock.Unlock()
+	delete(c.inFlight, key)
 }
 
-func (c *obcController) processNextItemInQueue() bool {
+func (c *obcController) runWorker(worker int) {
+	for c.processNextItemInQueue(worker) {
+	}
+}
+
+// processNextItemInQueue processes the next item on behalf of the given worker, returning false
+// once the queue is shut down.
+func (c *obcController) processNextItemInQueue(worker int) bool {
+	if c.isStopping() {
+		return false
+	}
+	if obj, ok := c.nextPriorityItem(); ok {
+		if c.isStopping() {
+			c.priorityQueue.Done(obj)
+			return false
+		}
+		c.processItem(worker, c.priorityQueue, priorityRequeuer{c}, obj)
+		return true
+	}
+
 	obj, shutdown := c.queue.Get()
 	if shutdown {
 		return false
 	}
+	if c.isStopping() {
+		c.queue.Done(obj)
+		return false
+	}
+	if _, ok := obj.(wakeToken); ok {
+		// the token only wakes the worker so that it checks priorityQueue
+		c.queue.Forget(obj)
+		c.queue.Done(obj)
+		return true
+	}
+	c.processItem(worker, c.queue, c.queue, obj)
+	return true
+}
+
+// isStopping reports whether Start was stopped, in which case workers take no further keys.
+func (c *obcController) isStopping() bool {
+	select {
+	case <-c.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// ActiveWorkers returns the key of the claim each busy worker is processing, by worker ID, eg. to
+// find a worker wedged on a claim. Idle workers are omitted.
+func (c *obcController) ActiveWorkers() map[int]string {
+	c.activeLock.Lock()
+	defer c.activeLock.Unlock()
+	active := make(map[int]string, len(c.activeKeys))
+	for worker, key := range c.activeKeys {
+		active[worker] = key
+	}
+	return active
+}
+
+// setActiveKey records the key the worker is processing, or that it is idle if key is empty.
+func (c *obcController) setActiveKey(worker int, key string) {
+	c.activeLock.Lock()
+	defer c.activeLock.Unlock()
+	if key == "" {
+		delete(c.activeKeys, worker)
+		return
+	}
+	c.activeKeys[worker] = key
+}
 
+// processItem syncs an item taken from the given queue, which is either queue or priorityQueue,
+// putting it back on the same queue through requeue if it fails.
+func (c *obcController) processItem(worker int, queue workqueue.Interface, requeue requeuer, obj interface{}) {
 	// We wrap this block in a func so we can defer c.workqueue.Done.
 	err := func(obj interface{}) error {
 		// We call Done here so the workqueue knows we have finished
@@ -171,7 +1068,7 @@ func (c *obcController) processNextItemInQueue() bool {
 		// not call Forget if a transient error occurs, instead the item is
 		// put back on the workqueue and attempted again after a back-off
 		// period.
-		defer c.queue.Done(obj)
+		defer queue.Done(obj)
 		var key string
 		var ok bool
 		// We expect strings to come off the workqueue. These are of the
@@ -183,40 +1080,369 @@ func (c *obcController) processNextItemInQueue() bool {
 			// As the item in the workqueue is actually invalid, we call
 			// Forget here else we'd go into a loop of attempting to
 			// process a work item that is invalid.
-			c.queue.Forget(obj)
+			requeue.Forget(obj)
+			recordReconcile(outcomeFailedTerminal)
 			utilruntime.HandleError(fmt.Errorf("expected string in workqueue but got %#v", obj))
 			return nil
 		}
+		if !c.startProcessing(key) {
+			// Another worker is processing the key taken from the other queue. Try again later.
+			requeue.AddRateLimited(key)
+			return nil
+		}
+		defer c.finishProcessing(key)
+		c.setActiveKey(worker, key)
+		defer c.setActiveKey(worker, "")
 		// Run the syncHandler, passing it the namespace/name string of the
 		// Foo resource to be synced.
-		if err := c.syncHandler(key); err != nil {
-			// Put the item back on the workqueue to handle any transient errors.
-			c.queue.AddRateLimited(key)
-			return fmt.Errorf("error syncing '%s': %s, requeuing", key, err.Error())
+		outcome, err := c.syncHandler(key)
+		if ferr, ok := asForbiddenError(err); ok {
+			after := c.forbiddenBackoff.When(key)
+			requeue.AddAfter(key, after)
+			recordReconcile(outcomeRequeuedTransient)
+			return fmt.Errorf("error syncing '%s': %s, requeuing after %v", key, ferr.Error(), after)
+		}
+		c.forbiddenBackoff.Forget(key)
+		if err != nil {
+			switch decision := c.classifyError(err); decision.Action {
+			case RequeueActionAfter:
+				// the delay is known, eg. by the provisioner, so the rate limiter's backoff is
+				// reset rather than increased
+				log.Info("requeuing claim after delay", "key", key, "requeueAfter", decision.After, "reason", err.Error())
+				requeue.Forget(obj)
+				requeue.AddAfter(key, decision.After)
+				recordReconcile(outcomeRequeuedTransient)
+				return nil
+			case RequeueActionForget:
+				requeue.Forget(obj)
+				recordReconcile(outcomeFailedTerminal)
+				return fmt.Errorf("error syncing '%s': %s, not requeuing", key, err.Error())
+			default:
+				// Put the item back on the workqueue to handle any transient errors.
+				c.requeueFailed(requeue, key)
+				recordReconcile(outcomeRequeuedTransient)
+				return fmt.Errorf("error syncing '%s': %s, requeuing", key, err.Error())
+			}
 		}
 		// Finally, if no error occurs we Forget this item so it does not
 		// get queued again until another change happens.
-		c.queue.Forget(obj)
+		requeue.Forget(obj)
+		recordReconcile(outcome)
 		return nil
 	}(obj)
 
 	if err != nil {
 		utilruntime.HandleError(err)
 	}
-	return true
 }
 
-// Reconcile implements the Reconciler interface. This function contains the business logic
-// of the OBC obcController.
-// Note: the obc obtained from the key is not expected to be nil. In other words, this func is
-// not called when informers detect an object is missing and trigger a formal delete event.
-// Instead, delete is indicated by the deletionTimestamp being non-nil on an update event.
-func (c *obcController) syncHandler(key string) error {
-
-	setLoggersWithRequest(key)
-	logD.Info("reconciling claim")
-
-	obc, err := claimForKey(key, c.libClientset)
+// requeueFailed requeues a key which failed to sync through requeue. Claims which have failed to become bound
+// for longer than stuckClaimAge are requeued after the long stuckClaimBackoff, so that they do
+// not take capacity from healthy claims, and are marked as backed off.
+func (c *obcController) requeueFailed(requeue requeuer, queueKey string) {
+	if c.stuckClaimAge == 0 {
+		requeue.AddRateLimited(queueKey)
+		return
+	}
+	namespace, name, err := c.splitKey(queueKey)
+	if err != nil {
+		requeue.AddRateLimited(queueKey)
+		return
+	}
+	obc, err := claimForKey(namespacedKey(namespace, name), c.libClientset)
+	if err != nil || obc.ObjectMeta.DeletionTimestamp != nil || obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseBound || c.clock.Since(obc.CreationTimestamp.Time) < c.stuckClaimAge {
+		requeue.AddRateLimited(queueKey)
+		return
+	}
+
+	log.Info("claim has failed for too long, backing off", "age", c.clock.Since(obc.CreationTimestamp.Time).Round(time.Second), "requeueAfter", c.stuckClaimBackoff)
+	requeue.AddAfter(queueKey, c.stuckClaimBackoff)
+	if !meta.IsStatusConditionTrue(obc.Status.Conditions, v1alpha1.ObjectBucketClaimConditionBackedOff) {
+		setBackedOffCondition(obc, c.stuckClaimBackoff, c.clock.Now())
+		if _, err = c.updateClaimPhase(obc, obc.Status.Phase); err != nil {
+			log.Error(err, "failed to set condition", "type", v1alpha1.ObjectBucketClaimConditionBackedOff)
+		}
+	}
+}
+
+// checkStuckPendingClaims finds claims of this provisioner which have been Pending for longer than
+// stuckPendingAge without an OB, eg. because the controller crashed while provisioning them. Each
+// is reported by a warning event and requeued, and their number is exported as the
+// obc_stuck_pending_claims metric.
+func (c *obcController) checkStuckPendingClaims() {
+	obcs, err := c.obcLister.List(labels.Everything())
+	if err != nil {
+		log.Error(err, "error listing claims")
+		return
+	}
+	stuck := 0
+	for _, obc := range obcs {
+		age := c.clock.Since(obc.CreationTimestamp.Time)
+		if obc.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhasePending || obc.DeletionTimestamp != nil || age < c.stuckPendingAge {
+			continue
+		}
+		obName, err := objectBucketNameFromClaimKey(namespacedKey(obc.Namespace, obc.Name))
+		if err != nil {
+			continue
+		}
+		if _, err = c.obLister.Get(obName); !errors.IsNotFound(err) {
+			continue
+		}
+		class, err := storageClassForClaim(c.clientset, obc)
+		if err != nil || !c.supportedProvisioner(class.Provisioner) {
+			continue
+		}
+		key, err := c.keyFunc(obc)
+		if err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+		stuck++
+		log.Info("claim stuck in Pending, requeuing", "obc", namespacedKey(obc.Namespace, obc.Name), "age", age.Round(time.Second))
+		c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonStuckPending,
+			"claim has been %s for %v without an ObjectBucket, requeuing", obc.Status.Phase, age.Round(time.Second))
+		c.queue.Add(key)
+	}
+	stuckPendingClaims.Set(float64(stuck))
+}
+
+// resyncUnboundClaims requeues the claims of this provisioner which are Pending or not yet phased,
+// so that a claim whose reconcile failed on a condition which has since cleared, eg. a missing
+// storage class which was created later, is reconciled again even though no event fired for it.
+// Claims whose storage class cannot be found are requeued too, as it may now be created. Failed
+// claims are not requeued, as they are retried from the beginning, which would provision and then
+// reject their bucket again on every resync.
+func (c *obcController) resyncUnboundClaims() {
+	obcs, err := c.obcLister.List(labels.Everything())
+	if err != nil {
+		log.Error(err, "error listing claims")
+		return
+	}
+	requeued := 0
+	for _, obc := range obcs {
+		if obc.Status.Phase != "" && obc.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhasePending {
+			continue
+		}
+		if class, err := storageClassForClaim(c.clientset, obc); err == nil && !c.supportedProvisioner(class.Provisioner) {
+			continue
+		}
+		key, err := c.keyFunc(obc)
+		if err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+		requeued++
+		c.queue.Add(key)
+	}
+	logD.Info("requeued claims which are not bound", "count", requeued)
+}
+
+// createdByController returns whether the OB carries the label marking OBs created by the
+// controller.
+func (c *obcController) createdByController(ob *v1alpha1.ObjectBucket) bool {
+	return ob.Labels[c.createdByKey] == c.createdByValue
+}
+
+// reconcileOrphanedBuckets cleans up the OBs of the provisioner whose claim no longer exists. The
+// bucket of each is deleted or access to it revoked according to its reclaim policy, then the OB
+// is deleted and the claim's secret and configmap released, as for a deleted claim. Errors are
+// logged, leaving the OB to be cleaned up on the next start.
+func (c *obcController) reconcileOrphanedBuckets() {
+	provisioner := c.currentProvisioner()
+
+	obs, err := c.obLister.List(c.orphanSelector)
+	if err != nil {
+		log.Error(err, "error listing OBs")
+		return
+	}
+	cleaned := 0
+	for _, ob := range obs {
+		ref := ob.Spec.ClaimRef
+		if ref == nil || ref.Name == "" || (c.namespace != "" && ref.Namespace != c.namespace) {
+			continue
+		}
+		obProvisioner := ob.Annotations[provisionerAnnotation]
+		if obProvisioner == "" {
+			class, err := c.clientset.StorageV1().StorageClasses().Get(context.TODO(), ob.Spec.StorageClassName, metav1.GetOptions{})
+			if err != nil {
+				log.Error(err, "error getting storage class of OB", "ob", ob.Name)
+				continue
+			}
+			obProvisioner = class.Provisioner
+		}
+		if !c.supportedProvisioner(obProvisioner) && !c.previousProvisioner(obProvisioner) {
+			continue
+		}
+		// the claim is looked up with the API server rather than the lister, since the bucket is
+		// lost if it is mistaken for an orphan
+		key := namespacedKey(ref.Namespace, ref.Name)
+		if _, err = claimForKey(key, c.libClientset); !errors.IsNotFound(err) {
+			if err != nil {
+				log.Error(err, "error getting claim of OB", "ob", ob.Name)
+			}
+			continue
+		}
+		log.Info("cleaning up orphaned OB", "ob", ob.Name, "obc", key)
+		outcome, err := c.cleanUpOrphanedBucket(key, ref, provisioner)
+		if err != nil {
+			log.Error(err, "error cleaning up orphaned OB", "ob", ob.Name)
+			continue
+		}
+		recordReconcile(outcome)
+		cleaned++
+	}
+	log.Info("cleaned up orphaned OBs", "count", cleaned)
+}
+
+// cleanUpOrphanedBucket deletes or revokes the bucket of the OB of the vanished claim with the
+// given key, unless it was deprovisioned already, and deletes the OB and the claim's resources.
+func (c *obcController) cleanUpOrphanedBucket(key string, ref *corev1.ObjectReference, provisioner api.Provisioner) (reconcileOutcome, error) {
+	ob, cm, secret, errs := c.getExistingResourcesFromKey(key)
+	if len(errs) > 0 {
+		return "", fmt.Errorf("error getting resources: %v", errs)
+	}
+	if ob == nil {
+		return "", fmt.Errorf("OB of claim %q vanished", key)
+	}
+	outcome := reconcileOutcome(ob.Annotations[deprovisionedAnnotation])
+	if outcome == "" {
+		timeout := c.deprovisionTimeout()
+		outcome = outcomeRevoked
+		deprovision := provisioner.Revoke
+		if effectiveReclaimPolicy(c.clientset, ob) == corev1.PersistentVolumeReclaimDelete {
+			outcome = outcomeDeleted
+			deprovision = provisioner.Delete
+		}
+		_, err := callWithTimeout(timeout, func() (*v1alpha1.ObjectBucket, error) {
+			return nil, deprovision(ob)
+		}, nil)
+		if err == errProvisionTimeout {
+			return "", fmt.Errorf("deprovisioning bucket did not complete within %v", timeout)
+		} else if err != nil {
+			return "", fmt.Errorf("provisioner error deprovisioning bucket: %v", err)
+		}
+		if ob, err = markDeprovisioned(c.libClientset, ob, outcome); err != nil {
+			log.Error(err, "backend may be called again if cleanup fails")
+		}
+	}
+	// the claim is identified by the OB's reference to it, to release the resources it owned
+	obc := &v1alpha1.ObjectBucketClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ref.Namespace, Name: ref.Name, UID: ref.UID},
+	}
+	if err := c.deleteResources(ob, cm, secret, obc); err != nil {
+		return "", err
+	}
+	return outcome, nil
+}
+
+// checkBoundBuckets asks the provisioner, if it implements api.BucketChecker, whether the buckets
+// of the bound claims of this provisioner still exist, at the rate allowed by bucketCheckLimiter.
+func (c *obcController) checkBoundBuckets() {
+	obcs, err := c.obcLister.List(labels.Everything())
+	if err != nil {
+		log.Error(err, "error listing claims")
+		return
+	}
+	for _, obc := range obcs {
+		if obc.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseBound || obc.DeletionTimestamp != nil {
+			continue
+		}
+		obName, err := objectBucketNameFromClaimKey(namespacedKey(obc.Namespace, obc.Name))
+		if err != nil {
+			continue
+		}
+		ob, err := c.obLister.Get(obName)
+		if err != nil {
+			continue
+		}
+		class, err := storageClassForClaim(c.clientset, obc)
+		if err != nil || !c.supportedProvisioner(class.Provisioner) {
+			continue
+		}
+		key, err := c.keyFunc(obc)
+		if err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+		c.bucketCheckLimiter.Accept()
+		c.checkBucket(key, obc.DeepCopy(), ob.DeepCopy())
+	}
+}
+
+// checkBucket asks the provisioner whether the bucket of a bound claim still exists. A missing
+// bucket is reported by a warning event and the claim's BucketMissing condition, which is cleared
+// once the bucket exists again. The claim of a missing new bucket is requeued to provision the
+// bucket again if reprovisionMissingBuckets is set, while the claim of an existing bucket is left
+// as it is, as access to it may have been deliberately removed.
+func (c *obcController) checkBucket(key string, obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBucket) {
+	checker, ok := c.currentProvisioner().(api.BucketChecker)
+	if !ok {
+		return
+	}
+	exists, err := checker.BucketExists(ob)
+	if err != nil {
+		log.Error(err, "error checking that bucket exists", "obc", key)
+		return
+	}
+	wasMissing := meta.IsStatusConditionTrue(obc.Status.Conditions, v1alpha1.ObjectBucketClaimConditionBucketMissing)
+	if exists {
+		if wasMissing {
+			clearBucketMissingCondition(obc, c.clock.Now())
+			if _, err = c.updateClaimPhase(obc, obc.Status.Phase); err != nil {
+				log.Error(err, "failed to clear condition", "type", v1alpha1.ObjectBucketClaimConditionBucketMissing)
+			}
+		}
+		return
+	}
+
+	message := fmt.Sprintf("bucket %q is missing from the object store", ob.Spec.Endpoint.BucketName)
+	reprovision := c.reprovisionMissingBuckets && isNewBucketByObjectBucket(c.clientset, ob)
+	if reprovision {
+		message += ", provisioning the bucket again"
+	}
+	log.Info("bucket of bound claim is missing", "obc", key, "bucket", ob.Spec.Endpoint.BucketName)
+	c.recorder.Event(obc, corev1.EventTypeWarning, reasonBucketMissing, message)
+	if !wasMissing {
+		setBucketMissingCondition(obc, message, c.clock.Now())
+		if _, err = c.updateClaimPhase(obc, obc.Status.Phase); err != nil {
+			log.Error(err, "failed to set condition", "type", v1alpha1.ObjectBucketClaimConditionBucketMissing)
+		}
+	}
+	if reprovision {
+		c.queue.Add(key)
+	}
+}
+
+// Reconcile implements the Reconciler interface. This function contains the business logic
+// of the OBC obcController.
+// Note: the obc obtained from the key is not expected to be nil. In other words, this func is
+// not called when informers detect an object is missing and trigger a formal delete event.
+// Instead, delete is indicated by the deletionTimestamp being non-nil on an update event.
+// The returned outcome describes the result of a successful sync. When an error is returned, the
+// key is requeued and the outcome is ignored.
+func (c *obcController) syncHandler(queueKey string) (reconcileOutcome, error) {
+
+	setLoggersWithRequest(queueKey)
+	logD.Info("reconciling claim")
+
+	// A custom key func may encode more than the namespace and name into the queue key. Resolve
+	// the canonical namespace/name key from which the names of all claim resources are derived.
+	namespace, name, err := c.splitKey(queueKey)
+	if err != nil {
+		// the key can never be parsed, so retrying would not help
+		utilruntime.HandleError(fmt.Errorf("invalid key %q: %v", queueKey, err))
+		return outcomeFailedTerminal, nil
+	}
+	return c.syncClaim(queueKey, namespacedKey(namespace, name))
+}
+
+// syncClaim syncs the claim with the given namespace/name key. The queueKey identifies the claim
+// in the workqueue, if any.
+func (c *obcController) syncClaim(queueKey, key string) (reconcileOutcome, error) {
+	// the provisioner is read once, so that it is not replaced part way through the sync
+	provisioner := c.currentProvisioner()
+
+	obc, err := claimForKey(key, c.libClientset)
 	if err != nil {
 		//      The OBC was deleted immediately after creation, before it could be processed by
 		//      handleProvisionClaim.  As a finalizer is immediately applied to the OBC before processing,
@@ -224,70 +1450,316 @@ func (c *obcController) syncHandler(key string) error {
 		//      Therefore, it is safe to assume nothing needs to be done.
 		if errors.IsNotFound(err) {
 			log.Info("OBC vanished, assuming it was deleted")
-			return nil
+			return outcomeSkippedNotFound, nil
 		}
-		return fmt.Errorf("could not sync OBC %s: %v", key, err)
+		return "", fmt.Errorf("could not sync OBC %s: %v", key, err)
 	}
 
 	class, err := storageClassForClaim(c.clientset, obc)
 	if err != nil {
-		return err
+		// the storage class may not have been created yet
+		if _, uerr := updateObjectBucketClaimProgress(c.libClientset, obc, reasonWaitingForStorageClass, err.Error()); uerr != nil {
+			log.Error(uerr, "error recording progress")
+		}
+		return "", err
 	}
-	if !c.supportedProvisioner(class.Provisioner) {
-		log.Info("unsupported provisioner", "got", class.Provisioner)
-		return nil
+	// A deleted claim is cleaned up by the provisioner recorded on its OB, which provisioned its
+	// bucket, even if the provisioner of the storage class was edited since.
+	classProvisioner := class.Provisioner
+	if obc.ObjectMeta.DeletionTimestamp != nil {
+		ob, err := getObFromKey(key, c.libClientset)
+		if err != nil {
+			return "", err
+		}
+		if ob != nil && ob.Annotations[provisionerAnnotation] != "" {
+			classProvisioner = ob.Annotations[provisionerAnnotation]
+		}
+	}
+	if !c.supportedProvisioner(classProvisioner) {
+		// claims of a provisioner's previous name are only cleaned up, so that they are not left
+		// behind when the provisioner is renamed
+		if obc.ObjectMeta.DeletionTimestamp == nil || !c.previousProvisioner(classProvisioner) {
+			log.Info("unsupported provisioner", "got", classProvisioner)
+			c.reportUnsupportedProvisioner(obc, class)
+			return outcomeSkippedUnsupported, nil
+		}
+		log.Info("cleaning up claim of previous provisioner name", "provisioner", classProvisioner)
+	}
+
+	// Record the default storage class in the claim so that the claim is unaffected by later
+	// changes to the default.
+	if obc.Spec.StorageClassName == "" && obc.ObjectMeta.DeletionTimestamp == nil {
+		obc, err = c.updateClaimWith(obc, func(obc *v1alpha1.ObjectBucketClaim) {
+			obc.Spec.StorageClassName = class.Name
+		})
+		if err != nil {
+			return "", fmt.Errorf("error updating OBC %q with default StorageClass: %v", key, err)
+		}
 	}
 
 	// ***********************
 	// Delete or Revoke Bucket
 	// ***********************
 	if obc.ObjectMeta.DeletionTimestamp != nil {
+		if !hasFinalizer(obc) {
+			// the finalizer was removed, eg. by a user, so the claim may vanish at any time and
+			// its resources are cleaned up on a best-effort basis. Without an OB, the claim was
+			// cleaned up before its finalizer was removed.
+			ob, err := getObFromKey(key, c.libClientset)
+			if err != nil {
+				return "", err
+			} else if ob == nil {
+				return outcomeSkippedNotFound, nil
+			}
+			log.Info("OBC deleted without finalizer, cleaning up before it vanishes")
+			c.recorder.Event(obc, corev1.EventTypeWarning, reasonFinalizerMissing,
+				"finalizer was removed before the claim was cleaned up, its bucket and resources may be left behind")
+		}
 		log.Info("OBC deleted, proceeding with cleanup")
-		return c.handleDeleteClaim(key, obc)
+		outcome, err := c.handleDeleteClaim(key, obc, provisioner)
+		if err != nil && c.namespaceDeletionRetriesExhausted(obc) {
+			outcome, err = c.abandonClaim(key, obc, err)
+		}
+		if err == nil {
+			c.forgetDeletionFailures(obc.UID)
+		}
+		return outcome, err
 	}
 
-	if obc.Status.Phase == "" {
-		// update the OBC's status to pending before any provisioning related errors can occur
-		obc, err = updateObjectBucketClaimPhase(
-			c.libClientset,
-			obc,
-			v1alpha1.ObjectBucketClaimStatusPhasePending)
+	// A failed claim is only requeued when its spec is edited (see updateSupported), or when the
+	// controller restarts, as the unbound resync skips it, so retry it from the beginning.
+	if obc.Status.Phase == "" || obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseFailed {
+		// update the OBC's status to pending before any provisioning related errors can occur. The
+		// progress recorded once the claim is validated is written along, saving a status update.
+		pending := obc.DeepCopy()
+		pending.Status.Reason, pending.Status.Message = reasonValidatingParameters, messageValidatingParameters
+		obc, err = c.updateClaimPhase(pending, v1alpha1.ObjectBucketClaimStatusPhasePending)
+		if err != nil {
+			return "", fmt.Errorf("error updating OBC status: %s", err)
+		}
+	}
+
+	// A bound claim whose OB has been deleted, eg. by an administrator, refers to a bucket which is
+	// no longer tracked. A new bucket is provisioned again under the claim's bucket name, while the
+	// claim of an existing bucket is failed, as access to it may have been deliberately removed.
+	if obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		ob, err := getObFromKey(key, c.libClientset)
 		if err != nil {
-			return fmt.Errorf("error updating OBC status: %s", err)
+			return "", err
+		}
+		if ob == nil {
+			missing := fmt.Errorf("ObjectBucket %q of the bound claim is missing", obc.Spec.ObjectBucketName)
+			if !isNewBucketByStorageClass(class) {
+				if err = c.failClaim(obc, &terminalError{reason: reasonObjectBucketMissing, err: missing}); err != nil {
+					return "", err
+				}
+				return outcomeFailedTerminal, nil
+			}
+			log.Info("provisioning bucket of claim again", "reason", missing.Error())
+			c.recorder.Event(obc, corev1.EventTypeWarning, reasonObjectBucketMissing, missing.Error()+", provisioning the bucket again")
+			obc, err = c.updateClaimPhase(obc, v1alpha1.ObjectBucketClaimStatusPhasePending)
+			if err != nil {
+				return "", fmt.Errorf("error updating OBC status: %s", err)
+			}
+		}
+	}
+
+	// The labels are backfilled before provisioning again, which may not update the resources, eg.
+	// when the provisioner fails or the update of the claim is rejected.
+	if obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		obc = c.backfillLabels(key, obc)
+		c.healConfigMap(key, obc, class, provisioner)
+	}
+
+	if validator, ok := provisioner.(api.AdditionalConfigValidator); ok {
+		if err := validateAdditionalConfig(obc.Spec.AdditionalConfig, validator.AdditionalConfigSchema()); err != nil {
+			switch {
+			case !c.strictAdditionalConfig:
+				c.recorder.Event(obc, corev1.EventTypeWarning, reasonInvalidAdditionalConfig, err.Error())
+			case obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseBound:
+				// the bucket is left as it is rather than failing a claim which is in use
+				log.Error(err, "rejecting additionalConfig of bound claim")
+				c.recorder.Event(obc, corev1.EventTypeWarning, reasonInvalidAdditionalConfig, err.Error())
+				return outcomeFailedTerminal, nil
+			default:
+				if err = c.failClaim(obc, &terminalError{reason: reasonInvalidAdditionalConfig, err: err}); err != nil {
+					return "", err
+				}
+				return outcomeFailedTerminal, nil
+			}
+		}
+	}
+
+	// A change to the additionalConfig of a bound claim is passed to provisioners supporting
+	// updates. Otherwise, the claim is provisioned again.
+	if obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		if updater, ok := provisioner.(api.Updater); ok {
+			ob, err := c.objectBucketForClaimKey(key)
+			if err != nil {
+				return "", fmt.Errorf("error getting OB for OBC %q: %v", key, err)
+			}
+			if additionalConfigChanged(ob, obc) {
+				return c.handleUpdateClaim(obc, ob, class, provisioner, updater)
+			}
 		}
 	}
 
 	// idempotent provisioner
-	err = c.handleProvisionClaim(key, obc, class)
+	// If the handler errors, the request will be re-queued unless the error is terminal, in which
+	// case the claim is marked Failed.
+	outcome, err := c.handleProvisionClaim(key, obc, class, provisioner)
+	if terr, ok := asTerminalError(err); ok {
+		if err = c.failClaim(obc, terr); err != nil {
+			return "", err
+		}
+		return outcomeFailedTerminal, nil
+	}
+	return outcome, err
+}
+
+// failUnlessBound returns a terminal error failing the claim for the given reason, unless the claim
+// is bound, eg. provisioned again after its storage class was recreated with an invalid parameter.
+// A bound claim is only given a warning event, leaving the bucket as it is rather than failing a
+// claim which is in use.
+func (c *obcController) failUnlessBound(obc *v1alpha1.ObjectBucketClaim, reason string, err error) (reconcileOutcome, error) {
+	if obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		log.Error(err, "not updating bound claim")
+		c.recorder.Event(obc, corev1.EventTypeWarning, reason, err.Error())
+		return outcomeFailedTerminal, nil
+	}
+	return "", newTerminalError(reason, err)
+}
+
+// abandonedBucketCleanup returns the cleanup of a bucket returned by a Provision or Grant call
+// which was abandoned after timing out, or nil for a bound claim, whose bucket is in use. The
+// bucket is deleted or its access revoked, unless the claim was bound by a retry in the meantime.
+// It runs in the background, after the reconcile which made the call has returned.
+func (c *obcController) abandonedBucketCleanup(key string, obc *v1alpha1.ObjectBucketClaim, provisioner api.Provisioner, isDynamicProvisioning bool) func(*v1alpha1.ObjectBucket) {
+	if obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		return nil
+	}
+	return func(ob *v1alpha1.ObjectBucket) {
+		logger := callerLogger().WithValues("obc", key)
+		obName, err := objectBucketNameFromClaimKey(key)
+		if err != nil {
+			logger.Error(err, "error getting name of OB")
+			return
+		}
+		if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), obName, metav1.GetOptions{}); err == nil {
+			logger.Info("claim was bound since the call timed out, keeping bucket it returned")
+			return
+		} else if !errors.IsNotFound(err) {
+			// the bucket may be in use, so it is leaked rather than deleted
+			logger.Error(err, "error getting OB, keeping bucket returned after the call timed out")
+			c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonBucketLeaked,
+				"provisioner returned a bucket after the call timed out, and it could not be checked whether the claim uses it: %v", err)
+			return
+		}
+		if isDynamicProvisioning {
+			err = provisioner.Delete(ob)
+		} else {
+			err = provisioner.Revoke(ob)
+		}
+		if err != nil {
+			logger.Error(err, "error cleaning up bucket returned after the call timed out")
+			c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonBucketLeaked,
+				"provisioner returned a bucket after the call timed out, and it could not be cleaned up: %v", err)
+			return
+		}
+		logger.Info("cleaned up bucket returned after the call timed out")
+	}
+}
 
-	// If the handler above errors, the request will be re-queued. In the distant future, we will
-	// likely want some ignorable error types in order to skip re-queuing
+// rejectBucket deletes or revokes a bucket which cannot be used for the claim and fails the claim.
+// Access to a new bucket is revoked rather than the bucket deleted if the rejection is a
+// PreserveBucketErr. Bound claims are only given a warning event, leaving the bucket as it is
+// rather than failing a claim which is in use.
+func (c *obcController) rejectBucket(obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBucket, provisioner api.Provisioner, isDynamicProvisioning bool, reason string, rejection error) (reconcileOutcome, error) {
+	if obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		log.Error(rejection, "not updating bound claim")
+		c.recorder.Event(obc, corev1.EventTypeWarning, reason, rejection.Error())
+		return outcomeFailedTerminal, nil
+	}
+	if bucketerrors.IsPreserveBucket(rejection) {
+		isDynamicProvisioning = false
+	}
+	timeout := c.deprovisionTimeout()
+	if err := c.cleanUpBucket(provisioner, ob, isDynamicProvisioning, timeout); err == errProvisionTimeout {
+		return "", fmt.Errorf("cleaning up rejected bucket did not complete within %v", timeout)
+	} else if err != nil {
+		return "", fmt.Errorf("error cleaning up rejected bucket: %v", err)
+	}
+	return "", newTerminalError(reason, rejection)
+}
+
+// deprovisionTimeout returns the bound of Delete and Revoke calls, which is the provision timeout
+// unless a delete timeout is set.
+func (c *obcController) deprovisionTimeout() time.Duration {
+	if c.deleteTimeout != nil {
+		return *c.deleteTimeout
+	}
+	return c.provisionTimeout
+}
+
+// cleanUpBucket deletes a bucket created for a claim which cannot use it, or revokes the access
+// granted to it, waiting at most timeout for the provisioner.
+func (c *obcController) cleanUpBucket(provisioner api.Provisioner, ob *v1alpha1.ObjectBucket, isDynamicProvisioning bool, timeout time.Duration) error {
+	_, err := callWithTimeout(timeout, func() (*v1alpha1.ObjectBucket, error) {
+		if isDynamicProvisioning {
+			return nil, provisioner.Delete(ob)
+		}
+		return nil, provisioner.Revoke(ob)
+	}, nil)
 	return err
 }
 
-func (c *obcController) handleProvisionClaim(key string, obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) error {
+func (c *obcController) handleProvisionClaim(key string, obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass, provisioner api.Provisioner) (reconcileOutcome, error) {
 
 	log.Info("syncing obc creation")
+	timer := newStepTimer(c.clock)
 
 	var (
 		ob  *v1alpha1.ObjectBucket
 		err error
 	)
 
+	// take a single snapshot of the labels so that all resources generated by this reconcile
+	// are labeled consistently
+	labels := c.labelsForClaim(obc)
+
+	// Reject names which cannot be used for the generated resources before the claim is given a
+	// finalizer or a bucket, rather than failing once the bucket has been provisioned.
+	if errs := validateGeneratedNames(obc, c.hashLongNames); len(errs) > 0 {
+		return "", newTerminalError(reasonInvalidName, fmt.Errorf("the OBC's name cannot be used for the generated resources: %s", strings.Join(errs, "; ")))
+	}
+
 	// set finalizer in OBC so that resources cleaned up is controlled when the obc is deleted
-	if obc, err = c.setOBCMetaFields(obc); err != nil {
-		return err
+	if obc, err = c.setOBCMetaFields(obc, labels); err != nil {
+		return "", err
 	}
 
 	ob, err = getObFromKey(key, c.libClientset) // ob may be nil here
 	if err != nil {
-		return fmt.Errorf("failed to find ob associated with obc %q", obc.Name)
+		return "", fmt.Errorf("failed to find ob associated with obc %q", obc.Name)
 	}
+	// an OB created by an admin is not marked as created by the controller when it is updated
+	createdByController := ob == nil || c.createdByController(ob)
 
 	// on an operator restart, the event will be an add event, and we should check if the obc has
 	// been updated in comparison to the ob, since we don't have an old OBC to compare to
 	if err = errIfObcConfigHasBeenModified(ob, obc); err != nil {
-		return err
+		return "", err
+	}
+
+	// Claims may reference a BucketClass which refines the parameters of the storage class
+	class, quota, err := c.applyBucketClass(obc, class)
+	if _, ok := asTerminalError(err); ok {
+		return "", err
+	} else if err != nil {
+		if _, uerr := updateObjectBucketClaimProgress(c.libClientset, obc, reasonWaitingForBucketClass, err.Error()); uerr != nil {
+			log.Error(uerr, "error recording progress")
+		}
+		return "", err
 	}
 
 	// If a storage class contains a non-nil value for the "bucketName" key, it is assumed
@@ -296,134 +1768,680 @@ func (c *obcController) handleProvisionClaim(key string, obc *v1alpha1.ObjectBuc
 	// to control access to static buckets via RBAC rules on storage classes.
 	isDynamicProvisioning := isNewBucketByStorageClass(class)
 
+	// A claim adopting an existing bucket is granted access to it, whatever its storage class, so
+	// that the bucket is not deleted with the claim.
+	adoptedBucket, adopting := obc.Annotations[v1alpha1.AdoptBucketAnnotation]
+	if adopting {
+		allow, err := allowBucketAdoptionForClass(class)
+		if err != nil {
+			return c.failUnlessBound(obc, reasonInvalidParameter, err)
+		}
+		if !allow {
+			return c.failUnlessBound(obc, reasonAdoptionRejected,
+				fmt.Errorf("StorageClass %q does not allow adopting bucket %q, set its %q parameter to allow it", class.Name, adoptedBucket, v1alpha1.StorageClassAllowBucketAdoption))
+		}
+		isDynamicProvisioning = false
+	}
+
+	// A claim without a bucket name can never be provisioned until the claim or its storage class
+	// is edited, so it is failed rather than retried.
 	bucketName := class.Parameters[v1alpha1.StorageClassBucket]
-	if isDynamicProvisioning {
+	if adopting {
+		bucketName = adoptedBucket
+	} else if isDynamicProvisioning {
 		bucketName, err = composeBucketName(obc)
+		if err == errBucketNameMissing {
+			return "", newTerminalError(reasonBucketNameMissing, fmt.Errorf("error composing bucket name: %v", err))
+		} else if err != nil {
+			return "", newTerminalError(reasonInvalidBucketName, err)
+		}
+	}
+	if len(strings.TrimSpace(bucketName)) == 0 {
+		missing := fmt.Errorf("bucket name missing: StorageClass %q parameter %q is blank", class.Name, v1alpha1.StorageClassBucket)
+		if adopting {
+			missing = fmt.Errorf("bucket name missing: annotation %q is blank", v1alpha1.AdoptBucketAnnotation)
+		}
+		return c.failUnlessBound(obc, reasonBucketNameMissing, missing)
+	}
+
+	// A bound claim is provisioned again, eg. after its storage class is recreated with new
+	// parameters. Changes which would move the claim to another bucket are not applied, and the
+	// bucket is left as it is rather than failing a claim which is in use.
+	if ob != nil && bucketName != ob.Spec.Endpoint.BucketName {
+		err = fmt.Errorf("storage class %q now refers to bucket %q rather than %q, which cannot be applied to a bound claim", class.Name, bucketName, ob.Spec.Endpoint.BucketName)
+		log.Error(err, "rejecting storage class change")
+		c.recorder.Event(obc, corev1.EventTypeWarning, reasonStorageClassChangeRejected, err.Error())
+		return outcomeFailedTerminal, nil
+	}
+
+	// the naming policy of the provisioner is only enforced on new claims, so that a policy
+	// introduced later does not fail claims which are in use
+	if validator, ok := provisioner.(api.BucketNameValidator); ok && obc.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		if err = validator.ValidateBucketName(obc.DeepCopy(), bucketName); err != nil {
+			return "", newTerminalError(reasonBucketNameRejected, fmt.Errorf("bucket name %q rejected by the provisioner: %v", bucketName, err))
+		}
+	}
+
+	obc, err = updateObjectBucketClaimProgress(c.libClientset, obc, reasonValidatingParameters, messageValidatingParameters)
+	if err != nil {
+		return "", err
+	}
+
+	timeout, err := c.provisionTimeoutForClass(class)
+	if err != nil {
+		return c.failUnlessBound(obc, reasonInvalidParameter, err)
+	}
+
+	tags, err := bucketTags(obc, class)
+	if err != nil {
+		return c.failUnlessBound(obc, reasonInvalidParameter, err)
+	}
+
+	requireTLS, err := c.requireTLSEndpointForClass(class)
+	if err != nil {
+		return c.failUnlessBound(obc, reasonInvalidParameter, err)
+	}
+
+	maxConcurrent, err := maxConcurrentProvisionsForClass(class)
+	if err != nil {
+		return c.failUnlessBound(obc, reasonInvalidParameter, err)
+	}
+
+	detectRegion, err := detectRegionForClass(class)
+	if err != nil {
+		return c.failUnlessBound(obc, reasonInvalidParameter, err)
+	}
+
+	collisionPolicy, err := bucketNameCollisionForClass(class)
+	if err != nil {
+		return c.failUnlessBound(obc, reasonInvalidParameter, err)
+	}
+
+	maxClaims, err := maxClaimsForClass(class)
+	if err != nil {
+		return c.failUnlessBound(obc, reasonInvalidParameter, err)
+	}
+	if maxClaims > 0 && obc.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		ahead, err := c.claimsOfClassAhead(obc, class.Name)
+		if err != nil {
+			return "", err
+		}
+		if ahead >= maxClaims {
+			return "", c.waitForClassQuota(obc, class.Name, maxClaims)
+		}
+	}
+
+	versioning, err := versioningForClaim(obc, provisioner)
+	if err != nil {
+		return c.failUnlessBound(obc, reasonInvalidAdditionalConfig, err)
+	}
+
+	accessMode, err := accessModeForClaim(obc, provisioner)
+	if err != nil {
+		return c.failUnlessBound(obc, reasonInvalidAdditionalConfig, err)
+	}
+
+	lifecycle, err := lifecycleForClaim(obc, provisioner)
+	if err != nil {
+		return c.failUnlessBound(obc, reasonInvalidAdditionalConfig, err)
+	}
+
+	// Resources created by users must not be taken over by the claim. Checking before provisioning
+	// avoids creating a bucket whose connection details cannot be stored.
+	childKey, err := childKeyForClaimKey(key, c.resourceNamespace)
+	if err != nil {
+		return "", err
+	}
+	unowned, err := unownedChildren(obc, childKey, c.clientset)
+	if err != nil {
+		return "", err
+	} else if len(unowned) > 0 {
+		return "", newTerminalError(reasonResourceConflict, fmt.Errorf("refusing to overwrite %s, not owned by the OBC", strings.Join(unowned, ", ")))
+	}
+
+	if preProvisioner, ok := provisioner.(api.PreProvisioner); ok && obc.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		namespace, err := c.clientset.CoreV1().Namespaces().Get(context.TODO(), obc.Namespace, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("error getting namespace %q: %v", obc.Namespace, err)
+		}
+		if err = preProvisioner.PreProvision(obc.DeepCopy(), namespace); err != nil {
+			return "", newTerminalError(reasonPreconditionFailed, fmt.Errorf("precondition failed: %v", err))
+		}
+	}
+	timer.step("parameter validation")
+
+	// In the case where a bucket name is being generated, generate the name and store it in the OBC
+	// spec before doing any Provisioning so that any crashes encountered in this code will not
+	// result in multiple buckets being generated for the same OBC. bucketName takes precedence over
+	// generateBucketName if both are present.
+	if obc.Spec.BucketName == "" {
+		obc, err = c.updateClaimWith(obc, func(obc *v1alpha1.ObjectBucketClaim) {
+			obc.Spec.BucketName = bucketName
+		})
+		if err != nil {
+			return "", fmt.Errorf("error updating OBC %q with bucket name: %v", key, err)
+		}
+	}
+
+	userID, err := provisioner.GenerateUserID(obc, ob)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate user id for use as idempotency key: %v", err)
+	}
+
+	options := &api.BucketOptions{
+		ReclaimPolicy:     class.ReclaimPolicy,
+		BucketName:        bucketName,
+		UserID:            userID,
+		ObjectBucketClaim: obc.DeepCopy(),
+		Parameters:        class.Parameters,
+		Quota:             quota,
+		Tags:              tags,
+		Versioning:        versioning,
+		AccessMode:        accessMode,
+		Lifecycle:         lifecycle,
+	}
+	// previousReclaimPolicy is set when the reclaim policy of a bound OB is replaced by that of its
+	// class, or of its BucketClass, which overrides it
+	var previousReclaimPolicy corev1.PersistentVolumeReclaimPolicy
+	if ob != nil && ob.Spec.ReclaimPolicy != nil && *ob.Spec.ReclaimPolicy != "" {
+		if c.syncReclaimPolicy && class.ReclaimPolicy != nil && *class.ReclaimPolicy != *ob.Spec.ReclaimPolicy {
+			previousReclaimPolicy = *ob.Spec.ReclaimPolicy
+		} else {
+			// a change to the reclaim policy of the class must not cause a bound bucket to be
+			// deleted, unless the controller opted in
+			options.ReclaimPolicy = ob.Spec.ReclaimPolicy
+		}
+	}
+
+	// only new buckets take up capacity
+	if checker, ok := provisioner.(api.CapacityChecker); ok && isDynamicProvisioning && ob == nil {
+		hasCapacity, err := checker.HasCapacity(options)
 		if err != nil {
-			return fmt.Errorf("error composing bucket name: %v", err)
+			return "", fmt.Errorf("error checking object store capacity: %v", err)
+		}
+		if !hasCapacity {
+			return "", c.waitForCapacity(obc)
+		}
+	}
+
+	verb, reason := "provisioning", reasonProvisioningBucket
+	if !isDynamicProvisioning {
+		verb, reason = "granting access to", reasonGrantingAccess
+	}
+	// the effective parameters, after those of the BucketClass are merged, show why a bucket was
+	// provisioned with unexpected settings
+	logD.Info(verb, "bucket", options.BucketName, "parameters", redactParameters(options.Parameters, c.redactedParameters))
+
+	obc, err = updateObjectBucketClaimProgress(c.libClientset, obc, reason, verb+" bucket")
+	if err != nil {
+		return "", err
+	}
+
+	progress := newProgressReporter(c.libClientset, obc, c.progressInterval, c.clock)
+	options.Progress = progress.report
+	// the slot is released when the call returns, even if it is abandoned after a timeout, so
+	// that the backend is not sent more requests than the class allows
+	release := c.classLimiter.acquire(class.Name, maxConcurrent)
+	start := c.clock.Now()
+	ob, err = callWithTimeout(timeout, func() (*v1alpha1.ObjectBucket, error) {
+		defer release()
+		if isDynamicProvisioning {
+			return provisioner.Provision(options)
+		}
+		return provisioner.Grant(options)
+	}, c.abandonedBucketCleanup(key, obc, provisioner, isDynamicProvisioning))
+	duration := c.clock.Since(start)
+	obc = progress.stop()
+	timer.step("provisioner call")
+	if err == errProvisionTimeout {
+		return "", newTerminalError(reasonProvisioningTimedOut, fmt.Errorf("%s bucket did not complete within %v", verb, timeout))
+	}
+
+	// The k8s code generator does not generate equality methods, and golang's native
+	// reflect.DeepEqual panics at unexported k8s struct fields, so must use apiequality lib.
+	emptyBucket := (ob == nil || apiequality.Semantic.DeepEqual(*ob, v1alpha1.ObjectBucket{}))
+
+	if _, ok := bucketerrors.RequeueAfter(err); ok {
+		// returned as is so that the claim is retried when the provisioner asked
+		return "", err
+	} else if bucketerrors.IsBucketExists(err) && isDynamicProvisioning && obc.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseBound && collisionPolicy != "" && c.bucketNameTaken(key, bucketName) {
+		return "", c.bucketNameCollision(obc, bucketName, collisionPolicy, err)
+	} else if err != nil {
+		if code, details, ok := bucketerrors.Code(err); ok {
+			c.recordProvisionerError(obc, verb, code, details)
+		}
+		if bucketerrors.IsPreserveBucket(err) && !emptyBucket && obc.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseBound {
+			// the provisioner returned the bucket it failed on to have the access granted to it
+			// revoked, rather than the bucket deleted
+			if deleted, outcome, err := c.abortIfClaimDeleted(key, obc, ob, provisioner, false); deleted || err != nil {
+				return outcome, err
+			}
+			timeout := c.deprovisionTimeout()
+			if cleanupErr := c.cleanUpBucket(provisioner, ob, false, timeout); cleanupErr != nil {
+				log.Error(cleanupErr, "error revoking access to bucket the provisioner failed on")
+			}
+		}
+		return "", fmt.Errorf("error %s bucket: %v", verb, err)
+	} else if emptyBucket {
+		return "", fmt.Errorf("provisioner returned empty object bucket")
+	}
+
+	if deleted, outcome, err := c.abortIfClaimDeleted(key, obc, ob, provisioner, isDynamicProvisioning); deleted || err != nil {
+		return outcome, err
+	}
+
+	if requireTLS && !isTLSEndpoint(ob) {
+		insecure := fmt.Errorf("provisioner returned a bucket endpoint which does not use TLS")
+		return c.rejectBucket(obc, ob, provisioner, isDynamicProvisioning, reasonInsecureEndpoint, insecure)
+	}
+
+	// lifecycle rules are only applied to new buckets, as those of existing buckets are managed by
+	// their owners
+	if applier, ok := provisioner.(api.LifecycleApplier); ok && isDynamicProvisioning && len(lifecycle) > 0 {
+		if err = applier.ApplyLifecycle(ob.DeepCopy(), lifecycle); err != nil {
+			return "", fmt.Errorf("error applying lifecycle rules: %v", err)
+		}
+	}
+
+	// Create/Update auth secret and endpoint configmap. Anonymous access to a bucket, eg. a public
+	// brownfield bucket, has no credentials and so no secret.
+	secretGenerated := hasAuthentication(ob)
+	// the credentials are checked before anything is written, so that consumers never see
+	// credentials which do not work
+	if validator, ok := provisioner.(api.CredentialsValidator); ok && c.validateCredentials && secretGenerated {
+		if err = validator.ValidateCredentials(ob.DeepCopy()); err != nil {
+			invalid := fmt.Errorf("credentials returned by the provisioner do not work: %w", err)
+			return c.rejectBucket(obc, ob, provisioner, isDynamicProvisioning, reasonInvalidCredentials, invalid)
+		}
+		timer.step("credentials validation")
+	}
+	if secretGenerated && c.secretDrift == SecretDriftFlag && obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		if err = c.handleSecretDrift(obc, ob, childKey); err != nil {
+			return "", err
+		}
+	}
+	createSecret := func() error {
+		if !secretGenerated {
+			log.Info("provisioner returned no authentication, skipping secret")
+			return nil
+		}
+		err := createOrUpdateSecret(
+			obc,
+			ob.Spec.Authentication,
+			labels,
+			c.annotations(),
+			childKey,
+			c.clientset)
+		if errors.IsForbidden(err) {
+			return c.resourceCreationForbidden(obc, "secret", err)
+		} else if err != nil {
+			return fmt.Errorf("error creating secret for OBC: %v", err)
+		}
+		timer.step("secret creation")
+		return nil
+	}
+	var configMapData map[string]string
+	if builder, ok := provisioner.(api.ConfigMapDataBuilder); ok {
+		configMapData, err = builder.BuildConfigMapData(ob.DeepCopy())
+		if err != nil {
+			return "", fmt.Errorf("error building configmap data for OBC: %v", err)
+		}
+	}
+	if len(c.childValidators) > 0 {
+		var configMap *corev1.ConfigMap
+		configMap, err = newBucketConfigMap(obc, endpointWithRegion(ob.Spec.Endpoint, detectRegion), configMapData, labels, c.annotations())
+		if err != nil {
+			return "", fmt.Errorf("error creating configmap for OBC: %v", err)
+		}
+		var secretData map[string]string
+		if secretGenerated {
+			var secret *corev1.Secret
+			secret, err = newCredentialsSecret(obc, ob.Spec.Authentication, labels, c.annotations())
+			if err != nil {
+				return "", fmt.Errorf("error creating secret for OBC: %v", err)
+			}
+			secretData = secret.StringData
+		}
+		if err = c.validateChildren(secretData, configMap.Data, secretGenerated); err != nil {
+			return c.rejectBucket(obc, ob, provisioner, isDynamicProvisioning, reasonChildValidationFailed, err)
+		}
+	}
+	createConfigMap := func() error {
+		err := createOrUpdateConfigMap(
+			obc,
+			endpointWithRegion(ob.Spec.Endpoint, detectRegion),
+			configMapData,
+			labels,
+			c.annotations(),
+			childKey,
+			c.clientset)
+		if errors.IsForbidden(err) {
+			return c.resourceCreationForbidden(obc, "configmap", err)
+		} else if err != nil {
+			return fmt.Errorf("error creating configmap for OBC: %v", err)
+		}
+		timer.step("configmap creation")
+		return nil
+	}
+	// either order creates both before the OB, whose Authentication is not stored and so is lost if
+	// the secret cannot be created
+	createChildren := []func() error{createSecret, createConfigMap}
+	if c.configMapFirst {
+		createChildren = []func() error{createConfigMap, createSecret}
+	}
+	for _, create := range createChildren {
+		if err = create(); err != nil {
+			return "", err
+		}
+	}
+
+	// the claim is checked again as creating the secret and configmap may have taken a while
+	if deleted, outcome, err := c.abortIfClaimDeleted(key, obc, ob, provisioner, isDynamicProvisioning); deleted || err != nil {
+		return outcome, err
+	}
+
+	// Create/Update OB
+	setObjectBucketName(ob, key)
+	if ob.Spec.StorageClassName == "" || ob.Spec.StorageClassName == obc.Spec.StorageClassName {
+		ob.Spec.StorageClassName = obc.Spec.StorageClassName
+	} else {
+		// Do not blindly overwrite the storage class. The provisioner might categorize the OB
+		// under a different logical class. The claim's class is still needed to detect changes
+		// to the claim and to decide how to clean up the bucket.
+		addAnnotations(ob, map[string]string{claimStorageClassAnnotation: obc.Spec.StorageClassName})
+	}
+	if ob.Spec.Provisioning == "" {
+		// recorded so that the bucket is cleaned up by the operation matching how it was obtained,
+		// even if the storage class is edited afterwards
+		ob.Spec.Provisioning = v1alpha1.ObjectBucketProvisioningGrant
+		if isDynamicProvisioning {
+			ob.Spec.Provisioning = v1alpha1.ObjectBucketProvisioningDynamic
+		}
+	}
+	if ob.Spec.ReclaimPolicy == nil || *ob.Spec.ReclaimPolicy == corev1.PersistentVolumeReclaimPolicy("") || previousReclaimPolicy != "" {
+		// Do not blindly overwrite the reclaim policy. The provisioner might have reason to
+		// specify a reclaim policy that is  different from the storage class.
+		ob.Spec.ReclaimPolicy = options.ReclaimPolicy
+	}
+	if !skipLabelPropagation(obc) {
+		addLabels(ob, c.obLabels)
+	}
+	addLabels(ob, labels)
+	addAnnotations(ob, c.annotations())
+	addAnnotations(ob, map[string]string{provisionerAnnotation: c.provisionerName})
+	if createdByController {
+		addLabels(ob, map[string]string{c.createdByKey: c.createdByValue})
+	}
+	addFinalizers(ob, []string{finalizer})
+	ob.Spec.ClaimRef, err = claimRefForKey(key, c.libClientset)
+	if err != nil {
+		return "", fmt.Errorf("error getting reference to OBC: %v", err)
+	}
+	ob, err = createOrUpdateObjectBucket(
+		ob,
+		c.libClientset)
+	if err != nil {
+		return "", fmt.Errorf("error creating or updating OB %q: %v", ob.Name, err)
+	}
+
+	// Status must be set/updated separately from OB spec, and is not written again for an OB which
+	// is already bound
+	if ob.Status.Phase != v1alpha1.ObjectBucketStatusPhaseBound {
+		ob.Status.Phase = v1alpha1.ObjectBucketStatusPhaseBound
+		ob, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().UpdateStatus(context.TODO(), ob, metav1.UpdateOptions{})
+		if err != nil {
+			return "", fmt.Errorf("error updating OB %q status to %q", ob.Name, v1alpha1.ObjectBucketStatusPhaseBound)
+		}
+	}
+	timer.step("OB creation")
+	if previousReclaimPolicy != "" {
+		log.Info("reclaim policy of storage class applied to OB", "from", previousReclaimPolicy, "to", *ob.Spec.ReclaimPolicy)
+		c.recorder.Eventf(obc, corev1.EventTypeNormal, reasonReclaimPolicyChanged,
+			"reclaim policy of ObjectBucket %q changed from %s to %s, following StorageClass %q", ob.Name, previousReclaimPolicy, *ob.Spec.ReclaimPolicy, class.Name)
+	}
+
+	// update OBC
+	obc, err = c.updateClaimWith(obc, func(obc *v1alpha1.ObjectBucketClaim) {
+		obc.Spec.ObjectBucketName = ob.Name
+		obc.Spec.BucketName = bucketName
+	})
+	if err != nil {
+		return "", fmt.Errorf("error updating OBC: %v", err)
+	}
+	setSecretGeneratedCondition(obc, secretGenerated, c.clock.Now())
+	clearBackedOffCondition(obc, c.clock.Now())
+	clearResourceCreationForbiddenCondition(obc, c.clock.Now())
+	clearWaitingForCapacityCondition(obc, c.clock.Now())
+	clearBucketMissingCondition(obc, c.clock.Now())
+	obc.Status.Reason, obc.Status.Message, obc.Status.Code = "", "", ""
+	obc.Status.ReclaimPolicy = effectiveReclaimPolicy(c.clientset, ob)
+	_, childName, _ := cache.SplitMetaNamespaceKey(childKey)
+	obc.Status.ConfigMapName, obc.Status.SecretName = childName, ""
+	if secretGenerated {
+		obc.Status.SecretName = childName
+	}
+	obc.Status.ProvisionDurationSeconds = duration.Round(time.Millisecond).Seconds()
+	obc.Status.ProvisioningMode = v1alpha1.ObjectBucketClaimProvisioningModeGrant
+	if isDynamicProvisioning {
+		obc.Status.ProvisioningMode = v1alpha1.ObjectBucketClaimProvisioningModeDynamic
+	}
+	obc, err = c.updateClaimPhase(obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+	if err != nil {
+		return "", fmt.Errorf("error updating OBC %q's status to %q: %v", obc.Name, v1alpha1.ObjectBucketClaimStatusPhaseBound, err)
+	}
+	timer.step("status update")
+	timer.total("provisioning")
+
+	if isDynamicProvisioning {
+		return outcomeProvisioned, nil
+	}
+	return outcomeGranted, nil
+}
+
+// waitForCapacity keeps a claim whose bucket cannot be provisioned while the object store is at
+// capacity Pending, and returns the error by which it is retried with the rate limiter's backoff.
+func (c *obcController) waitForCapacity(obc *v1alpha1.ObjectBucketClaim) error {
+	log.Info("object store is at capacity, waiting to provision bucket")
+	capacityBlockedTotal.Inc()
+	updateOBC := obc.DeepCopy()
+	setWaitingForCapacityCondition(updateOBC, c.clock.Now())
+	updateOBC.Status.Reason, updateOBC.Status.Message = reasonWaitingForCapacity, "waiting for the object store to have capacity"
+	if _, err := c.updateClaimPhase(updateOBC, v1alpha1.ObjectBucketClaimStatusPhasePending); err != nil {
+		return err
+	}
+	return fmt.Errorf("object store is at capacity")
+}
+
+// bucketNameCollision handles a new bucket whose name is taken, eg. by the bucket of another claim
+// with the same bucketName or generated name, according to the storage class's policy: the claim
+// is either failed, or its bucketName replaced by a unique name and retried. The new name is
+// stored before the retry, as for generated names, so that at most one bucket is provisioned.
+func (c *obcController) bucketNameCollision(obc *v1alpha1.ObjectBucketClaim, bucketName, policy string, err error) error {
+	if policy == v1alpha1.BucketNameCollisionFail {
+		return newTerminalError(reasonBucketNameCollision, fmt.Errorf("bucket name %q is taken: %v", bucketName, err))
+	}
+	uniqueName := uniqueBucketName(obc)
+	if _, uerr := c.updateClaimWith(obc, func(obc *v1alpha1.ObjectBucketClaim) {
+		obc.Spec.BucketName = uniqueName
+	}); uerr != nil {
+		return fmt.Errorf("error updating OBC with unique bucket name: %v", uerr)
+	}
+	c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonBucketNameCollision, "bucket name %q is taken, retrying with %q", bucketName, uniqueName)
+	return fmt.Errorf("bucket name %q is taken, retrying with %q: %v", bucketName, uniqueName, err)
+}
+
+// bucketNameTaken reports whether the bucket name is used by the OB of another claim, or by another
+// claim whose OB may not be written yet. An existing bucket whose name is not taken may be the
+// claim's own, eg. provisioned by an attempt which timed out or crashed before the OB was written,
+// so it is retried as any other error rather than renamed or failed by the collision policy.
+func (c *obcController) bucketNameTaken(key, bucketName string) bool {
+	obName, err := objectBucketNameFromClaimKey(key)
+	if err != nil {
+		log.Error(err, "error getting OB name", "obc", key)
+		return false
+	}
+	obs, err := c.obLister.List(labels.Everything())
+	if err != nil {
+		log.Error(err, "error listing OBs")
+		return false
+	}
+	for _, ob := range obs {
+		if ob.Name != obName && ob.Spec.Connection != nil && ob.Spec.Endpoint != nil && ob.Spec.Endpoint.BucketName == bucketName {
+			return true
 		}
 	}
-	if len(bucketName) == 0 {
-		return fmt.Errorf("bucket name missing")
+	obcs, err := c.obcLister.List(labels.Everything())
+	if err != nil {
+		log.Error(err, "error listing claims")
+		return false
+	}
+	for _, obc := range obcs {
+		if namespacedKey(obc.Namespace, obc.Name) != key && obc.Spec.BucketName == bucketName {
+			return true
+		}
 	}
+	logD.Info("bucket exists but its name is not taken by another claim", "bucket", bucketName)
+	return false
+}
 
-	// In the case where a bucket name is being generated, generate the name and store it in the OBC
-	// spec before doing any Provisioning so that any crashes encountered in this code will not
-	// result in multiple buckets being generated for the same OBC. bucketName takes precedence over
-	// generateBucketName if both are present.
-	if obc.Spec.BucketName == "" {
-		obc.Spec.BucketName = bucketName
-		obc, err = updateClaim(
-			c.libClientset,
-			obc)
+// abortIfClaimDeleted checks whether the claim was deleted while its bucket was being provisioned,
+// in which case provisioning is aborted rather than creating resources for a claim being torn
+// down. The bucket of an unbound claim is not yet recorded in an OB, where the delete flow would
+// find it, so it is deleted, or access to it revoked, here. Access is revoked for existing buckets
+// and for new buckets the provisioner asked to preserve with a PreserveBucketErr. Should that fail,
+// a BucketLeaked warning event is recorded, as the bucket cannot be found again. The claim is then
+// handed off to the delete flow, which deletes the generated secret and configmap and removes its
+// finalizer.
+func (c *obcController) abortIfClaimDeleted(key string, obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBucket, provisioner api.Provisioner, isDynamicProvisioning bool) (bool, reconcileOutcome, error) {
+	latest, err := claimForKey(key, c.libClientset)
+	if errors.IsNotFound(err) {
+		latest = nil
+	} else if err != nil {
+		return false, "", fmt.Errorf("error getting OBC %q: %v", key, err)
+	}
+	if latest != nil && latest.DeletionTimestamp == nil {
+		return false, "", nil
+	}
+	log.Info("OBC deleted while provisioning, aborting")
+	if obc.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		timeout := c.deprovisionTimeout()
+		if err = c.cleanUpBucket(provisioner, ob, isDynamicProvisioning, timeout); err == errProvisionTimeout {
+			err = fmt.Errorf("cleanup did not complete within %v", timeout)
+		}
 		if err != nil {
-			return fmt.Errorf("error updating OBC %q with bucket name: %v", key, err)
+			log.Error(err, "error cleaning up bucket of deleted OBC")
+			c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonBucketLeaked,
+				"claim was deleted while provisioning and its bucket could not be cleaned up: %v", err)
 		}
 	}
-
-	userID, err := c.provisioner.GenerateUserID(obc, ob)
-	if err != nil {
-		return fmt.Errorf("failed to generate user id for use as idempotency key: %v", err)
+	if latest == nil {
+		return true, outcomeSkippedNotFound, nil
 	}
+	outcome, err := c.handleDeleteClaim(key, latest, provisioner)
+	return true, outcome, err
+}
 
-	options := &api.BucketOptions{
-		ReclaimPolicy:     class.ReclaimPolicy,
-		BucketName:        bucketName,
-		UserID:            userID,
-		ObjectBucketClaim: obc.DeepCopy(),
-		Parameters:        class.Parameters,
+// recordProvisionerError reports an error with a backend-specific code returned by the provisioner
+// in an event and, unless the claim is bound, in its status. The claim is retried regardless, so
+// failing to record the error is only logged.
+func (c *obcController) recordProvisionerError(obc *v1alpha1.ObjectBucketClaim, verb, code, details string) {
+	c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonProvisionerError, "%s bucket failed with code %s: %s", verb, code, details)
+	if obc.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		return
 	}
-
-	verb := "provisioning"
-	if !isDynamicProvisioning {
-		verb = "granting access to"
+	updateOBC := obc.DeepCopy()
+	updateOBC.Status.Reason, updateOBC.Status.Message, updateOBC.Status.Code = reasonProvisionerError, details, code
+	if _, err := c.updateClaimPhase(updateOBC, updateOBC.Status.Phase); err != nil {
+		log.Error(err, "failed to record provisioner error", "code", code)
 	}
-	logD.Info(verb, "bucket", options.BucketName)
+}
 
-	if isDynamicProvisioning {
-		ob, err = c.provisioner.Provision(options)
-	} else {
-		ob, err = c.provisioner.Grant(options)
-	}
+// handleUpdateClaim passes the claim's new additionalConfig to the provisioner and records it in
+// the claim's OB. If the OB cannot be updated, the provisioner's update is reverted so that the
+// update is retried from a consistent state. Updates of existing buckets, which may be shared with
+// other claims, are rejected unless the storage class allows them.
+func (c *obcController) handleUpdateClaim(obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBucket, class *storagev1.StorageClass, provisioner api.Provisioner, updater api.Updater) (reconcileOutcome, error) {
 
-	// The k8s code generator does not generate equality methods, and golang's native
-	// reflect.DeepEqual panics at unexported k8s struct fields, so must use apiequality lib.
-	emptyBucket := (ob == nil || apiequality.Semantic.DeepEqual(*ob, v1alpha1.ObjectBucket{}))
+	log.Info("syncing obc update")
 
-	if err != nil {
-		return fmt.Errorf("error %s bucket: %v", verb, err)
-	} else if emptyBucket {
-		return fmt.Errorf("provisioner returned empty object bucket")
+	// the recorded operation tells new buckets from adopted ones, whose class names no bucket
+	isNewBucket := isNewBucketByObjectBucket(c.clientset, ob)
+	if !isNewBucket {
+		allow, err := allowBrownfieldUpdatesForClass(class)
+		if err != nil {
+			log.Error(err, "rejecting additionalConfig update of existing bucket")
+			c.recorder.Event(obc, corev1.EventTypeWarning, reasonInvalidParameter, err.Error())
+			return outcomeFailedTerminal, nil
+		}
+		if !allow {
+			// the bucket is left as it is rather than changing a bucket other claims may use
+			log.Info("rejecting additionalConfig update of existing bucket", "ob", ob.Name)
+			c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonBrownfieldUpdateRejected,
+				"additionalConfig of a claim of existing bucket %q cannot be updated unless StorageClass %q sets %q to \"true\"",
+				ob.Spec.Endpoint.BucketName, class.Name, v1alpha1.StorageClassAllowBrownfieldUpdates)
+			return outcomeFailedTerminal, nil
+		}
 	}
 
-	// Create/Update auth secret and endpoint configmap
-	err = createOrUpdateSecret(
-		obc,
-		ob.Spec.Authentication,
-		c.provisionerLabels,
-		c.clientset)
-	if err != nil {
-		return fmt.Errorf("error creating secret for OBC: %v", err)
-	}
-	err = createOrUpdateConfigMap(
-		obc,
-		ob.Spec.Endpoint,
-		c.provisionerLabels,
-		c.clientset)
+	lifecycle, err := lifecycleForClaim(obc, provisioner)
 	if err != nil {
-		return fmt.Errorf("error creating configmap for OBC: %v", err)
+		// the bucket is left as it is rather than failing a claim which is in use
+		log.Error(err, "rejecting additionalConfig of bound claim")
+		c.recorder.Event(obc, corev1.EventTypeWarning, reasonInvalidAdditionalConfig, err.Error())
+		return outcomeFailedTerminal, nil
 	}
 
-	// Create/Update OB
-	setObjectBucketName(ob, key)
-	ob.Spec.StorageClassName = obc.Spec.StorageClassName
-	if ob.Spec.ReclaimPolicy == nil || *ob.Spec.ReclaimPolicy == corev1.PersistentVolumeReclaimPolicy("") {
-		// Do not blindly overwrite the reclaim policy. The provisioner might have reason to
-		// specify a reclaim policy that is  different from the storage class.
-		ob.Spec.ReclaimPolicy = options.ReclaimPolicy
-	}
-	addLabels(ob, c.provisionerLabels)
-	addFinalizers(ob, []string{finalizer})
-	ob.Spec.ClaimRef, err = claimRefForKey(key, c.libClientset)
-	if err != nil {
-		return fmt.Errorf("error getting reference to OBC: %v", err)
+	oldConfig := ob.Spec.Endpoint.AdditionalConfigData
+	diff := diffConfig(oldConfig, obc.Spec.AdditionalConfig)
+	ob.Spec.Endpoint.AdditionalConfigData = obc.Spec.AdditionalConfig
+	// the rules are applied again on the next sync should the update fail
+	if applier, ok := provisioner.(api.LifecycleApplier); ok && diff.has(v1alpha1.AdditionalConfigLifecycle) && isNewBucket {
+		if err = applier.ApplyLifecycle(ob.DeepCopy(), lifecycle); err != nil {
+			return "", fmt.Errorf("provisioner error applying lifecycle rules: %v", err)
+		}
 	}
-	ob, err = createOrUpdateObjectBucket(
-		ob,
-		c.libClientset)
-	if err != nil {
-		return fmt.Errorf("error creating or updating OB %q: %v", ob.Name, err)
+	if err := updater.Update(ob.DeepCopy()); err != nil {
+		return "", fmt.Errorf("provisioner error updating bucket: %v", err)
 	}
 
-	// Status must be set/updated separately from OB spec
-	ob.Status.Phase = v1alpha1.ObjectBucketStatusPhaseBound
-	ob, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().UpdateStatus(context.TODO(), ob, metav1.UpdateOptions{})
+	_, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Update(context.TODO(), ob, metav1.UpdateOptions{})
 	if err != nil {
-		return fmt.Errorf("error updating OB %q status to %q", ob.Name, ob.Status.Phase)
+		err = fmt.Errorf("error updating OB %q: %v", ob.Name, err)
+		ob.Spec.Endpoint.AdditionalConfigData = oldConfig
+		if revertErr := updater.Update(ob.DeepCopy()); revertErr != nil {
+			// The bucket now has a configuration which is not recorded in the OB. Make this
+			// visible to users, as it may require manual remediation.
+			keys := diff.keys()
+			message := fmt.Sprintf("additionalConfig keys %v were applied to the bucket but not recorded in OB %q, and could not be reverted: %v", keys, ob.Name, revertErr)
+			log.Error(revertErr, "failed to revert provisioner update", "keys", keys)
+			c.recorder.Event(obc, corev1.EventTypeWarning, reasonUpdateDegraded, message)
+			setUpdateDegradedCondition(obc, message, c.clock.Now())
+			if _, statusErr := c.updateClaimPhase(obc, obc.Status.Phase); statusErr != nil {
+				log.Error(statusErr, "failed to set condition", "type", v1alpha1.ObjectBucketClaimConditionUpdateDegraded)
+			}
+		}
+		return "", err
 	}
 
-	// update OBC
-	obc.Spec.ObjectBucketName = ob.Name
-	obc.Spec.BucketName = bucketName
-	obc, err = updateClaim(
-		c.libClientset,
-		obc)
-	if err != nil {
-		return fmt.Errorf("error updating OBC: %v", err)
-	}
-	obc, err = updateObjectBucketClaimPhase(
-		c.libClientset,
-		obc,
-		v1alpha1.ObjectBucketClaimStatusPhaseBound)
-	if err != nil {
-		return fmt.Errorf("error updating OBC %q's status to %q: %v", obc.Name, v1alpha1.ObjectBucketClaimStatusPhaseBound, err)
+	if meta.IsStatusConditionTrue(obc.Status.Conditions, v1alpha1.ObjectBucketClaimConditionUpdateDegraded) {
+		clearUpdateDegradedCondition(obc, c.clock.Now())
+		if _, err = c.updateClaimPhase(obc, obc.Status.Phase); err != nil {
+			return "", err
+		}
 	}
 
-	return nil
+	c.recorder.Eventf(obc, corev1.EventTypeNormal, reasonAdditionalConfigUpdated, "additionalConfig updated: %s", diff)
+	if encoded, err := json.Marshal(diff); err != nil {
+		log.Error(err, "failed to encode additionalConfig diff")
+	} else if _, err = patchClaimMetadata(c.libClientset, obc, nil, map[string]string{lastConfigDiffAnnotation: string(encoded)}, nil); err != nil {
+		// the bucket and OB are already updated, so the annotation is informational only
+		log.Error(err, "failed to record additionalConfig diff", "annotation", lastConfigDiffAnnotation)
+	}
+	return outcomeUpdated, nil
 }
 
 // Delete or Revoke access to bucket defined by passed-in key and obc.
-func (c *obcController) handleDeleteClaim(key string, obc *v1alpha1.ObjectBucketClaim) error {
+func (c *obcController) handleDeleteClaim(key string, obc *v1alpha1.ObjectBucketClaim, provisioner api.Provisioner) (reconcileOutcome, error) {
 	// Call `Delete` for new (greenfield) buckets with reclaimPolicy == "Delete".
 	// Call `Revoke` for new buckets with reclaimPolicy != "Delete".
 	// Call `Revoke` for existing (brownfield) buckets regardless of reclaimPolicy.
@@ -432,47 +2450,330 @@ func (c *obcController) handleDeleteClaim(key string, obc *v1alpha1.ObjectBucket
 
 	ob, cm, secret, errs := c.getExistingResourcesFromKey(key)
 	if len(errs) > 0 {
-		return fmt.Errorf("error getting resources: %v", errs)
+		return "", fmt.Errorf("error getting resources: %v", errs)
 	}
 
 	// Delete/Revoke cannot be called if the ob is nil; however, if the secret
 	// and/or cm != nil we can delete them
 	if ob == nil {
 		log.Error(nil, "nil ObjectBucket, assuming it has been deleted")
-		return c.deleteResources(nil, cm, secret, obc)
+		if err := c.deleteResources(nil, cm, secret, obc); err != nil {
+			return "", err
+		}
+		return outcomeDeleted, nil
+	}
+
+	// An OB created by an admin, eg. for static binding, is only cleaned up if it explicitly names
+	// the claim, so that an admin-curated bucket is never deleted because its OB's name matches
+	if !c.createdByController(ob) && !bucketIsOwnedByClaim(obc, ob) {
+		log.Info("OB was not created by the controller and does not reference the claim, leaving it", "ob", ob.Name)
+		if err := c.deleteResources(nil, cm, secret, obc); err != nil {
+			return "", err
+		}
+		return outcomeAbandoned, nil
 	}
 
 	if ob.Spec.ReclaimPolicy == nil {
 		log.Error(nil, "missing reclaimPolicy", "ob", ob.Name)
-		return nil
+		return outcomeFailedTerminal, nil
 	}
 
 	// call Delete or Revoke and then delete generated k8s resources
 	// Note: if Delete or Revoke return err then we do not try to delete resources
-	ob, err := updateObjectBucketPhase(c.libClientset, ob, v1alpha1.ObjectBucketClaimStatusPhaseReleased)
+	released := ob.Status.Phase == v1alpha1.ObjectBucketStatusPhaseReleased
+	ob, err := c.updateBucketPhase(ob, v1alpha1.ObjectBucketStatusPhaseReleased)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if !released {
+		c.onReleased(obc, ob, provisioner)
 	}
 
-	// decide whether Delete or Revoke is called
-	if isNewBucketByObjectBucket(c.clientset, ob) && *ob.Spec.ReclaimPolicy == corev1.PersistentVolumeReclaimDelete {
-		if err = c.provisioner.Delete(ob); err != nil {
-			// Do not proceed to deleting the ObjectBucket if the deprovisioning fails for bookkeeping purposes
-			return fmt.Errorf("provisioner error deleting bucket %v", err)
-		}
+	// a previous sync may have called Delete or Revoke but failed to clean up the resources, in
+	// which case the backend is not called again
+	outcome := reconcileOutcome(ob.Annotations[deprovisionedAnnotation])
+	if outcome != "" {
+		log.Info("bucket already deprovisioned, cleaning up resources", "outcome", outcome)
 	} else {
-		if err = c.provisioner.Revoke(ob); err != nil {
-			return fmt.Errorf("provisioner error revoking access to bucket %v", err)
+		// decide whether Delete or Revoke is called
+		outcome = outcomeRevoked
+		timeout := c.deprovisionTimeout()
+		if effectiveReclaimPolicy(c.clientset, ob) == corev1.PersistentVolumeReclaimDelete {
+			_, err = callWithTimeout(timeout, func() (*v1alpha1.ObjectBucket, error) {
+				return nil, provisioner.Delete(ob)
+			}, nil)
+			if err == errProvisionTimeout {
+				return "", c.deprovisioningTimedOut(obc, "deleting bucket", timeout)
+			} else if err != nil {
+				// Do not proceed to deleting the ObjectBucket if the deprovisioning fails for bookkeeping purposes
+				return "", fmt.Errorf("provisioner error deleting bucket %v", err)
+			}
+			outcome = outcomeDeleted
+		} else {
+			_, err = callWithTimeout(timeout, func() (*v1alpha1.ObjectBucket, error) {
+				return nil, provisioner.Revoke(ob)
+			}, nil)
+			if err == errProvisionTimeout {
+				return "", c.deprovisioningTimedOut(obc, "revoking access to bucket", timeout)
+			} else if err != nil {
+				return "", fmt.Errorf("provisioner error revoking access to bucket %v", err)
+			}
+		}
+		if ob, err = markDeprovisioned(c.libClientset, ob, outcome); err != nil {
+			log.Error(err, "backend may be called again if cleanup fails")
+		}
+	}
+
+	// the bucket persists, so its endpoint may still be of use
+	if outcome == outcomeRevoked && c.keepConfigMapOnRevoke(ob) {
+		if err = orphanConfigMap(cm, obc, c.clientset); err != nil {
+			return "", fmt.Errorf("error keeping configmap: %v", err)
 		}
+		cm = nil
+	}
+
+	if err = c.deleteResources(ob, cm, secret, obc); err != nil {
+		return "", err
+	}
+	return outcome, nil
+}
+
+// deprovisioningTimedOut records that a Delete or Revoke call did not return within the timeout and
+// returns an error, so that the claim is retried. The abandoned call may still complete.
+func (c *obcController) deprovisioningTimedOut(obc *v1alpha1.ObjectBucketClaim, verb string, timeout time.Duration) error {
+	err := fmt.Errorf("%s did not complete within %v", verb, timeout)
+	c.recorder.Event(obc, corev1.EventTypeWarning, reasonDeprovisioningTimedOut, err.Error()+", retrying")
+	return err
+}
+
+// onReleased records the OB's transition into Released on the claim and the OB, and calls the
+// provisioner's OnReleased, if it implements it. An error of OnReleased is only logged.
+func (c *obcController) onReleased(obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBucket, provisioner api.Provisioner) {
+	c.recorder.Eventf(obc, corev1.EventTypeNormal, reasonReleased, "ObjectBucket %s released", ob.Name)
+	c.recorder.Eventf(ob, corev1.EventTypeNormal, reasonReleased, "released by deleted claim %s/%s", obc.Namespace, obc.Name)
+	handler, ok := provisioner.(api.ReleaseHandler)
+	if !ok {
+		return
+	}
+	if err := handler.OnReleased(ob.DeepCopy()); err != nil {
+		log.Error(err, "provisioner failed to handle the release of the bucket, deleting it anyway", "ob", ob.Name)
+	}
+}
+
+// handleSecretDrift compares the credentials in the secret of a bound claim with those returned by
+// the provisioner. If they differ, eg. because an external secret manager rotated them, the
+// authentication of the OB being reconciled is replaced with the secret's credentials, so that the
+// secret is written as it is, and the claim is flagged as drifted. The authentication is not
+// stored with the OB, so the comparison is made again on every sync.
+func (c *obcController) handleSecretDrift(obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBucket, childKey string) error {
+	secret, err := secretForClaimKey(childKey, c.clientset)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error getting secret of OBC: %v", err)
+	}
+	if !childIsOwnedByClaim(obc, secret) {
+		return nil
+	}
+	generated := ob.Spec.Authentication.ToMap()
+	current := secretCredentials(secret, generated)
+	if reflect.DeepEqual(current, generated) {
+		clearCredentialsDriftedCondition(obc, c.clock.Now())
+		return nil
+	}
+	if len(current) != len(generated) {
+		// the secret lacks credentials, which cannot be kept
+		log.Info("secret is missing credentials, restoring them", "secret", childKey)
+		return nil
+	}
+
+	ob.Spec.Authentication = &v1alpha1.Authentication{
+		AccessKeys: &v1alpha1.AccessKeys{
+			AccessKeyID:     current[v1alpha1.AwsKeyField],
+			SecretAccessKey: current[v1alpha1.AwsSecretField],
+		},
+		AdditionalSecretData: ob.Spec.Authentication.AdditionalSecretData,
+	}
+	log.Info("credentials in secret differ from the ObjectBucket", "secret", childKey)
+	c.recorder.Event(obc, corev1.EventTypeWarning, reasonCredentialsDrifted, "the credentials in the secret differ from those of the ObjectBucket")
+	setCredentialsDriftedCondition(obc, c.clock.Now())
+	return nil
+}
+
+// namespaceDeletionRetriesExhausted records a failed deletion of the claim, and reports whether the
+// claim is in a terminating namespace and its deletion has been retried namespaceDeletionRetries
+// times.
+func (c *obcController) namespaceDeletionRetriesExhausted(obc *v1alpha1.ObjectBucketClaim) bool {
+	if c.namespaceDeletionRetries == 0 {
+		return false
+	}
+	c.deletionFailuresLock.Lock()
+	c.deletionFailures[obc.UID]++
+	failures := c.deletionFailures[obc.UID]
+	c.deletionFailuresLock.Unlock()
+	if failures <= c.namespaceDeletionRetries {
+		return false
+	}
+	namespace, err := c.clientset.CoreV1().Namespaces().Get(context.TODO(), obc.Namespace, metav1.GetOptions{})
+	if err != nil {
+		log.Error(err, "error getting namespace", "namespace", obc.Namespace)
+		return false
+	}
+	return namespace.Status.Phase == corev1.NamespaceTerminating
+}
+
+// forgetDeletionFailures forgets the failed deletions of a claim which was cleaned up or released.
+func (c *obcController) forgetDeletionFailures(uid types.UID) {
+	c.deletionFailuresLock.Lock()
+	defer c.deletionFailuresLock.Unlock()
+	delete(c.deletionFailures, uid)
+}
+
+// abandonClaim releases a claim whose bucket could not be deleted or revoked, leaking the bucket,
+// so that the claim does not block the deletion of its namespace. The claim's OB is kept so that
+// an administrator can find and clean up the bucket.
+func (c *obcController) abandonClaim(key string, obc *v1alpha1.ObjectBucketClaim, cause error) (reconcileOutcome, error) {
+	log.Error(cause, "namespace is terminating and claim cleanup keeps failing, leaking bucket", "retries", c.namespaceDeletionRetries)
+	c.recorder.Eventf(obc, corev1.EventTypeWarning, reasonBucketLeaked,
+		"namespace %q is terminating and cleanup failed %d times, releasing claim and leaking its bucket: %v",
+		obc.Namespace, c.namespaceDeletionRetries, cause)
+
+	_, cm, secret, errs := c.getExistingResourcesFromKey(key)
+	if len(errs) > 0 {
+		return "", fmt.Errorf("error getting resources: %v", errs)
+	}
+	if err := c.deleteResources(nil, cm, secret, obc); err != nil {
+		return "", err
+	}
+	leakedBucketsTotal.Inc()
+	return outcomeAbandoned, nil
+}
+
+// applyBucketClass layers the claim's BucketClass, if any, over its storage class. The returned
+// storage class is a copy of class whose parameters and reclaim policy are overridden by the
+// BucketClass, along with the BucketClass's quota.
+func (c *obcController) applyBucketClass(obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) (*storagev1.StorageClass, *v1alpha1.BucketQuota, error) {
+	if obc.Spec.BucketClassName == "" {
+		return class, nil, nil
+	}
+	bucketClass, err := c.getBucketClass(obc.Spec.BucketClassName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting BucketClass %q: %v", obc.Spec.BucketClassName, err)
 	}
+	// the bucket name decides between provisioning and granting, which must be the same when the
+	// claim is deleted and only the storage class is known
+	if _, ok := bucketClass.Parameters[v1alpha1.StorageClassBucket]; ok {
+		return nil, nil, newTerminalError(reasonInvalidParameter,
+			fmt.Errorf("BucketClass %q cannot set parameter %q", bucketClass.Name, v1alpha1.StorageClassBucket))
+	}
+
+	class = class.DeepCopy()
+	if class.Parameters == nil && len(bucketClass.Parameters) > 0 {
+		class.Parameters = make(map[string]string, len(bucketClass.Parameters))
+	}
+	for k, v := range bucketClass.Parameters {
+		class.Parameters[k] = v
+	}
+	if bucketClass.ReclaimPolicy != nil {
+		class.ReclaimPolicy = bucketClass.ReclaimPolicy
+	}
+	return class, bucketClass.Quota, nil
+}
+
+// provisionTimeoutForClass returns how long to wait for Provision or Grant calls for claims of the
+// given class. The class's provisionTimeout parameter takes precedence over the controller's
+// default.
+func (c *obcController) provisionTimeoutForClass(class *storagev1.StorageClass) (time.Duration, error) {
+	value, ok := class.Parameters[v1alpha1.StorageClassProvisionTimeout]
+	if !ok {
+		return c.provisionTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("StorageClass %q parameter %q must be a non-negative duration, got %q", class.Name, v1alpha1.StorageClassProvisionTimeout, value)
+	}
+	return timeout, nil
+}
+
+// requireTLSEndpointForClass returns whether claims of the class must have an endpoint which uses
+// TLS.
+func (c *obcController) requireTLSEndpointForClass(class *storagev1.StorageClass) (bool, error) {
+	value, ok := class.Parameters[v1alpha1.StorageClassRequireTLSEndpoint]
+	if !ok {
+		return c.requireTLSEndpoint, nil
+	}
+	require, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("StorageClass %q parameter %q must be a boolean, got %q", class.Name, v1alpha1.StorageClassRequireTLSEndpoint, value)
+	}
+	return require, nil
+}
 
-	return c.deleteResources(ob, cm, secret, obc)
+// keepConfigMapOnRevoke returns whether the storage class of the OB asks for the configmap to be
+// kept when access to the bucket is revoked. An invalid parameter is logged and ignored, so that it
+// does not block the deletion of claims.
+func (c *obcController) keepConfigMapOnRevoke(ob *v1alpha1.ObjectBucket) bool {
+	class, err := storageClassForObjectBucket(ob, c.clientset)
+	if err != nil {
+		log.Error(err, "unable to get StorageClass of ObjectBucket")
+		return false
+	}
+	value, ok := class.Parameters[v1alpha1.StorageClassKeepConfigMapOnRevoke]
+	if !ok {
+		return false
+	}
+	keep, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Error(err, "ignoring invalid StorageClass parameter", "class", class.Name, "parameter", v1alpha1.StorageClassKeepConfigMapOnRevoke)
+		return false
+	}
+	return keep
 }
 
 func (c *obcController) supportedProvisioner(provisioner string) bool {
 	return provisioner == c.provisionerName
 }
 
+// reportUnsupportedProvisioner records an event on a claim of another provisioner which no
+// controller has picked up yet, so that a user who chose the wrong storage class learns why the
+// claim is not provisioned. The event is recorded once per claim.
+func (c *obcController) reportUnsupportedProvisioner(obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) {
+	if obc.Status.Phase != "" || obc.ObjectMeta.DeletionTimestamp != nil {
+		return
+	}
+	c.unsupportedLock.Lock()
+	defer c.unsupportedLock.Unlock()
+	if c.unsupportedReported[obc.UID] {
+		return
+	}
+	c.unsupportedReported[obc.UID] = true
+	c.recorder.Eventf(obc, corev1.EventTypeNormal, reasonUnsupportedProvisioner,
+		"StorageClass %q names provisioner %q, not %q; the claim is not provisioned unless a controller for %q is running",
+		class.Name, class.Provisioner, c.provisionerName, class.Provisioner)
+}
+
+// forgetDeletedClaim forgets that an event was recorded on a deleted claim, and its failed
+// deletions.
+func (c *obcController) forgetDeletedClaim(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	obc, ok := obj.(*v1alpha1.ObjectBucketClaim)
+	if !ok {
+		return
+	}
+	c.forgetDeletionFailures(obc.UID)
+	c.unsupportedLock.Lock()
+	defer c.unsupportedLock.Unlock()
+	delete(c.unsupportedReported, obc.UID)
+}
+
+// previousProvisioner returns true if provisioner is one of the previous names of this provisioner,
+// whose claims are cleaned up but not provisioned.
+func (c *obcController) previousProvisioner(provisioner string) bool {
+	return c.previousProvisionerNames[provisioner]
+}
+
 // trim the errors resulting from objects not being found
 func (c *obcController) getExistingResourcesFromKey(key string) (*v1alpha1.ObjectBucket, *corev1.ConfigMap, *corev1.Secret, []error) {
 	ob, cm, secret, errs := c.getResourcesFromKey(key)
@@ -500,9 +2801,14 @@ func (c *obcController) getResourcesFromKey(key string) (ob *v1alpha1.ObjectBuck
 
 	ob, err = c.objectBucketForClaimKey(key)
 	groupErrors(err)
-	cm, err = configMapForClaimKey(key, c.clientset)
+	childKey, err := childKeyForClaimKey(key, c.resourceNamespace)
+	if err != nil {
+		groupErrors(err)
+		return
+	}
+	cm, err = configMapForClaimKey(childKey, c.clientset)
 	groupErrors(err)
-	sec, err = secretForClaimKey(key, c.clientset)
+	sec, err = secretForClaimKey(childKey, c.clientset)
 	groupErrors(err)
 
 	return
@@ -511,46 +2817,230 @@ func (c *obcController) getResourcesFromKey(key string) (ob *v1alpha1.ObjectBuck
 // Deleting the resources generated by a Provision or Grant call is triggered by the delete of
 // the OBC. However, a finalizer is added to the OBC so that we can cleanup up the other resources
 // created by a Provision or Grant call. Since the secret and configmap's ownerReference is the OBC
-// they will be garbage collected once their finalizers are removed. The OB must be explicitly
+// they will be garbage collected once their finalizers are removed, unless they are in another
+// resource namespace, in which case they are deleted. The OB must be explicitly
 // deleted since it is a global resource and cannot have a namespaced ownerReference. The last step
 // is to remove the finalizer on the OBC so it too will be garbage collected.
 // Returns err if we can't delete one or more of the resources, the final returned error being
 // somewhat arbitrary.
 func (c *obcController) deleteResources(ob *v1alpha1.ObjectBucket, cm *corev1.ConfigMap, s *corev1.Secret, obc *v1alpha1.ObjectBucketClaim) (err error) {
 
+	if c.childrenDeletedFirst {
+		err = c.deleteChildren(cm, s, obc, true)
+	}
 	if delErr := deleteObjectBucket(ob, c.libClientset); delErr != nil {
 		log.Error(delErr, "error deleting objectBucket", ob.Name)
 		err = delErr
 	}
+	if !c.childrenDeletedFirst {
+		if delErr := c.deleteChildren(cm, s, obc, false); delErr != nil {
+			err = delErr
+		}
+	}
+	if delErr := releaseOBC(obc, c.libClientset); delErr != nil {
+		log.Error(delErr, "error releasing obc")
+		err = delErr
+	}
+	return err
+}
+
+// deleteChildren removes the finalizers of the claim's secret and configmap. Those in another
+// namespace than the claim, or all of them if force is set, are also deleted rather than left to
+// be garbage collected with the claim. Children which are not owned by the claim are not deleted.
+func (c *obcController) deleteChildren(cm *corev1.ConfigMap, s *corev1.Secret, obc *v1alpha1.ObjectBucketClaim, force bool) (err error) {
 	if delErr := releaseSecret(s, c.clientset); delErr != nil {
 		log.Error(delErr, "error releasing secret")
 		err = delErr
+	} else if s != nil && (force || s.Namespace != obc.Namespace) && childIsOwnedByClaim(obc, s) {
+		if delErr = deleteSecret(s, c.clientset); delErr != nil {
+			log.Error(delErr, "error deleting secret")
+			err = delErr
+		}
 	}
 	if delErr := releaseConfigMap(cm, c.clientset); delErr != nil {
 		log.Error(delErr, "error releasing configMap")
 		err = delErr
-	}
-	if delErr := releaseOBC(obc, c.libClientset); delErr != nil {
-		log.Error(delErr, "error releasing obc")
-		err = delErr
+	} else if cm != nil && (force || cm.Namespace != obc.Namespace) && childIsOwnedByClaim(obc, cm) {
+		if delErr = deleteConfigMap(cm, c.clientset); delErr != nil {
+			log.Error(delErr, "error deleting configMap")
+			err = delErr
+		}
 	}
 	return err
 }
 
-// Add finalizer and labels to the OBC.
-func (c *obcController) setOBCMetaFields(obc *v1alpha1.ObjectBucketClaim) (*v1alpha1.ObjectBucketClaim, error) {
-	clib := c.libClientset
+// backfillLabels adds the current provisioner labels which are missing from a bound claim and from
+// its OB, secret and configmap, eg. because they were created before SetLabels was called or by an
+// older version, so that selecting them by label does not miss them. Resources which carry the
+// labels are not updated. Errors are logged rather than blocking the reconcile, and the labels are
+// backfilled again the next time the claim is synced.
+func (c *obcController) backfillLabels(key string, obc *v1alpha1.ObjectBucketClaim) *v1alpha1.ObjectBucketClaim {
+	labels := c.labelsForClaim(obc)
+	// the claim is only patched if its labels differ
+	obc, err := patchClaimMetadata(c.libClientset, obc, labels, nil, nil)
+	if err != nil {
+		log.Error(err, "error backfilling labels")
+	}
+	if !c.cachedLabelsStale(key, obc, labels) {
+		return obc
+	}
+	ob, cm, secret, errs := c.getExistingResourcesFromKey(key)
+	if len(errs) > 0 {
+		log.Error(utilerrors.NewAggregate(errs), "error getting resources to backfill labels")
+		return obc
+	}
+	if ob != nil && len(changedEntries(ob.Labels, labels)) > 0 {
+		logD.Info("backfilling labels", "ob", ob.Name)
+		addLabels(ob, labels)
+		if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Update(context.TODO(), ob, metav1.UpdateOptions{}); err != nil {
+			log.Error(err, "error backfilling labels", "ob", ob.Name)
+		}
+	}
+	if secret != nil && childIsOwnedByClaim(obc, secret) && len(changedEntries(secret.Labels, labels)) > 0 {
+		logD.Info("backfilling labels", "secret", secret.Namespace+"/"+secret.Name)
+		addLabels(secret, labels)
+		if _, err = c.clientset.CoreV1().Secrets(secret.Namespace).Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+			log.Error(err, "error backfilling labels", "secret", secret.Namespace+"/"+secret.Name)
+		}
+	}
+	if cm != nil && childIsOwnedByClaim(obc, cm) && len(changedEntries(cm.Labels, labels)) > 0 {
+		logD.Info("backfilling labels", "configmap", cm.Namespace+"/"+cm.Name)
+		addLabels(cm, labels)
+		if _, err = c.clientset.CoreV1().ConfigMaps(cm.Namespace).Update(context.TODO(), cm, metav1.UpdateOptions{}); err != nil {
+			log.Error(err, "error backfilling labels", "configmap", cm.Namespace+"/"+cm.Name)
+		}
+	}
+	return obc
+}
+
+// healConfigMap recreates the configmap of a bound claim from the endpoint of its OB if it is
+// missing, or if any of the standard keys are, eg. because a previous reconcile failed while writing
+// it. Like the labels, the configmap is healed before provisioning again, which may not rewrite it.
+// The configmap is read from the child informer if there is one, so that healthy configmaps cost no
+// request, and one missing from it is looked up on the API server before it is recreated, as an
+// unlabeled configmap is not watched.
+func (c *obcController) healConfigMap(key string, obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass, provisioner api.Provisioner) {
+	childKey, err := childKeyForClaimKey(key, c.resourceNamespace)
+	if err != nil {
+		log.Error(err, "error getting configmap key", "obc", key)
+		return
+	}
+	cm, err := c.cachedConfigMap(childKey)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "error getting configmap to heal", "configmap", childKey)
+		return
+	}
+	missing := standardConfigMapKeys
+	if err == nil {
+		if !childIsOwnedByClaim(obc, cm) {
+			return
+		}
+		if missing = missingConfigMapKeys(cm.Data); len(missing) == 0 {
+			return
+		}
+	}
+	ob, err := c.objectBucketForClaimKey(key)
+	if err != nil {
+		log.Error(err, "error getting OB to heal configmap", "configmap", childKey)
+		return
+	}
+	detectRegion, err := detectRegionForClass(class)
+	if err != nil {
+		log.Error(err, "error healing configmap", "configmap", childKey)
+		return
+	}
+	var configMapData map[string]string
+	if builder, ok := provisioner.(api.ConfigMapDataBuilder); ok {
+		if configMapData, err = builder.BuildConfigMapData(ob.DeepCopy()); err != nil {
+			log.Error(err, "error building configmap data", "configmap", childKey)
+			return
+		}
+	}
+	log.Info("healing configmap", "configmap", childKey, "missingKeys", missing)
+	err = createOrUpdateConfigMap(obc, endpointWithRegion(ob.Spec.Endpoint, detectRegion), configMapData, c.labelsForClaim(obc), c.annotations(), childKey, c.clientset)
+	if err != nil {
+		log.Error(err, "error healing configmap", "configmap", childKey)
+		return
+	}
+	message := fmt.Sprintf("configmap was missing %s and was recreated from the ObjectBucket", strings.Join(missing, ", "))
+	if cm == nil {
+		message = "configmap was missing and was recreated from the ObjectBucket"
+	}
+	c.recorder.Event(obc, corev1.EventTypeNormal, reasonConfigMapHealed, message)
+}
 
-	// Do not make changes directly to the obc used as input. If the update fails, we should return
-	// the obc given as input as it was given so code that comes after can't assume obc is at the
-	// new phase.
-	updateOBC := obc.DeepCopy()
+// cachedSecret returns the secret with the given key from the child informer if there is one, and
+// from the API server otherwise. The child informer only watches labeled resources, so a secret
+// missing from it is read from the API server too, as it may exist without the labels.
+func (c *obcController) cachedSecret(childKey string) (*corev1.Secret, error) {
+	ns, name, err := cache.SplitMetaNamespaceKey(childKey)
+	if err != nil {
+		return nil, err
+	}
+	if c.childInformerFactory != nil {
+		secret, err := c.childInformerFactory.Core().V1().Secrets().Lister().Secrets(ns).Get(name)
+		if !errors.IsNotFound(err) {
+			return secret, err
+		}
+	}
+	secret, err := c.clientset.CoreV1().Secrets(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// cachedConfigMap returns the configmap with the given key from the child informer if there is one,
+// and from the API server otherwise. As for secrets, a configmap missing from the child informer is
+// read from the API server too.
+func (c *obcController) cachedConfigMap(childKey string) (*corev1.ConfigMap, error) {
+	ns, name, err := cache.SplitMetaNamespaceKey(childKey)
+	if err != nil {
+		return nil, err
+	}
+	if c.childInformerFactory != nil {
+		cm, err := c.childInformerFactory.Core().V1().ConfigMaps().Lister().ConfigMaps(ns).Get(name)
+		if !errors.IsNotFound(err) {
+			return cm, err
+		}
+	}
+	cm, err := c.clientset.CoreV1().ConfigMaps(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
 
-	addFinalizers(updateOBC, []string{finalizer})
-	addLabels(updateOBC, c.provisionerLabels)
+// cachedLabelsStale reports whether the OB, configmap or secret of the claim lack any of the given
+// labels, according to the informers, so that resources whose labels are already backfilled cost
+// no request. The generated configmap and secret are read from the API server if there is no child
+// informer, or if they are missing from it as they lack the labels it watches.
+func (c *obcController) cachedLabelsStale(key string, obc *v1alpha1.ObjectBucketClaim, labels map[string]string) bool {
+	obName, err := objectBucketNameFromClaimKey(key)
+	if err != nil {
+		return true
+	}
+	if ob, err := c.obLister.Get(obName); err == nil && len(changedEntries(ob.Labels, labels)) > 0 {
+		return true
+	}
+	childKey, err := childKeyForClaimKey(key, c.resourceNamespace)
+	if err != nil {
+		return true
+	}
+	if cm, err := c.cachedConfigMap(childKey); err == nil && childIsOwnedByClaim(obc, cm) && len(changedEntries(cm.Labels, labels)) > 0 {
+		return true
+	}
+	if secret, err := c.cachedSecret(childKey); err == nil && childIsOwnedByClaim(obc, secret) && len(changedEntries(secret.Labels, labels)) > 0 {
+		return true
+	}
+	return false
+}
 
+// Add finalizer, labels and annotations to the OBC. Only these fields are written, so that changes
+// made to the OBC's metadata concurrently, eg. by users, are preserved.
+func (c *obcController) setOBCMetaFields(obc *v1alpha1.ObjectBucketClaim, labels map[string]string) (*v1alpha1.ObjectBucketClaim, error) {
 	logD.Info("updating OBC metadata")
-	obcUpdated, err := updateClaim(clib, updateOBC)
+	obcUpdated, err := patchClaimMetadata(c.libClientset, obc, labels, c.annotations(), []string{finalizer})
 	if err != nil {
 		return obc, fmt.Errorf("error configuring obc metadata: %v", err)
 	}
@@ -583,6 +3073,11 @@ func updateSupported(old, new *v1alpha1.ObjectBucketClaim) bool {
 	if reflect.DeepEqual(new.Spec, old.Spec) {
 		return false
 	}
+	// A failed claim has no bucket bound to it, so any change to its spec may resolve the failure
+	// and is retried
+	if new.Status.Phase == v1alpha1.ObjectBucketClaimStatusPhaseFailed {
+		return true
+	}
 	// create copy of old spec, and set the new spec's additionalConfig on it
 	oldspec := old.Spec.DeepCopy()
 	oldspec.AdditionalConfig = new.Spec.AdditionalConfig
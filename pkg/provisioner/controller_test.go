@@ -0,0 +1,6539 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	testingclock "k8s.io/utils/clock/testing"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	externalFake "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned/fake"
+	informers "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/informers/externalversions"
+	listers "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/listers/objectbucket.io/v1alpha1"
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
+	bucketerrors "github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api/errors"
+)
+
+// newTestController returns a controller backed by fake clientsets which are pre-populated with
+// the given kubernetes and objectbucket.io objects.
+func newTestController(p api.Provisioner, kubeObjects []runtime.Object, libObjects []runtime.Object, options ...Option) *obcController {
+	client := fake.NewSimpleClientset(kubeObjects...)
+	libClient := externalFake.NewSimpleClientset(libObjects...)
+	factory := informers.NewSharedInformerFactory(libClient, 0)
+	return NewController(
+		provisionerName,
+		p,
+		client,
+		libClient,
+		factory.Objectbucket().V1alpha1().ObjectBucketClaims(),
+		factory.Objectbucket().V1alpha1().ObjectBuckets(),
+		options...)
+}
+
+func newTestStorageClass(parameters map[string]string) *storagev1.StorageClass {
+	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+	return &storagev1.StorageClass{
+		ObjectMeta:    metav1.ObjectMeta{Name: className},
+		Provisioner:   provisionerName,
+		ReclaimPolicy: &reclaimPolicy,
+		Parameters:    parameters,
+	}
+}
+
+func newTestClaim(name string) *v1alpha1.ObjectBucketClaim {
+	return &v1alpha1.ObjectBucketClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNamespace,
+			Name:      name,
+		},
+		Spec: v1alpha1.ObjectBucketClaimSpec{
+			StorageClassName:   className,
+			GenerateBucketName: name,
+		},
+	}
+}
+
+func newTestClaimInPhase(name string, phase v1alpha1.ObjectBucketClaimStatusPhase, created metav1.Time) *v1alpha1.ObjectBucketClaim {
+	obc := newTestClaim(name)
+	obc.CreationTimestamp = created
+	obc.Status.Phase = phase
+	return obc
+}
+
+func newTestBucket() *v1alpha1.ObjectBucket {
+	return &v1alpha1.ObjectBucket{
+		Spec: v1alpha1.ObjectBucketSpec{
+			Connection: &v1alpha1.Connection{
+				Endpoint: &v1alpha1.Endpoint{
+					BucketHost: "s3.test.com",
+					BucketPort: 443,
+				},
+				Authentication: &v1alpha1.Authentication{
+					AccessKeys: &v1alpha1.AccessKeys{
+						AccessKeyID:     "test-access-key",
+						SecretAccessKey: "test-secret-key",
+					},
+				},
+			},
+		},
+	}
+}
+
+// newTestConfigMap returns the configmap generated for a bound claim, for tests which create bound
+// claims directly.
+func newTestConfigMap(t *testing.T, obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBucket) *corev1.ConfigMap {
+	t.Helper()
+	cm, err := newBucketConfigMap(obc, ob.Spec.Endpoint, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("error creating configmap: %v", err)
+	}
+	return cm
+}
+
+func testKey(obc *v1alpha1.ObjectBucketClaim) string {
+	return obc.Namespace + "/" + obc.Name
+}
+
+// waitForClaimPhase polls the fake clientset until the claim reaches the given phase.
+func waitForClaimPhase(t *testing.T, c *obcController, obc *v1alpha1.ObjectBucketClaim, phase v1alpha1.ObjectBucketClaimStatusPhase) {
+	t.Helper()
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return got.Status.Phase == phase, nil
+	})
+	if err != nil {
+		t.Fatalf("claim %q did not reach phase %q: %v", testKey(obc), phase, err)
+	}
+}
+
+func TestSetLabelsWhileReconciling(t *testing.T) {
+	const numClaims = 10
+
+	var libObjects []runtime.Object
+	for i := 0; i < numClaims; i++ {
+		libObjects = append(libObjects, newTestClaim(fmt.Sprintf("%s-%d", testName, i)))
+	}
+	c := newTestController(
+		&fakeProvisioner{bucket: newTestBucket()},
+		[]runtime.Object{newTestStorageClass(nil)},
+		libObjects)
+
+	for _, obj := range libObjects {
+		c.queue.Add(testKey(obj.(*v1alpha1.ObjectBucketClaim)))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.runWorker(0)
+		close(done)
+	}()
+
+	// modify the labels while the worker is reading them to generate resources
+	for i := 0; i < numClaims; i++ {
+		c.SetLabels(map[string]string{fmt.Sprintf("label-%d", i): "value"})
+	}
+
+	for _, obj := range libObjects {
+		waitForClaimPhase(t, c, obj.(*v1alpha1.ObjectBucketClaim), v1alpha1.ObjectBucketClaimStatusPhaseBound)
+	}
+	c.queue.ShutDown()
+	<-done
+
+	if got := len(c.labels()); got != numClaims+1 {
+		t.Errorf("wanted %d labels, got %d", numClaims+1, got)
+	}
+}
+
+func TestSetProvisionerWhileReconciling(t *testing.T) {
+	const numClaims = 10
+
+	var libObjects []runtime.Object
+	for i := 0; i < numClaims; i++ {
+		libObjects = append(libObjects, newTestClaim(fmt.Sprintf("%s-%d", testName, i)))
+	}
+	c := newTestController(
+		&fakeProvisioner{bucket: newTestBucket()},
+		[]runtime.Object{newTestStorageClass(nil)},
+		libObjects)
+
+	for _, obj := range libObjects {
+		c.queue.Add(testKey(obj.(*v1alpha1.ObjectBucketClaim)))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.runWorker(0)
+		close(done)
+	}()
+
+	// replace the provisioner while the worker is syncing claims with it
+	for i := 0; i < numClaims; i++ {
+		c.SetProvisioner(&fakeProvisioner{bucket: newTestBucket()})
+	}
+
+	for _, obj := range libObjects {
+		waitForClaimPhase(t, c, obj.(*v1alpha1.ObjectBucketClaim), v1alpha1.ObjectBucketClaimStatusPhaseBound)
+	}
+	c.queue.ShutDown()
+	<-done
+}
+
+func TestSetProvisionerDuringInFlightSync(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	var oldCalls, newCalls int32
+	oldProvisioner := &fakeProvisioner{
+		provisionFunc: func(*api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+			atomic.AddInt32(&oldCalls, 1)
+			close(started)
+			<-unblock
+			return newTestBucket(), nil
+		},
+	}
+	newProvisioner := &fakeProvisioner{
+		provisionFunc: func(*api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+			atomic.AddInt32(&newCalls, 1)
+			return newTestBucket(), nil
+		},
+	}
+
+	first, second := newTestClaim(testName+"-1"), newTestClaim(testName+"-2")
+	c := newTestController(oldProvisioner, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{first, second})
+
+	synced := make(chan error)
+	go func() {
+		_, err := c.syncHandler(testKey(first))
+		synced <- err
+	}()
+	<-started
+
+	// the provisioner is replaced without waiting for the sync hung in the previous provisioner
+	swapped := make(chan struct{})
+	go func() {
+		c.SetProvisioner(newProvisioner)
+		close(swapped)
+	}()
+	select {
+	case <-swapped:
+	case <-time.After(time.Second):
+		t.Fatal("SetProvisioner waited for a sync in flight")
+	}
+
+	if _, err := c.syncHandler(testKey(second)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	close(unblock)
+	if err := <-synced; err != nil {
+		t.Fatalf("error syncing in-flight claim: %v", err)
+	}
+	waitForClaimPhase(t, c, first, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+	if got := atomic.LoadInt32(&oldCalls); got != 1 {
+		t.Errorf("wanted the previous provisioner to be called once, got %d", got)
+	}
+	if got := atomic.LoadInt32(&newCalls); got != 1 {
+		t.Errorf("wanted the new provisioner to be called once, got %d", got)
+	}
+}
+
+func TestCustomKeyFunc(t *testing.T) {
+	const shardPrefix = "shard-1|"
+
+	keyFunc := func(obj interface{}) (string, error) {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		return shardPrefix + key, err
+	}
+	splitFunc := func(key string) (string, string, error) {
+		if !strings.HasPrefix(key, shardPrefix) {
+			return "", "", fmt.Errorf("key %q has no shard", key)
+		}
+		return cache.SplitMetaNamespaceKey(strings.TrimPrefix(key, shardPrefix))
+	}
+
+	obc := newTestClaim(testName)
+	c := newTestController(
+		&fakeProvisioner{bucket: newTestBucket()},
+		[]runtime.Object{newTestStorageClass(nil)},
+		[]runtime.Object{obc},
+		WithKeyFunc(keyFunc, splitFunc))
+
+	c.enqueueOBC(obc)
+	item, _ := c.queue.Get()
+	key := item.(string)
+	if want := shardPrefix + testKey(obc); key != want {
+		t.Fatalf("wanted key %q, got %q", want, key)
+	}
+	if _, err := c.syncHandler(key); err != nil {
+		t.Fatalf("error syncing key %q: %v", key, err)
+	}
+	c.queue.Done(item)
+
+	if _, err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), "obc-"+testNamespace+"-"+testName, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected OB to be named after the claim, not the shard key: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+}
+
+func TestReconcileOutcomes(t *testing.T) {
+	unsupportedClass := newTestStorageClass(nil)
+	unsupportedClass.Name = "unsupported-class"
+	unsupportedClass.Provisioner = "other-provisioner"
+
+	tests := []struct {
+		name    string
+		class   *storagev1.StorageClass
+		claim   *v1alpha1.ObjectBucketClaim
+		want    reconcileOutcome
+		wantErr bool
+	}{
+		{
+			name:  "greenfield claim is provisioned",
+			class: newTestStorageClass(nil),
+			claim: newTestClaim(testName),
+			want:  outcomeProvisioned,
+		},
+		{
+			name:  "brownfield claim is granted",
+			class: newTestStorageClass(map[string]string{v1alpha1.StorageClassBucket: "existing-bucket"}),
+			claim: newTestClaim(testName),
+			want:  outcomeGranted,
+		},
+		{
+			name:  "claim of another provisioner is skipped",
+			class: unsupportedClass,
+			claim: func() *v1alpha1.ObjectBucketClaim {
+				obc := newTestClaim(testName)
+				obc.Spec.StorageClassName = unsupportedClass.Name
+				return obc
+			}(),
+			want: outcomeSkippedUnsupported,
+		},
+		{
+			name:  "missing claim is skipped",
+			class: newTestStorageClass(nil),
+			want:  outcomeSkippedNotFound,
+		},
+		{
+			name:  "missing storage class is requeued",
+			claim: newTestClaim(testName),
+			want:  outcomeRequeuedTransient,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var kubeObjects, libObjects []runtime.Object
+			if tt.class != nil {
+				kubeObjects = append(kubeObjects, tt.class)
+			}
+			if tt.claim != nil {
+				libObjects = append(libObjects, tt.claim)
+			}
+			c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, kubeObjects, libObjects)
+
+			before := testutil.ToFloat64(reconcileTotal.WithLabelValues(string(tt.want)))
+			c.queue.Add(testNamespace + "/" + testName)
+			if !c.processNextItemInQueue(0) {
+				t.Fatal("queue unexpectedly shut down")
+			}
+			if got := testutil.ToFloat64(reconcileTotal.WithLabelValues(string(tt.want))) - before; got != 1 {
+				t.Errorf("wanted outcome %q to be recorded once, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProvisionTimeout(t *testing.T) {
+	// unblock is closed at the end of the test to release provisioner calls left running
+	unblock := make(chan struct{})
+	defer close(unblock)
+	slowProvisioner := &fakeProvisioner{
+		provisionFunc: func(*api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+			<-unblock
+			return newTestBucket(), nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		provisioner *fakeProvisioner
+		parameters  map[string]string
+		options     []Option
+		wantPhase   v1alpha1.ObjectBucketClaimStatusPhase
+		wantReason  string
+	}{
+		{
+			name:        "storage class timeout elapses",
+			provisioner: slowProvisioner,
+			parameters:  map[string]string{v1alpha1.StorageClassProvisionTimeout: "10ms"},
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason:  reasonProvisioningTimedOut,
+		},
+		{
+			name:        "controller default timeout elapses",
+			provisioner: slowProvisioner,
+			options:     []Option{WithProvisionTimeout(10 * time.Millisecond)},
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason:  reasonProvisioningTimedOut,
+		},
+		{
+			name:        "storage class timeout overrides controller default",
+			provisioner: &fakeProvisioner{bucket: newTestBucket()},
+			parameters:  map[string]string{v1alpha1.StorageClassProvisionTimeout: "1m"},
+			options:     []Option{WithProvisionTimeout(time.Nanosecond)},
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:        "invalid storage class timeout",
+			provisioner: &fakeProvisioner{bucket: newTestBucket()},
+			parameters:  map[string]string{v1alpha1.StorageClassProvisionTimeout: "soon"},
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason:  reasonInvalidParameter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			c := newTestController(
+				tt.provisioner,
+				[]runtime.Object{newTestStorageClass(tt.parameters)},
+				[]runtime.Object{obc},
+				tt.options...)
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+
+			if tt.wantReason == "" {
+				if outcome == outcomeFailedTerminal {
+					t.Errorf("unexpected outcome %q", outcome)
+				}
+				return
+			}
+			if outcome != outcomeFailedTerminal {
+				t.Errorf("wanted outcome %q, got %q", outcomeFailedTerminal, outcome)
+			}
+			select {
+			case event := <-recorder.Events:
+				if !strings.HasPrefix(event, corev1.EventTypeWarning+" "+tt.wantReason+" ") {
+					t.Errorf("wanted %s event with reason %q, got %q", corev1.EventTypeWarning, tt.wantReason, event)
+				}
+			default:
+				t.Errorf("wanted event with reason %q, got none", tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestClassMaxClaims(t *testing.T) {
+	older := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	tests := []struct {
+		name string
+		// others are claims of the class besides the synced one
+		others     []*v1alpha1.ObjectBucketClaim
+		maxClaims  string
+		wantErr    bool
+		wantPhase  v1alpha1.ObjectBucketClaimStatusPhase
+		wantReason string
+	}{
+		{
+			name:      "under limit",
+			others:    []*v1alpha1.ObjectBucketClaim{newTestClaimInPhase("bound", v1alpha1.ObjectBucketClaimStatusPhaseBound, older)},
+			maxClaims: "2",
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:       "bound claims at limit",
+			others:     []*v1alpha1.ObjectBucketClaim{newTestClaimInPhase("bound", v1alpha1.ObjectBucketClaimStatusPhaseBound, older)},
+			maxClaims:  "1",
+			wantErr:    true,
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhasePending,
+			wantReason: reasonClassQuotaExceeded,
+		},
+		{
+			name:       "older pending claims at limit",
+			others:     []*v1alpha1.ObjectBucketClaim{newTestClaimInPhase("pending", v1alpha1.ObjectBucketClaimStatusPhasePending, older)},
+			maxClaims:  "1",
+			wantErr:    true,
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhasePending,
+			wantReason: reasonClassQuotaExceeded,
+		},
+		{
+			name: "newer pending and failed claims are not counted",
+			others: []*v1alpha1.ObjectBucketClaim{
+				newTestClaimInPhase("pending", v1alpha1.ObjectBucketClaimStatusPhasePending, metav1.NewTime(time.Now().Add(time.Hour))),
+				newTestClaimInPhase("failed", v1alpha1.ObjectBucketClaimStatusPhaseFailed, older),
+			},
+			maxClaims: "1",
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:       "invalid limit",
+			maxClaims:  "none",
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason: reasonInvalidParameter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			obc.CreationTimestamp = metav1.Now()
+			class := newTestStorageClass(map[string]string{v1alpha1.StorageClassMaxClaims: tt.maxClaims})
+			c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{class}, []runtime.Object{obc})
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+			for _, other := range append(tt.others, obc) {
+				if err := c.obcInformer.Informer().GetIndexer().Add(other); err != nil {
+					t.Fatalf("error adding claim to lister: %v", err)
+				}
+			}
+
+			_, err := c.syncHandler(testKey(obc))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wantErr %v, error = %v", tt.wantErr, err)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if tt.wantReason != "" && got.Status.Reason != tt.wantReason {
+				t.Errorf("wanted status reason %q, got %q", tt.wantReason, got.Status.Reason)
+			}
+			if tt.wantReason == reasonClassQuotaExceeded {
+				event := <-recorder.Events
+				if !strings.HasPrefix(event, corev1.EventTypeWarning+" "+reasonClassQuotaExceeded+" ") {
+					t.Errorf("wanted %s event with reason %q, got %q", corev1.EventTypeWarning, reasonClassQuotaExceeded, event)
+				}
+			}
+		})
+	}
+}
+
+func TestBucketNameCollision(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		wantErr     bool
+		wantPhase   v1alpha1.ObjectBucketClaimStatusPhase
+		wantReason  string
+		wantRenamed bool
+	}{
+		{
+			name:      "retried with the same name by default",
+			wantErr:   true,
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhasePending,
+		},
+		{
+			name:       "failed",
+			policy:     v1alpha1.BucketNameCollisionFail,
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason: reasonBucketNameCollision,
+		},
+		{
+			name:        "retried with a unique name",
+			policy:      v1alpha1.BucketNameCollisionUniquify,
+			wantErr:     true,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhasePending,
+			wantReason:  reasonBucketNameCollision,
+			wantRenamed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, second := newTestClaim("first"), newTestClaim("second")
+			for _, obc := range []*v1alpha1.ObjectBucketClaim{first, second} {
+				obc.Spec.GenerateBucketName = ""
+				obc.Spec.BucketName = "shared-bucket"
+			}
+			taken := map[string]bool{}
+			p := &fakeProvisioner{
+				provisionFunc: func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+					if taken[options.BucketName] {
+						return nil, bucketerrors.NewBucketExistsError("bucket exists")
+					}
+					taken[options.BucketName] = true
+					ob := newTestBucket()
+					ob.Spec.Endpoint.BucketName = options.BucketName
+					return ob, nil
+				},
+			}
+			parameters := map[string]string{}
+			if tt.policy != "" {
+				parameters[v1alpha1.StorageClassBucketNameCollision] = tt.policy
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(parameters)}, []runtime.Object{first, second})
+			recorder := record.NewFakeRecorder(10)
+
+			if _, err := c.syncHandler(testKey(first)); err != nil {
+				t.Fatalf("error syncing first claim: %v", err)
+			}
+			// the bucket is taken by the OB of the first claim
+			firstOB, err := c.objectBucketForClaimKey(testKey(first))
+			if err != nil {
+				t.Fatalf("error getting OB of first claim: %v", err)
+			}
+			obIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if err = obIndexer.Add(firstOB); err != nil {
+				t.Fatalf("error adding OB to informer: %v", err)
+			}
+			c.obLister = listers.NewObjectBucketLister(obIndexer)
+			c.recorder = recorder
+			_, err = c.syncHandler(testKey(second))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wantErr %v, error = %v", tt.wantErr, err)
+			}
+			waitForClaimPhase(t, c, second, tt.wantPhase)
+
+			select {
+			case event := <-recorder.Events:
+				if !strings.HasPrefix(event, corev1.EventTypeWarning+" "+tt.wantReason+" ") {
+					t.Errorf("wanted %s event with reason %q, got %q", corev1.EventTypeWarning, tt.wantReason, event)
+				}
+			default:
+				if tt.wantReason != "" {
+					t.Errorf("wanted event with reason %q, got none", tt.wantReason)
+				}
+			}
+
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(second.Namespace).Get(context.TODO(), second.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if renamed := got.Spec.BucketName != "shared-bucket"; renamed != tt.wantRenamed {
+				t.Fatalf("wanted claim renamed %v, got bucket name %q", tt.wantRenamed, got.Spec.BucketName)
+			}
+			if !tt.wantRenamed {
+				return
+			}
+			if !strings.HasPrefix(got.Spec.BucketName, "shared-bucket-") {
+				t.Errorf("wanted unique bucket name with prefix %q, got %q", "shared-bucket-", got.Spec.BucketName)
+			}
+			if _, err = c.syncHandler(testKey(second)); err != nil {
+				t.Fatalf("error retrying claim: %v", err)
+			}
+			waitForClaimPhase(t, c, second, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+		})
+	}
+}
+
+func TestAbandonedCallCleanup(t *testing.T) {
+	tests := []struct {
+		name        string
+		parameters  map[string]string
+		bound       bool
+		failed      bool
+		wantCleanup bool
+	}{
+		{
+			name:        "new bucket is cleaned up",
+			wantCleanup: true,
+		},
+		{
+			name:        "granted access is cleaned up",
+			parameters:  map[string]string{v1alpha1.StorageClassBucket: "existing-bucket"},
+			wantCleanup: true,
+		},
+		{
+			name:  "bucket of claim bound by a retry is kept",
+			bound: true,
+		},
+		{
+			name:   "failed call returns no bucket",
+			failed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			// the call returns only after the controller stopped waiting
+			unblock := make(chan struct{})
+			cleaned := make(chan *v1alpha1.ObjectBucket, 1)
+			failed := tt.failed
+			p := &fakeProvisioner{bucket: newTestBucket()}
+			p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+				<-unblock
+				if failed {
+					return nil, fmt.Errorf("abandoned")
+				}
+				return p.newBucket(options), nil
+			}
+			p.deleteFunc = func(ob *v1alpha1.ObjectBucket) error {
+				cleaned <- ob
+				return nil
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(tt.parameters)}, []runtime.Object{obc},
+				WithProvisionTimeout(10*time.Millisecond))
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseFailed)
+			if tt.bound {
+				ob := newTestBucket()
+				ob.Name = "obc-" + testNamespace + "-" + testName
+				if _, err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Create(context.TODO(), ob, metav1.CreateOptions{}); err != nil {
+					t.Fatalf("error creating OB: %v", err)
+				}
+			}
+			close(unblock)
+
+			select {
+			case ob := <-cleaned:
+				if !tt.wantCleanup {
+					t.Fatalf("wanted bucket not to be cleaned up, got %q cleaned up", ob.Spec.Endpoint.BucketName)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.wantCleanup {
+					t.Fatalf("wanted bucket returned after the timeout to be cleaned up")
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		options    []Option
+		delay      time.Duration
+		wantErr    bool
+	}{
+		{
+			name:    "provision timeout applies by default",
+			options: []Option{WithProvisionTimeout(10 * time.Millisecond)},
+			delay:   time.Hour,
+			wantErr: true,
+		},
+		{
+			name:    "delete timeout elapses",
+			options: []Option{WithDeleteTimeout(10 * time.Millisecond)},
+			delay:   time.Hour,
+			wantErr: true,
+		},
+		{
+			name:       "revoke timeout elapses",
+			parameters: map[string]string{v1alpha1.StorageClassBucket: "existing-bucket"},
+			options:    []Option{WithDeleteTimeout(10 * time.Millisecond)},
+			delay:      time.Hour,
+			wantErr:    true,
+		},
+		{
+			name:    "delete timeout exceeds provision timeout",
+			options: []Option{WithProvisionTimeout(10 * time.Millisecond), WithDeleteTimeout(time.Minute)},
+			delay:   50 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// unblock is closed at the end of the subtest to release provisioner calls left
+			// running, which must not read tt as it is reused by the next subtest
+			unblock := make(chan struct{})
+			defer close(unblock)
+			delay := tt.delay
+			p := &fakeProvisioner{
+				bucket: newTestBucket(),
+				deleteFunc: func(*v1alpha1.ObjectBucket) error {
+					select {
+					case <-time.After(delay):
+					case <-unblock:
+					}
+					return nil
+				},
+			}
+			obc := newTestClaim(testName)
+			c := newTestController(p, []runtime.Object{newTestStorageClass(tt.parameters)}, []runtime.Object{obc}, tt.options...)
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			now := metav1.Now()
+			bound.DeletionTimestamp = &now
+			if _, err = updateClaim(c.libClientset, bound); err != nil {
+				t.Fatalf("error deleting claim: %v", err)
+			}
+			for len(recorder.Events) > 0 {
+				<-recorder.Events
+			}
+
+			_, err = c.syncHandler(testKey(obc))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wantErr %v, error = %v", tt.wantErr, err)
+			}
+			timedOut := false
+			for len(recorder.Events) > 0 {
+				if strings.HasPrefix(<-recorder.Events, corev1.EventTypeWarning+" "+reasonDeprovisioningTimedOut+" ") {
+					timedOut = true
+				}
+			}
+			if timedOut != tt.wantErr {
+				t.Errorf("wanted %s event %v, got %v", reasonDeprovisioningTimedOut, tt.wantErr, timedOut)
+			}
+		})
+	}
+}
+func TestRetryFailedClaimAfterSpecEdit(t *testing.T) {
+	invalidClass := newTestStorageClass(map[string]string{v1alpha1.StorageClassProvisionTimeout: "soon"})
+	invalidClass.Name = "invalid-class"
+	obc := newTestClaim(testName)
+	obc.Spec.StorageClassName = invalidClass.Name
+
+	c := newTestController(
+		&fakeProvisioner{bucket: newTestBucket()},
+		[]runtime.Object{invalidClass, newTestStorageClass(nil)},
+		[]runtime.Object{obc})
+	c.recorder = record.NewFakeRecorder(10)
+
+	if _, err := c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseFailed)
+
+	failed, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	edited := failed.DeepCopy()
+	edited.Spec.StorageClassName = className
+	if !updateSupported(failed, edited) {
+		t.Fatal("expected spec edit of failed claim to be supported")
+	}
+	if _, err = updateClaim(c.libClientset, edited); err != nil {
+		t.Fatalf("error updating claim: %v", err)
+	}
+
+	outcome, err := c.syncHandler(testKey(obc))
+	if err != nil {
+		t.Fatalf("error syncing edited claim: %v", err)
+	}
+	if outcome != outcomeProvisioned {
+		t.Errorf("wanted outcome %q, got %q", outcomeProvisioned, outcome)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+}
+
+func TestProvisionWithoutAuthentication(t *testing.T) {
+	bucket := newTestBucket()
+	bucket.Spec.Authentication = nil
+	obc := newTestClaim(testName)
+	c := newTestController(
+		&fakeProvisioner{bucket: bucket},
+		[]runtime.Object{newTestStorageClass(map[string]string{v1alpha1.StorageClassBucket: "public-bucket"})},
+		[]runtime.Object{obc})
+
+	if _, err := c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+	if _, err := secretForClaimKey(testKey(obc), c.clientset); !errors.IsNotFound(err) {
+		t.Errorf("expected no secret to be generated, got err %v", err)
+	}
+	if _, err := configMapForClaimKey(testKey(obc), c.clientset); err != nil {
+		t.Errorf("expected configmap to be generated: %v", err)
+	}
+	bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if !meta.IsStatusConditionFalse(bound.Status.Conditions, v1alpha1.ObjectBucketClaimConditionSecretGenerated) {
+		t.Errorf("expected condition %q to be false, got %v", v1alpha1.ObjectBucketClaimConditionSecretGenerated, bound.Status.Conditions)
+	}
+
+	// deleting the claim must tolerate the missing secret
+	now := metav1.Now()
+	bound.DeletionTimestamp = &now
+	if _, err = updateClaim(c.libClientset, bound); err != nil {
+		t.Fatalf("error deleting claim: %v", err)
+	}
+	outcome, err := c.syncHandler(testKey(obc))
+	if err != nil {
+		t.Fatalf("error syncing deleted claim: %v", err)
+	}
+	if outcome != outcomeRevoked {
+		t.Errorf("wanted outcome %q, got %q", outcomeRevoked, outcome)
+	}
+}
+
+func TestDeletionPriority(t *testing.T) {
+	const numClaims = 5
+
+	deleted := newTestClaim("deleted")
+	now := metav1.Now()
+	deleted.DeletionTimestamp = &now
+	deleted.Finalizers = []string{finalizer}
+	libObjects := []runtime.Object{deleted}
+	for i := 0; i < numClaims; i++ {
+		libObjects = append(libObjects, newTestClaim(fmt.Sprintf("%s-%d", testName, i)))
+	}
+	c := newTestController(
+		&fakeProvisioner{bucket: newTestBucket()},
+		[]runtime.Object{newTestStorageClass(nil)},
+		libObjects,
+		WithDeletionPriority())
+
+	for _, obj := range libObjects[1:] {
+		c.queue.Add(testKey(obj.(*v1alpha1.ObjectBucketClaim)))
+	}
+	c.enqueueOBC(deleted)
+
+	if !c.processNextItemInQueue(0) {
+		t.Fatal("queue unexpectedly shut down")
+	}
+	if got := c.priorityQueue.Len(); got != 0 {
+		t.Errorf("expected deletion to be processed first, %d keys remain in priority queue", got)
+	}
+	// the creations and the wake token remain queued
+	if got := c.queue.Len(); got != numClaims+1 {
+		t.Errorf("expected %d keys to remain queued, got %d", numClaims+1, got)
+	}
+	got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(deleted.Namespace).Get(context.TODO(), deleted.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if len(got.Finalizers) != 0 {
+		t.Errorf("expected deleted claim to be released, got finalizers %v", got.Finalizers)
+	}
+
+	// draining the queue processes the creations and consumes the wake token
+	for c.queue.Len() > 0 {
+		c.processNextItemInQueue(0)
+	}
+	for _, obj := range libObjects[1:] {
+		waitForClaimPhase(t, c, obj.(*v1alpha1.ObjectBucketClaim), v1alpha1.ObjectBucketClaimStatusPhaseBound)
+	}
+}
+
+func TestFailedDeletionRequeuedOnPriorityQueue(t *testing.T) {
+	deleted := newTestClaim("deleted")
+	now := metav1.Now()
+	deleted.DeletionTimestamp = &now
+	deleted.Finalizers = []string{finalizer}
+	c := newTestController(
+		&fakeProvisioner{bucket: newTestBucket()},
+		[]runtime.Object{newTestStorageClass(nil)},
+		[]runtime.Object{deleted},
+		WithDeletionPriority())
+	var failed int32
+	c.libClientset.(*externalFake.Clientset).PrependReactor("update", "objectbucketclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if atomic.CompareAndSwapInt32(&failed, 0, 1) {
+			return true, nil, fmt.Errorf("update failed")
+		}
+		return false, nil, nil
+	})
+	key := testKey(deleted)
+
+	c.enqueueOBC(deleted)
+	if !c.processNextItemInQueue(0) {
+		t.Fatal("queue unexpectedly shut down")
+	}
+	if n := c.priorityLimiter.NumRequeues(key); n != 1 {
+		t.Errorf("expected failed deletion to be rate limited by the priority queue, got %d requeues", n)
+	}
+	if n := c.queue.NumRequeues(key); n != 0 {
+		t.Errorf("expected failed deletion not to be rate limited by queue, got %d requeues", n)
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+		return c.priorityQueue.Len() == 1, nil
+	}); err != nil {
+		t.Fatal("expected failed deletion to be requeued on the priority queue")
+	}
+
+	// the retry is taken from the priority queue ahead of the wake tokens
+	if !c.processNextItemInQueue(0) {
+		t.Fatal("queue unexpectedly shut down")
+	}
+	got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(deleted.Namespace).Get(context.TODO(), deleted.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if len(got.Finalizers) != 0 {
+		t.Errorf("expected deleted claim to be released, got finalizers %v", got.Finalizers)
+	}
+	if n := c.priorityLimiter.NumRequeues(key); n != 0 {
+		t.Errorf("expected backoff to be reset after the deletion succeeded, got %d requeues", n)
+	}
+}
+
+func TestProvisionWithDefaultStorageClass(t *testing.T) {
+	class := newTestStorageClass(nil)
+	class.Annotations = map[string]string{v1alpha1.DefaultStorageClassAnnotation: "true"}
+	obc := newTestClaim(testName)
+	obc.Spec.StorageClassName = ""
+	c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{class}, []runtime.Object{obc})
+
+	if _, err := c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+	got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if got.Spec.StorageClassName != class.Name {
+		t.Errorf("expected default StorageClass %q to be recorded in claim, got %q", class.Name, got.Spec.StorageClassName)
+	}
+}
+
+func TestUpdateClaim(t *testing.T) {
+	oldConfig := map[string]string{"tenant": "a", "quota": "1Gi"}
+	newConfig := map[string]string{"tenant": "b", "quota": "1Gi", "region": "eu"}
+
+	tests := []struct {
+		name          string
+		degraded      bool
+		updateErr     error
+		revertErr     error
+		obUpdateErr   error
+		wantErr       bool
+		wantConfig    map[string]string
+		wantDegraded  bool
+		wantEventKeys string
+	}{
+		{
+			name:       "update succeeds",
+			wantConfig: newConfig,
+		},
+		{
+			name:       "update succeeds and clears degraded condition",
+			degraded:   true,
+			wantConfig: newConfig,
+		},
+		{
+			name:       "provisioner update fails",
+			updateErr:  fmt.Errorf("backend unavailable"),
+			wantErr:    true,
+			wantConfig: oldConfig,
+		},
+		{
+			name:        "OB update fails and is reverted",
+			obUpdateErr: fmt.Errorf("conflict"),
+			wantErr:     true,
+			wantConfig:  oldConfig,
+		},
+		{
+			name:          "OB update fails and revert fails",
+			obUpdateErr:   fmt.Errorf("conflict"),
+			revertErr:     fmt.Errorf("backend unavailable"),
+			wantErr:       true,
+			wantConfig:    oldConfig,
+			wantDegraded:  true,
+			wantEventKeys: "[region tenant]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			obc.Spec.AdditionalConfig = newConfig
+			obc.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhaseBound
+			if tt.degraded {
+				setUpdateDegradedCondition(obc, "degraded", time.Now())
+			}
+			ob := newTestBucket()
+			ob.Name = "obc-" + testNamespace + "-" + testName
+			ob.Spec.StorageClassName = className
+			ob.Spec.Endpoint.AdditionalConfigData = oldConfig
+
+			calls := 0
+			p := &fakeUpdater{
+				updateFunc: func(ob *v1alpha1.ObjectBucket) error {
+					calls++
+					if calls == 1 {
+						return tt.updateErr
+					}
+					return tt.revertErr
+				},
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil), newTestConfigMap(t, obc, ob)}, []runtime.Object{obc, ob})
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+			if tt.obUpdateErr != nil {
+				c.libClientset.(*externalFake.Clientset).PrependReactor("update", "objectbuckets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, tt.obUpdateErr
+				})
+			}
+
+			outcome, err := c.syncHandler(testKey(obc))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wantErr %v, error = %v", tt.wantErr, err)
+			}
+			if err == nil && outcome != outcomeUpdated {
+				t.Errorf("wanted outcome %q, got %q", outcomeUpdated, outcome)
+			}
+
+			gotOB, err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), ob.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting OB: %v", err)
+			}
+			if !reflect.DeepEqual(gotOB.Spec.Endpoint.AdditionalConfigData, tt.wantConfig) {
+				t.Errorf("wanted OB config %v, got %v", tt.wantConfig, gotOB.Spec.Endpoint.AdditionalConfigData)
+			}
+			gotOBC, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if got := meta.IsStatusConditionTrue(gotOBC.Status.Conditions, v1alpha1.ObjectBucketClaimConditionUpdateDegraded); got != tt.wantDegraded {
+				t.Errorf("wanted condition %q %v, got %v", v1alpha1.ObjectBucketClaimConditionUpdateDegraded, tt.wantDegraded, got)
+			}
+
+			updated := !tt.wantErr
+			if got, want := gotOBC.Annotations[lastConfigDiffAnnotation], `{"added":["region"],"modified":["tenant"]}`; updated && got != want {
+				t.Errorf("wanted annotation %q to be %s, got %q", lastConfigDiffAnnotation, want, got)
+			} else if !updated && got != "" {
+				t.Errorf("unexpected annotation %q: %q", lastConfigDiffAnnotation, got)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				switch {
+				case updated:
+					if want := corev1.EventTypeNormal + " " + reasonAdditionalConfigUpdated + " additionalConfig updated: added [region], removed [], modified [tenant]"; event != want {
+						t.Errorf("wanted event %q, got %q", want, event)
+					}
+				case !tt.wantDegraded:
+					t.Errorf("unexpected event %q", event)
+				case !strings.HasPrefix(event, corev1.EventTypeWarning+" "+reasonUpdateDegraded+" ") || !strings.Contains(event, tt.wantEventKeys):
+					t.Errorf("wanted %s event with reason %q naming keys %s, got %q", corev1.EventTypeWarning, reasonUpdateDegraded, tt.wantEventKeys, event)
+				}
+			default:
+				if updated {
+					t.Errorf("wanted event with reason %q, got none", reasonAdditionalConfigUpdated)
+				} else if tt.wantDegraded {
+					t.Errorf("wanted event with reason %q, got none", reasonUpdateDegraded)
+				}
+			}
+		})
+	}
+}
+
+func TestBrownfieldUpdate(t *testing.T) {
+	tests := []struct {
+		name        string
+		parameters  map[string]string
+		wantUpdated bool
+		wantReason  string
+	}{
+		{
+			name:       "rejected by default",
+			parameters: map[string]string{v1alpha1.StorageClassBucket: "existing-bucket"},
+			wantReason: reasonBrownfieldUpdateRejected,
+		},
+		{
+			name: "allowed by class",
+			parameters: map[string]string{
+				v1alpha1.StorageClassBucket:                 "existing-bucket",
+				v1alpha1.StorageClassAllowBrownfieldUpdates: "true",
+			},
+			wantUpdated: true,
+			wantReason:  reasonAdditionalConfigUpdated,
+		},
+		{
+			name: "invalid parameter",
+			parameters: map[string]string{
+				v1alpha1.StorageClassBucket:                 "existing-bucket",
+				v1alpha1.StorageClassAllowBrownfieldUpdates: "sometimes",
+			},
+			wantReason: reasonInvalidParameter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			obc.Spec.AdditionalConfig = map[string]string{"tenant": "b"}
+			obc.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhaseBound
+			ob := newTestBucket()
+			ob.Name = "obc-" + testNamespace + "-" + testName
+			ob.Spec.StorageClassName = className
+			ob.Spec.Endpoint.BucketName = "existing-bucket"
+			ob.Spec.Endpoint.AdditionalConfigData = map[string]string{"tenant": "a"}
+
+			updated := false
+			p := &fakeUpdater{
+				updateFunc: func(ob *v1alpha1.ObjectBucket) error {
+					updated = true
+					return nil
+				},
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(tt.parameters), newTestConfigMap(t, obc, ob)}, []runtime.Object{obc, ob})
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != tt.wantUpdated {
+				t.Errorf("wanted Update called %v, got %v", tt.wantUpdated, updated)
+			}
+			wantOutcome := outcomeFailedTerminal
+			wantConfig := ob.Spec.Endpoint.AdditionalConfigData
+			if tt.wantUpdated {
+				wantOutcome = outcomeUpdated
+				wantConfig = obc.Spec.AdditionalConfig
+			}
+			if outcome != wantOutcome {
+				t.Errorf("wanted outcome %q, got %q", wantOutcome, outcome)
+			}
+
+			gotOB, err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), ob.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting OB: %v", err)
+			}
+			if !reflect.DeepEqual(gotOB.Spec.Endpoint.AdditionalConfigData, wantConfig) {
+				t.Errorf("wanted OB config %v, got %v", wantConfig, gotOB.Spec.Endpoint.AdditionalConfigData)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if !strings.Contains(event, " "+tt.wantReason+" ") {
+					t.Errorf("wanted event with reason %q, got %q", tt.wantReason, event)
+				}
+			default:
+				t.Errorf("wanted event with reason %q, got none", tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestProvisionerVersion(t *testing.T) {
+	const version = "v1.2.3-test"
+
+	obc := newTestClaim(testName)
+	c := newTestController(
+		&fakeProvisioner{bucket: newTestBucket()},
+		[]runtime.Object{newTestStorageClass(nil)},
+		[]runtime.Object{obc},
+		WithProvisionerVersion(version))
+
+	if got := testutil.ToFloat64(provisionerInfo.WithLabelValues(version)); got != 1 {
+		t.Errorf("wanted provisioner info metric for version %q to be 1, got %v", version, got)
+	}
+
+	if _, err := c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+	gotOBC, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	gotOB, err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), gotOBC.Spec.ObjectBucketName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting OB: %v", err)
+	}
+	gotSecret, err := secretForClaimKey(testKey(obc), c.clientset)
+	if err != nil {
+		t.Fatalf("error getting secret: %v", err)
+	}
+	gotConfigMap, err := configMapForClaimKey(testKey(obc), c.clientset)
+	if err != nil {
+		t.Fatalf("error getting configmap: %v", err)
+	}
+	for _, obj := range []metav1.Object{gotOBC, gotOB, gotSecret, gotConfigMap} {
+		if got := obj.GetAnnotations()[provisionerVersionAnnotation]; got != version {
+			t.Errorf("wanted %q annotation %q on %s, got %q", provisionerVersionAnnotation, version, obj.GetName(), got)
+		}
+	}
+}
+
+func TestReconcileChildren(t *testing.T) {
+	obc := newTestClaim(testName)
+	c := newTestController(
+		&fakeProvisioner{bucket: newTestBucket()},
+		[]runtime.Object{newTestStorageClass(nil)},
+		[]runtime.Object{obc},
+		WithReconcileChildren())
+
+	if _, err := c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+	if err := c.obcInformer.Informer().GetIndexer().Add(obc); err != nil {
+		t.Fatalf("error adding claim to informer: %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c.childInformerFactory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, c.childrenHaveSynced...) {
+		t.Fatal("failed to sync informers")
+	}
+
+	// tamper with the generated resources. The fake clientset does not bump resource versions.
+	secret, err := secretForClaimKey(testKey(obc), c.clientset)
+	if err != nil {
+		t.Fatalf("error getting secret: %v", err)
+	}
+	wantSecretData := secret.StringData
+	secret.StringData = map[string]string{v1alpha1.AwsKeyField: "tampered"}
+	secret.Data = map[string][]byte{"extra": []byte("value")}
+	secret.Annotations = map[string]string{"user": "note"}
+	secret.ResourceVersion = "tampered"
+	if _, err = c.clientset.CoreV1().Secrets(secret.Namespace).Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("error updating secret: %v", err)
+	}
+	configMap, err := configMapForClaimKey(testKey(obc), c.clientset)
+	if err != nil {
+		t.Fatalf("error getting configmap: %v", err)
+	}
+	wantConfigMapData := configMap.Data
+	configMap.Data = map[string]string{bucketHost: "tampered"}
+	configMap.ResourceVersion = "tampered"
+	if _, err = c.clientset.CoreV1().ConfigMaps(configMap.Namespace).Update(context.TODO(), configMap, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("error updating configmap: %v", err)
+	}
+
+	err = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		if c.queue.Len() > 0 {
+			c.processNextItemInQueue(0)
+		}
+		secret, err = secretForClaimKey(testKey(obc), c.clientset)
+		if err != nil {
+			return false, err
+		}
+		configMap, err = configMapForClaimKey(testKey(obc), c.clientset)
+		if err != nil {
+			return false, err
+		}
+		return reflect.DeepEqual(secret.StringData, wantSecretData) && reflect.DeepEqual(configMap.Data, wantConfigMapData), nil
+	})
+	if err != nil {
+		t.Fatalf("generated resources were not restored: %v", err)
+	}
+	if len(secret.Data) != 0 {
+		t.Errorf("expected unexpected secret data to be removed, got %v", secret.Data)
+	}
+	if got := secret.Annotations["user"]; got != "note" {
+		t.Errorf("expected user annotation to be preserved, got %q", got)
+	}
+}
+
+func TestStuckClaimBackoff(t *testing.T) {
+	tests := []struct {
+		name          string
+		age           time.Duration
+		wantBackedOff bool
+	}{
+		{
+			name: "young claim is rate limited",
+			age:  time.Minute,
+		},
+		{
+			name:          "old claim is backed off",
+			age:           2 * time.Hour,
+			wantBackedOff: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the storage class is missing, so the claim fails to sync
+			created := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+			clock := testingclock.NewFakeClock(created)
+			clock.Step(tt.age)
+			obc := newTestClaim(testName)
+			obc.CreationTimestamp = metav1.NewTime(created)
+			c := newTestController(
+				&fakeProvisioner{bucket: newTestBucket()},
+				nil,
+				[]runtime.Object{obc},
+				WithStuckClaimBackoff(time.Hour, time.Hour),
+				WithClock(clock))
+
+			key := testKey(obc)
+			c.queue.Add(key)
+			if !c.processNextItemInQueue(0) {
+				t.Fatal("queue unexpectedly shut down")
+			}
+
+			// only keys requeued by the rate limiter are counted as requeues
+			if rateLimited := c.queue.NumRequeues(key) > 0; rateLimited == tt.wantBackedOff {
+				t.Errorf("wanted rate limited requeue %v, got %v", !tt.wantBackedOff, rateLimited)
+			}
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if backedOff := meta.IsStatusConditionTrue(got.Status.Conditions, v1alpha1.ObjectBucketClaimConditionBackedOff); backedOff != tt.wantBackedOff {
+				t.Errorf("wanted condition %q %v, got %v", v1alpha1.ObjectBucketClaimConditionBackedOff, tt.wantBackedOff, backedOff)
+			}
+			if cond := meta.FindStatusCondition(got.Status.Conditions, v1alpha1.ObjectBucketClaimConditionBackedOff); cond != nil && !cond.LastTransitionTime.Time.Equal(clock.Now()) {
+				t.Errorf("wanted condition transition at %v, got %v", clock.Now(), cond.LastTransitionTime)
+			}
+		})
+	}
+}
+
+func TestProvisionerStorageClassName(t *testing.T) {
+	const obClassName = "logical-class"
+
+	bucket := newTestBucket()
+	bucket.Spec.StorageClassName = obClassName
+	obc := newTestClaim(testName)
+	c := newTestController(
+		&fakeProvisioner{bucket: bucket},
+		[]runtime.Object{newTestStorageClass(nil)},
+		[]runtime.Object{obc})
+
+	if _, err := c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+	ob, err := c.objectBucketForClaimKey(testKey(obc))
+	if err != nil {
+		t.Fatalf("error getting OB: %v", err)
+	}
+	if ob.Spec.StorageClassName != obClassName {
+		t.Errorf("wanted OB StorageClass %q, got %q", obClassName, ob.Spec.StorageClassName)
+	}
+
+	// the claim is not mistaken as modified when synced again
+	if _, err = c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing bound claim: %v", err)
+	}
+
+	// the claim's storage class decides that the greenfield bucket is deleted
+	bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	now := metav1.Now()
+	bound.DeletionTimestamp = &now
+	if _, err = updateClaim(c.libClientset, bound); err != nil {
+		t.Fatalf("error deleting claim: %v", err)
+	}
+	outcome, err := c.syncHandler(testKey(obc))
+	if err != nil {
+		t.Fatalf("error syncing deleted claim: %v", err)
+	}
+	if outcome != outcomeDeleted {
+		t.Errorf("wanted outcome %q, got %q", outcomeDeleted, outcome)
+	}
+}
+
+func TestPreProvision(t *testing.T) {
+	const billingAnnotation = "billing.example.com/account"
+
+	requireBilling := func(obc *v1alpha1.ObjectBucketClaim, namespace *corev1.Namespace) error {
+		if _, ok := namespace.Annotations[billingAnnotation]; !ok {
+			return fmt.Errorf("namespace %q has no %q annotation", namespace.Name, billingAnnotation)
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantPhase   v1alpha1.ObjectBucketClaimStatusPhase
+	}{
+		{
+			name:        "namespace meets precondition",
+			annotations: map[string]string{billingAnnotation: "1234"},
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:      "namespace fails precondition",
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: testNamespace, Annotations: tt.annotations},
+			}
+			obc := newTestClaim(testName)
+			p := &fakePreProvisioner{
+				fakeProvisioner:  fakeProvisioner{bucket: newTestBucket()},
+				preProvisionFunc: requireBilling,
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil), namespace}, []runtime.Object{obc})
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+
+			if tt.wantPhase != v1alpha1.ObjectBucketClaimStatusPhaseFailed {
+				return
+			}
+			select {
+			case event := <-recorder.Events:
+				if !strings.HasPrefix(event, corev1.EventTypeWarning+" "+reasonPreconditionFailed+" ") || !strings.Contains(event, billingAnnotation) {
+					t.Errorf("wanted %s event with reason %q and the hook's message, got %q", corev1.EventTypeWarning, reasonPreconditionFailed, event)
+				}
+			default:
+				t.Errorf("wanted event with reason %q, got none", reasonPreconditionFailed)
+			}
+		})
+	}
+}
+
+func TestPendingReason(t *testing.T) {
+	tests := []struct {
+		name             string
+		kubeObjects      []runtime.Object
+		wantPhase        v1alpha1.ObjectBucketClaimStatusPhase
+		wantReason       string
+		wantProvisioning bool
+	}{
+		{
+			name:             "provisioning bucket",
+			kubeObjects:      []runtime.Object{newTestStorageClass(nil)},
+			wantPhase:        v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantProvisioning: true,
+		},
+		{
+			name:       "waiting for storage class",
+			wantReason: reasonWaitingForStorageClass,
+		},
+		{
+			name:        "invalid parameter",
+			kubeObjects: []runtime.Object{newTestStorageClass(map[string]string{v1alpha1.StorageClassProvisionTimeout: "soon"})},
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason:  reasonInvalidParameter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			p := &fakeProvisioner{}
+			c := newTestController(p, tt.kubeObjects, []runtime.Object{obc})
+			c.recorder = record.NewFakeRecorder(10)
+
+			// capture the claim's status as seen while the bucket is being provisioned
+			var provisioning v1alpha1.ObjectBucketClaimStatus
+			p.provisionFunc = func(*api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+				got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+				if err != nil {
+					return nil, err
+				}
+				provisioning = got.Status
+				return newTestBucket(), nil
+			}
+
+			_, _ = c.syncHandler(testKey(obc))
+
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if got.Status.Phase != tt.wantPhase || got.Status.Reason != tt.wantReason {
+				t.Errorf("wanted phase %q and reason %q, got %q and %q", tt.wantPhase, tt.wantReason, got.Status.Phase, got.Status.Reason)
+			}
+			if tt.wantReason != "" && got.Status.Message == "" {
+				t.Errorf("wanted a status message with reason %q", tt.wantReason)
+			}
+			if tt.wantProvisioning && (provisioning.Phase != v1alpha1.ObjectBucketClaimStatusPhasePending || provisioning.Reason != reasonProvisioningBucket) {
+				t.Errorf("wanted phase %q and reason %q while provisioning, got %q and %q",
+					v1alpha1.ObjectBucketClaimStatusPhasePending, reasonProvisioningBucket, provisioning.Phase, provisioning.Reason)
+			}
+		})
+	}
+}
+
+func TestNamespaceDeletionRetries(t *testing.T) {
+	const retries = 3
+
+	tests := []struct {
+		name         string
+		phase        corev1.NamespacePhase
+		wantReleased bool
+	}{
+		{
+			name:         "terminating namespace",
+			phase:        corev1.NamespaceTerminating,
+			wantReleased: true,
+		},
+		{
+			name:  "active namespace",
+			phase: corev1.NamespaceActive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: testNamespace},
+				Status:     corev1.NamespaceStatus{Phase: tt.phase},
+			}
+			obc := newTestClaim(testName)
+			p := &fakeProvisioner{bucket: newTestBucket()}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil), namespace}, []runtime.Object{obc},
+				WithNamespaceDeletionRetries(retries))
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error provisioning claim: %v", err)
+			}
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			now := metav1.Now()
+			bound.DeletionTimestamp = &now
+			if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Update(context.TODO(), bound, metav1.UpdateOptions{}); err != nil {
+				t.Fatalf("error deleting claim: %v", err)
+			}
+
+			// the object store is unreachable
+			p.deleteFunc = func(*v1alpha1.ObjectBucket) error { return fmt.Errorf("connection refused") }
+			var outcome reconcileOutcome
+			for i := 0; i <= retries; i++ {
+				// the failures are counted even though the queue forgets the claim, eg. when it is
+				// requeued after a delay
+				if outcome, err = c.syncHandler(testKey(obc)); err != nil {
+					c.queue.Forget(testKey(obc))
+				}
+			}
+
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if released := len(got.Finalizers) == 0; released != tt.wantReleased {
+				t.Errorf("wanted claim released %v, got finalizers %v", tt.wantReleased, got.Finalizers)
+			}
+			if !tt.wantReleased {
+				return
+			}
+			if outcome != outcomeAbandoned {
+				t.Errorf("wanted outcome %q, got %q", outcomeAbandoned, outcome)
+			}
+			if _, err = c.objectBucketForClaimKey(testKey(obc)); err != nil {
+				t.Errorf("expected the OB of the leaked bucket to be kept: %v", err)
+			}
+			// the Released events of the claim's OB precede the BucketLeaked event
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			if len(events) == 0 || !strings.HasPrefix(events[len(events)-1], corev1.EventTypeWarning+" "+reasonBucketLeaked+" ") {
+				t.Errorf("wanted %s event with reason %q, got %q", corev1.EventTypeWarning, reasonBucketLeaked, events)
+			}
+		})
+	}
+}
+
+func TestBucketClass(t *testing.T) {
+	retain := corev1.PersistentVolumeReclaimRetain
+	maxObjects := int64(1000)
+	gold := &v1alpha1.BucketClass{
+		ObjectMeta:    metav1.ObjectMeta{Name: "gold"},
+		Parameters:    map[string]string{"tier": "gold", "region": "eu"},
+		ReclaimPolicy: &retain,
+		Quota:         &v1alpha1.BucketQuota{MaxObjects: &maxObjects},
+	}
+	invalid := &v1alpha1.BucketClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "invalid"},
+		Parameters: map[string]string{v1alpha1.StorageClassBucket: "shared"},
+	}
+	bucketClasses := map[string]*v1alpha1.BucketClass{gold.Name: gold, invalid.Name: invalid}
+
+	tests := []struct {
+		name            string
+		bucketClassName string
+		wantErr         bool
+		wantPhase       v1alpha1.ObjectBucketClaimStatusPhase
+		wantParameters  map[string]string
+		wantReclaim     corev1.PersistentVolumeReclaimPolicy
+		wantQuota       *v1alpha1.BucketQuota
+	}{
+		{
+			name:           "storage class only",
+			wantPhase:      v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantParameters: map[string]string{"tier": "silver"},
+			wantReclaim:    corev1.PersistentVolumeReclaimDelete,
+		},
+		{
+			name:            "bucket class overrides storage class",
+			bucketClassName: gold.Name,
+			wantPhase:       v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantParameters:  map[string]string{"tier": "gold", "region": "eu"},
+			wantReclaim:     corev1.PersistentVolumeReclaimRetain,
+			wantQuota:       gold.Quota,
+		},
+		{
+			name:            "missing bucket class",
+			bucketClassName: "missing",
+			wantErr:         true,
+			wantPhase:       v1alpha1.ObjectBucketClaimStatusPhasePending,
+		},
+		{
+			name:            "bucket class sets bucket name",
+			bucketClassName: invalid.Name,
+			wantPhase:       v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			obc.Spec.BucketClassName = tt.bucketClassName
+			var options *api.BucketOptions
+			p := &fakeProvisioner{
+				provisionFunc: func(o *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+					options = o
+					return newTestBucket(), nil
+				},
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(map[string]string{"tier": "silver"})}, []runtime.Object{obc})
+			c.recorder = record.NewFakeRecorder(10)
+			c.getBucketClass = func(name string) (*v1alpha1.BucketClass, error) {
+				if bucketClass, ok := bucketClasses[name]; ok {
+					return bucketClass.DeepCopy(), nil
+				}
+				return nil, errors.NewNotFound(v1alpha1.Resource("bucketclasses"), name)
+			}
+
+			_, err := c.syncHandler(testKey(obc))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wanted error %v, got %v", tt.wantErr, err)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+			if tt.wantPhase != v1alpha1.ObjectBucketClaimStatusPhaseBound {
+				if options != nil {
+					t.Errorf("expected the bucket not to be provisioned")
+				}
+				return
+			}
+			if !reflect.DeepEqual(options.Parameters, tt.wantParameters) {
+				t.Errorf("wanted parameters %v, got %v", tt.wantParameters, options.Parameters)
+			}
+			if !reflect.DeepEqual(options.Quota, tt.wantQuota) {
+				t.Errorf("wanted quota %v, got %v", tt.wantQuota, options.Quota)
+			}
+			ob, err := c.objectBucketForClaimKey(testKey(obc))
+			if err != nil {
+				t.Fatalf("error getting OB: %v", err)
+			}
+			if *ob.Spec.ReclaimPolicy != tt.wantReclaim {
+				t.Errorf("wanted reclaim policy %q, got %q", tt.wantReclaim, *ob.Spec.ReclaimPolicy)
+			}
+		})
+	}
+}
+
+func TestStuckPendingCheck(t *testing.T) {
+	const maxAge = time.Hour
+	old := metav1.NewTime(time.Now().Add(-2 * maxAge))
+
+	newClaim := func(name string, phase v1alpha1.ObjectBucketClaimStatusPhase, created metav1.Time) *v1alpha1.ObjectBucketClaim {
+		obc := newTestClaim(name)
+		obc.CreationTimestamp = created
+		obc.Status.Phase = phase
+		return obc
+	}
+	stuck := newClaim("stuck", v1alpha1.ObjectBucketClaimStatusPhasePending, old)
+	claims := []*v1alpha1.ObjectBucketClaim{
+		stuck,
+		newClaim("recent", v1alpha1.ObjectBucketClaimStatusPhasePending, metav1.Now()),
+		newClaim("provisioned", v1alpha1.ObjectBucketClaimStatusPhasePending, old),
+		newClaim("bound", v1alpha1.ObjectBucketClaimStatusPhaseBound, old),
+	}
+
+	c := newTestController(&fakeProvisioner{}, []runtime.Object{newTestStorageClass(nil)}, nil, WithStuckPendingCheck(maxAge, time.Minute))
+	recorder := record.NewFakeRecorder(10)
+	c.recorder = recorder
+	for _, obc := range claims {
+		if err := c.obcInformer.Informer().GetIndexer().Add(obc); err != nil {
+			t.Fatalf("error adding claim to informer: %v", err)
+		}
+	}
+	// only the provisioned claim has an OB
+	obIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	obName, _ := objectBucketNameFromClaimKey(testNamespace + "/provisioned")
+	if err := obIndexer.Add(&v1alpha1.ObjectBucket{ObjectMeta: metav1.ObjectMeta{Name: obName}}); err != nil {
+		t.Fatalf("error adding OB to informer: %v", err)
+	}
+	c.obLister = listers.NewObjectBucketLister(obIndexer)
+
+	c.checkStuckPendingClaims()
+
+	if got := testutil.ToFloat64(stuckPendingClaims); got != 1 {
+		t.Errorf("wanted 1 stuck claim, got %v", got)
+	}
+	if got := c.queue.Len(); got != 1 {
+		t.Fatalf("wanted 1 claim to be requeued, got %d", got)
+	}
+	if key, _ := c.queue.Get(); key != testKey(stuck) {
+		t.Errorf("wanted claim %q to be requeued, got %v", testKey(stuck), key)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.HasPrefix(event, corev1.EventTypeWarning+" "+reasonStuckPending+" ") {
+			t.Errorf("wanted %s event with reason %q, got %q", corev1.EventTypeWarning, reasonStuckPending, event)
+		}
+	default:
+		t.Errorf("wanted event with reason %q, got none", reasonStuckPending)
+	}
+}
+
+func TestUnboundResync(t *testing.T) {
+	// the claim was reconciled before its storage class was created, and left Pending, while the failed
+	// claim is only retried once edited
+	stale := newTestClaimInPhase("stale", v1alpha1.ObjectBucketClaimStatusPhasePending, metav1.Now())
+	failed := newTestClaimInPhase("failed", v1alpha1.ObjectBucketClaimStatusPhaseFailed, metav1.Now())
+	bound := newTestClaimInPhase("bound", v1alpha1.ObjectBucketClaimStatusPhaseBound, metav1.Now())
+	other := newTestClaimInPhase("other", v1alpha1.ObjectBucketClaimStatusPhasePending, metav1.Now())
+	other.Spec.StorageClassName = "other"
+	otherClass := newTestStorageClass(nil)
+	otherClass.Name, otherClass.Provisioner = "other", "other.io/bucket"
+
+	c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{otherClass}, []runtime.Object{stale}, WithUnboundResync(time.Minute))
+	for _, obc := range []*v1alpha1.ObjectBucketClaim{stale, failed, bound, other} {
+		if err := c.obcInformer.Informer().GetIndexer().Add(obc); err != nil {
+			t.Fatalf("error adding claim to informer: %v", err)
+		}
+	}
+	if _, err := c.clientset.StorageV1().StorageClasses().Create(context.TODO(), newTestStorageClass(nil), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("error creating StorageClass: %v", err)
+	}
+
+	c.resyncUnboundClaims()
+
+	var requeued []string
+	for c.queue.Len() > 0 {
+		key, _ := c.queue.Get()
+		requeued = append(requeued, key.(string))
+		c.queue.Done(key)
+	}
+	sort.Strings(requeued)
+	if want := []string{testKey(stale)}; !reflect.DeepEqual(requeued, want) {
+		t.Fatalf("wanted claims %v to be requeued, got %v", want, requeued)
+	}
+
+	if _, err := c.syncHandler(testKey(stale)); err != nil {
+		t.Fatalf("error syncing requeued claim: %v", err)
+	}
+	waitForClaimPhase(t, c, stale, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+}
+
+func TestBucketExistenceCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		parameters   map[string]string
+		exists       bool
+		reprovision  bool
+		wantMissing  bool
+		wantRequeued bool
+	}{
+		{
+			name:   "bucket exists",
+			exists: true,
+		},
+		{
+			name:        "bucket is missing",
+			wantMissing: true,
+		},
+		{
+			name:         "missing new bucket is provisioned again",
+			reprovision:  true,
+			wantMissing:  true,
+			wantRequeued: true,
+		},
+		{
+			name:        "missing existing bucket is not provisioned again",
+			parameters:  map[string]string{v1alpha1.StorageClassBucket: "existing-bucket"},
+			reprovision: true,
+			wantMissing: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			p := &fakeBucketChecker{fakeProvisioner: fakeProvisioner{bucket: newTestBucket()}, exists: tt.exists}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(tt.parameters)}, []runtime.Object{obc},
+				WithBucketExistenceCheck(time.Minute, 100, tt.reprovision))
+			recorder := record.NewFakeRecorder(10)
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+			c.recorder = recorder
+
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			ob, err := c.objectBucketForClaimKey(testKey(obc))
+			if err != nil {
+				t.Fatalf("error getting OB: %v", err)
+			}
+			if err = c.obcInformer.Informer().GetIndexer().Add(bound); err != nil {
+				t.Fatalf("error adding claim to informer: %v", err)
+			}
+			obIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if err = obIndexer.Add(ob); err != nil {
+				t.Fatalf("error adding OB to informer: %v", err)
+			}
+			c.obLister = listers.NewObjectBucketLister(obIndexer)
+
+			c.checkBoundBuckets()
+
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if missing := meta.IsStatusConditionTrue(got.Status.Conditions, v1alpha1.ObjectBucketClaimConditionBucketMissing); missing != tt.wantMissing {
+				t.Errorf("wanted condition %q %v, got %v", v1alpha1.ObjectBucketClaimConditionBucketMissing, tt.wantMissing, missing)
+			}
+			if got.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseBound {
+				t.Errorf("wanted claim to stay %s, got %s", v1alpha1.ObjectBucketClaimStatusPhaseBound, got.Status.Phase)
+			}
+			select {
+			case event := <-recorder.Events:
+				if !tt.wantMissing {
+					t.Errorf("unexpected event %q", event)
+				} else if !strings.HasPrefix(event, corev1.EventTypeWarning+" "+reasonBucketMissing+" ") {
+					t.Errorf("wanted %s event with reason %q, got %q", corev1.EventTypeWarning, reasonBucketMissing, event)
+				}
+			default:
+				if tt.wantMissing {
+					t.Errorf("wanted event with reason %q, got none", reasonBucketMissing)
+				}
+			}
+			if requeued := c.queue.Len() == 1; requeued != tt.wantRequeued {
+				t.Fatalf("wanted claim requeued %v, got %d keys queued", tt.wantRequeued, c.queue.Len())
+			}
+			if !tt.wantRequeued {
+				return
+			}
+
+			// provisioning the bucket again clears the condition
+			if _, err = c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing requeued claim: %v", err)
+			}
+			got, err = c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if meta.IsStatusConditionTrue(got.Status.Conditions, v1alpha1.ObjectBucketClaimConditionBucketMissing) {
+				t.Errorf("wanted condition %q to be cleared, got %v", v1alpha1.ObjectBucketClaimConditionBucketMissing, got.Status.Conditions)
+			}
+		})
+	}
+}
+
+func TestReconciler(t *testing.T) {
+	obc := newTestClaim(testName)
+	c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+	r := &Reconciler{Name: provisionerName, claimController: c}
+
+	req := Request{NamespacedName: types.NamespacedName{Namespace: obc.Namespace, Name: obc.Name}}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("error reconciling claim: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+	// a claim which no longer exists is not retried
+	req.Name = "missing"
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Errorf("expected missing claim to be skipped, got %v", err)
+	}
+}
+
+func TestReconcilerLiveListers(t *testing.T) {
+	// the claim holding the class's only place is not in the informer, which is not run
+	bound := newTestClaimInPhase("bound", v1alpha1.ObjectBucketClaimStatusPhaseBound, metav1.Now())
+	obc := newTestClaim(testName)
+	provisioned := false
+	p := &fakeProvisioner{bucket: newTestBucket()}
+	p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+		provisioned = true
+		return p.newBucket(options), nil
+	}
+	parameters := map[string]string{v1alpha1.StorageClassMaxClaims: "1"}
+	c := newTestController(p, []runtime.Object{newTestStorageClass(parameters)}, []runtime.Object{bound, obc})
+	withLiveListers(c.libClientset, "")(c)
+	r := &Reconciler{Name: provisionerName, claimController: c}
+
+	req := Request{NamespacedName: types.NamespacedName{Namespace: obc.Namespace, Name: obc.Name}}
+	if _, err := r.Reconcile(context.TODO(), req); err == nil {
+		t.Fatalf("wanted claim to wait for the class quota")
+	}
+	if provisioned {
+		t.Errorf("wanted claim exceeding the class quota not to be provisioned")
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhasePending)
+}
+
+func TestValidateAdditionalConfig(t *testing.T) {
+	schema := api.AdditionalConfigSchema{
+		"tenant": nil,
+		"versioning": func(value string) error {
+			if value != "enabled" && value != "suspended" {
+				return fmt.Errorf("must be enabled or suspended")
+			}
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name      string
+		config    map[string]string
+		options   []Option
+		wantPhase v1alpha1.ObjectBucketClaimStatusPhase
+		wantEvent bool
+	}{
+		{
+			name:      "valid config",
+			config:    map[string]string{"tenant": "a", "versioning": "enabled"},
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:      "unknown key warns",
+			config:    map[string]string{"tenat": "a"},
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantEvent: true,
+		},
+		{
+			name:      "unknown key rejected",
+			config:    map[string]string{"tenat": "a"},
+			options:   []Option{WithStrictAdditionalConfig()},
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantEvent: true,
+		},
+		{
+			name:      "invalid value rejected",
+			config:    map[string]string{"versioning": "on"},
+			options:   []Option{WithStrictAdditionalConfig()},
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantEvent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			obc.Spec.AdditionalConfig = tt.config
+			p := &fakeValidator{fakeProvisioner: fakeProvisioner{bucket: newTestBucket()}, schema: schema}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc}, tt.options...)
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+
+			select {
+			case event := <-recorder.Events:
+				if !tt.wantEvent {
+					t.Errorf("unexpected event %q", event)
+				} else if !strings.HasPrefix(event, corev1.EventTypeWarning+" "+reasonInvalidAdditionalConfig+" ") {
+					t.Errorf("wanted %s event with reason %q, got %q", corev1.EventTypeWarning, reasonInvalidAdditionalConfig, event)
+				}
+			default:
+				if tt.wantEvent {
+					t.Errorf("wanted event with reason %q, got none", reasonInvalidAdditionalConfig)
+				}
+			}
+		})
+	}
+}
+
+func TestSetOBCMetaFieldsPreservesConcurrentChanges(t *testing.T) {
+	obc := newTestClaim(testName)
+	c := newTestController(&fakeProvisioner{}, nil, []runtime.Object{obc}, WithProvisionerVersion("v1.2.3"))
+
+	// the controller read the claim before a user annotated it
+	stale, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	annotated := stale.DeepCopy()
+	annotated.Annotations = map[string]string{"example.com/owner": "jane"}
+	if _, err = updateClaim(c.libClientset, annotated); err != nil {
+		t.Fatalf("error annotating claim: %v", err)
+	}
+
+	got, err := c.setOBCMetaFields(stale, c.labels())
+	if err != nil {
+		t.Fatalf("error setting claim metadata: %v", err)
+	}
+	wantAnnotations := map[string]string{"example.com/owner": "jane", provisionerVersionAnnotation: "v1.2.3"}
+	if !reflect.DeepEqual(got.Annotations, wantAnnotations) {
+		t.Errorf("wanted annotations %v, got %v", wantAnnotations, got.Annotations)
+	}
+	if !reflect.DeepEqual(got.Finalizers, []string{finalizer}) {
+		t.Errorf("wanted finalizers %v, got %v", []string{finalizer}, got.Finalizers)
+	}
+	if got.Labels[provisionerLabelKey] != labelValue(provisionerName) {
+		t.Errorf("wanted label %s=%s, got labels %v", provisionerLabelKey, labelValue(provisionerName), got.Labels)
+	}
+}
+
+func TestMaxQueueDepth(t *testing.T) {
+	const depth = 2
+
+	c := newTestController(&fakeProvisioner{}, nil, nil, WithMaxQueueDepth(depth, time.Hour))
+	deferrals := testutil.ToFloat64(queueDeferralsTotal)
+
+	for i := 0; i < depth; i++ {
+		c.queue.Add(testKey(newTestClaim(fmt.Sprintf("%s-%d", testName, i))))
+	}
+	c.enqueueOBC(newTestClaim(testName))
+	if got := c.queue.Len(); got != depth {
+		t.Errorf("wanted queue depth %d, got %d", depth, got)
+	}
+	if got := testutil.ToFloat64(queueDeferralsTotal) - deferrals; got != 1 {
+		t.Errorf("wanted 1 deferral, got %v", got)
+	}
+}
+
+func TestEventCoalescing(t *testing.T) {
+	const delay = 200 * time.Millisecond
+
+	c := newTestController(&fakeProvisioner{}, nil, nil, WithEventCoalescing(delay))
+	var syncs int32
+	go func() {
+		for {
+			key, shutdown := c.queue.Get()
+			if shutdown {
+				return
+			}
+			atomic.AddInt32(&syncs, 1)
+			c.queue.Done(key)
+		}
+	}()
+
+	// events of several sources arrive in a burst, spread over less than the delay
+	obc := newTestClaim(testName)
+	for i := 0; i < 5; i++ {
+		c.enqueueOBC(obc)
+		time.Sleep(delay / 10)
+	}
+	if got := atomic.LoadInt32(&syncs); got != 0 {
+		t.Errorf("wanted claim not to be synced before the delay, got %d syncs", got)
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return atomic.LoadInt32(&syncs) > 0, nil
+	}); err != nil {
+		t.Fatalf("claim was not synced: %v", err)
+	}
+	time.Sleep(2 * delay)
+	c.queue.ShutDown()
+	if got := atomic.LoadInt32(&syncs); got != 1 {
+		t.Errorf("wanted burst of events to be synced once, got %d syncs", got)
+	}
+}
+
+func TestReclaimPolicySync(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    []Option
+		wantPolicy corev1.PersistentVolumeReclaimPolicy
+		wantEvent  bool
+	}{
+		{
+			name:       "bound OB keeps its policy",
+			wantPolicy: corev1.PersistentVolumeReclaimDelete,
+		},
+		{
+			name:       "bound OB follows class",
+			options:    []Option{WithReclaimPolicySync()},
+			wantPolicy: corev1.PersistentVolumeReclaimRetain,
+			wantEvent:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			class := newTestStorageClass(nil)
+			c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{class}, []runtime.Object{obc}, tt.options...)
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			retain := corev1.PersistentVolumeReclaimRetain
+			class.ReclaimPolicy = &retain
+			if _, err := c.clientset.StorageV1().StorageClasses().Update(context.TODO(), class, metav1.UpdateOptions{}); err != nil {
+				t.Fatalf("error editing StorageClass: %v", err)
+			}
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+
+			obName, err := objectBucketNameFromClaimKey(testKey(obc))
+			if err != nil {
+				t.Fatalf("error getting OB name: %v", err)
+			}
+			ob, err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), obName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting OB: %v", err)
+			}
+			if *ob.Spec.ReclaimPolicy != tt.wantPolicy {
+				t.Errorf("wanted OB reclaim policy %q, got %q", tt.wantPolicy, *ob.Spec.ReclaimPolicy)
+			}
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if got.Status.ReclaimPolicy != tt.wantPolicy {
+				t.Errorf("wanted claim reclaim policy %q, got %q", tt.wantPolicy, got.Status.ReclaimPolicy)
+			}
+
+			gotEvent := false
+			for len(recorder.Events) > 0 {
+				if strings.HasPrefix(<-recorder.Events, corev1.EventTypeNormal+" "+reasonReclaimPolicyChanged+" ") {
+					gotEvent = true
+				}
+			}
+			if gotEvent != tt.wantEvent {
+				t.Errorf("wanted %s event %v, got %v", reasonReclaimPolicyChanged, tt.wantEvent, gotEvent)
+			}
+		})
+	}
+}
+
+func TestStorageClassWatch(t *testing.T) {
+	newClaim := func(name, class string, phase v1alpha1.ObjectBucketClaimStatusPhase) *v1alpha1.ObjectBucketClaim {
+		obc := newTestClaim(name)
+		obc.Spec.StorageClassName = class
+		obc.Status.Phase = phase
+		return obc
+	}
+	bound := newClaim("bound", className, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+	claims := []*v1alpha1.ObjectBucketClaim{
+		bound,
+		newClaim("pending", className, v1alpha1.ObjectBucketClaimStatusPhasePending),
+		newClaim("other-class", "other", v1alpha1.ObjectBucketClaimStatusPhaseBound),
+	}
+
+	c := newTestController(&fakeProvisioner{}, nil, nil, WithStorageClassWatch())
+	if c.classInformerFactory == nil {
+		t.Fatalf("wanted storage classes to be watched")
+	}
+	// the rate limiter would delay the keys
+	c.queue = workqueue.NewRateLimitingQueue(workqueue.NewItemFastSlowRateLimiter(0, 0, 0))
+	for _, obc := range claims {
+		if err := c.obcInformer.Informer().GetIndexer().Add(obc); err != nil {
+			t.Fatalf("error adding claim to informer: %v", err)
+		}
+	}
+
+	unsupported := newTestStorageClass(nil)
+	unsupported.Provisioner = "other-provisioner"
+	c.enqueueClaimsOfClass(unsupported)
+	if got := c.queue.Len(); got != 0 {
+		t.Fatalf("wanted no claims of an unsupported class to be requeued, got %d", got)
+	}
+
+	c.enqueueClaimsOfClass(newTestStorageClass(nil))
+	if got := c.queue.Len(); got != 1 {
+		t.Fatalf("wanted 1 claim to be requeued, got %d", got)
+	}
+	if key, _ := c.queue.Get(); key != testKey(bound) {
+		t.Errorf("wanted claim %q to be requeued, got %v", testKey(bound), key)
+	}
+}
+
+func TestStorageClassChange(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldParams   map[string]string
+		newParams   map[string]string
+		wantOutcome reconcileOutcome
+		wantParams  map[string]string
+		wantEvent   bool
+	}{
+		{
+			name:        "new parameters are applied",
+			oldParams:   map[string]string{"region": "us"},
+			newParams:   map[string]string{"region": "eu"},
+			wantOutcome: outcomeProvisioned,
+			wantParams:  map[string]string{"region": "eu"},
+		},
+		{
+			name:        "new bucket is rejected",
+			oldParams:   map[string]string{v1alpha1.StorageClassBucket: "old-bucket"},
+			newParams:   map[string]string{v1alpha1.StorageClassBucket: "new-bucket"},
+			wantOutcome: outcomeFailedTerminal,
+			wantEvent:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *api.BucketOptions
+			p := &fakeProvisioner{bucket: newTestBucket()}
+			p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+				got = options
+				return p.newBucket(options), nil
+			}
+			obc := newTestClaim(testName)
+			c := newTestController(p, []runtime.Object{newTestStorageClass(tt.oldParams)}, []runtime.Object{obc})
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			// the class is recreated, as its parameters and reclaim policy are immutable
+			classes := c.clientset.StorageV1().StorageClasses()
+			if err := classes.Delete(context.TODO(), className, metav1.DeleteOptions{}); err != nil {
+				t.Fatalf("error deleting storage class: %v", err)
+			}
+			class := newTestStorageClass(tt.newParams)
+			retain := corev1.PersistentVolumeReclaimRetain
+			class.ReclaimPolicy = &retain
+			if _, err := classes.Create(context.TODO(), class, metav1.CreateOptions{}); err != nil {
+				t.Fatalf("error creating storage class: %v", err)
+			}
+
+			got = nil
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing bound claim: %v", err)
+			}
+			if outcome != tt.wantOutcome {
+				t.Errorf("wanted outcome %q, got %q", tt.wantOutcome, outcome)
+			}
+			if tt.wantParams != nil {
+				if got == nil {
+					t.Fatalf("wanted bucket to be provisioned again")
+				}
+				if !reflect.DeepEqual(got.Parameters, tt.wantParams) {
+					t.Errorf("wanted parameters %v, got %v", tt.wantParams, got.Parameters)
+				}
+				if *got.ReclaimPolicy != corev1.PersistentVolumeReclaimDelete {
+					t.Errorf("wanted reclaim policy of the OB %q, got %q", corev1.PersistentVolumeReclaimDelete, *got.ReclaimPolicy)
+				}
+			} else if got != nil {
+				t.Errorf("wanted bucket not to be provisioned again")
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			var rejected bool
+			for len(recorder.Events) > 0 {
+				if strings.HasPrefix(<-recorder.Events, corev1.EventTypeWarning+" "+reasonStorageClassChangeRejected+" ") {
+					rejected = true
+				}
+			}
+			if rejected != tt.wantEvent {
+				t.Errorf("wanted %s event %v, got %v", reasonStorageClassChangeRejected, tt.wantEvent, rejected)
+			}
+		})
+	}
+}
+
+func TestBoundClaimInvalidParameter(t *testing.T) {
+	tests := []struct {
+		name  string
+		param string
+		value string
+	}{
+		{name: "tags", param: v1alpha1.StorageClassTags, value: "novalue"},
+		{name: "detect region", param: v1alpha1.StorageClassDetectRegion, value: "maybe"},
+		{name: "provision timeout", param: v1alpha1.StorageClassProvisionTimeout, value: "bad"},
+		{name: "require TLS endpoint", param: v1alpha1.StorageClassRequireTLSEndpoint, value: "maybe"},
+		{name: "max concurrent provisions", param: v1alpha1.StorageClassMaxConcurrentProvisions, value: "x"},
+		{name: "bucket name collision", param: v1alpha1.StorageClassBucketNameCollision, value: "bogus"},
+		{name: "max claims", param: v1alpha1.StorageClassMaxClaims, value: "x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var provisioned bool
+			p := &fakeProvisioner{bucket: newTestBucket()}
+			p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+				provisioned = true
+				return p.newBucket(options), nil
+			}
+			obc := newTestClaim(testName)
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			// the class is recreated with an invalid parameter
+			classes := c.clientset.StorageV1().StorageClasses()
+			if err := classes.Delete(context.TODO(), className, metav1.DeleteOptions{}); err != nil {
+				t.Fatalf("error deleting storage class: %v", err)
+			}
+			if _, err := classes.Create(context.TODO(), newTestStorageClass(map[string]string{tt.param: tt.value}), metav1.CreateOptions{}); err != nil {
+				t.Fatalf("error creating storage class: %v", err)
+			}
+			for len(recorder.Events) > 0 {
+				<-recorder.Events
+			}
+
+			provisioned = false
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("wanted bound claim not to be failed, got %v", err)
+			}
+			if outcome != outcomeFailedTerminal {
+				t.Errorf("wanted outcome %q, got %q", outcomeFailedTerminal, outcome)
+			}
+			if provisioned {
+				t.Errorf("wanted bucket not to be provisioned again")
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			var warned bool
+			for len(recorder.Events) > 0 {
+				if strings.HasPrefix(<-recorder.Events, corev1.EventTypeWarning+" "+reasonInvalidParameter+" ") {
+					warned = true
+				}
+			}
+			if !warned {
+				t.Errorf("wanted %s event", reasonInvalidParameter)
+			}
+		})
+	}
+}
+
+func TestEffectiveReclaimPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		parameters    map[string]string
+		reclaimPolicy corev1.PersistentVolumeReclaimPolicy
+		want          corev1.PersistentVolumeReclaimPolicy
+	}{
+		{
+			name:          "new bucket is deleted",
+			reclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+			want:          corev1.PersistentVolumeReclaimDelete,
+		},
+		{
+			name:          "new bucket is retained",
+			reclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			want:          corev1.PersistentVolumeReclaimRetain,
+		},
+		{
+			name:          "existing bucket is retained regardless of policy",
+			parameters:    map[string]string{v1alpha1.StorageClassBucket: "existing-bucket"},
+			reclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+			want:          corev1.PersistentVolumeReclaimRetain,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class := newTestStorageClass(tt.parameters)
+			class.ReclaimPolicy = &tt.reclaimPolicy
+			obc := newTestClaim(testName)
+			c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{class}, []runtime.Object{obc})
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if bound.Status.ReclaimPolicy != tt.want {
+				t.Errorf("wanted reclaim policy %q, got %q", tt.want, bound.Status.ReclaimPolicy)
+			}
+
+			// the status matches what is done when the claim is deleted
+			now := metav1.Now()
+			bound.DeletionTimestamp = &now
+			if _, err = updateClaim(c.libClientset, bound); err != nil {
+				t.Fatalf("error deleting claim: %v", err)
+			}
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing deleted claim: %v", err)
+			}
+			wantOutcome := outcomeRevoked
+			if tt.want == corev1.PersistentVolumeReclaimDelete {
+				wantOutcome = outcomeDeleted
+			}
+			if outcome != wantOutcome {
+				t.Errorf("wanted outcome %q, got %q", wantOutcome, outcome)
+			}
+		})
+	}
+}
+
+func TestProvisionProgress(t *testing.T) {
+	tests := []struct {
+		name        string
+		interval    time.Duration
+		wantMessage string
+	}{
+		{
+			name:        "every step is recorded",
+			wantMessage: "applying lifecycle policy",
+		},
+		{
+			name:        "steps are rate limited",
+			interval:    time.Hour,
+			wantMessage: "creating bucket",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			var (
+				progress func(string)
+				message  string
+			)
+			p := &fakeProvisioner{bucket: newTestBucket()}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+			c.progressInterval = tt.interval
+			getMessage := func() string {
+				got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("error getting claim: %v", err)
+				}
+				return got.Status.Message
+			}
+			p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+				progress = options.Progress
+				progress("creating bucket")
+				progress("applying lifecycle policy")
+				message = getMessage()
+				return p.newBucket(options), nil
+			}
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+			if message != tt.wantMessage {
+				t.Errorf("wanted message %q while provisioning, got %q", tt.wantMessage, message)
+			}
+
+			// steps reported after provisioning has returned are ignored
+			progress("done")
+			if got := getMessage(); got != "" {
+				t.Errorf("wanted message of bound claim to be cleared, got %q", got)
+			}
+		})
+	}
+}
+
+func TestRequireTLSEndpoint(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     []Option
+		parameters  map[string]string
+		port        int
+		wantPhase   v1alpha1.ObjectBucketClaimStatusPhase
+		wantDeleted bool
+	}{
+		{
+			name:      "TLS endpoint is not checked by default",
+			port:      80,
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:      "TLS endpoint is accepted",
+			options:   []Option{WithRequireTLSEndpoint()},
+			port:      443,
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:        "insecure endpoint is rejected and cleaned up",
+			options:     []Option{WithRequireTLSEndpoint()},
+			port:        80,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantDeleted: true,
+		},
+		{
+			name:        "storage class requires TLS",
+			parameters:  map[string]string{v1alpha1.StorageClassRequireTLSEndpoint: "true"},
+			port:        80,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantDeleted: true,
+		},
+		{
+			name:       "storage class allows insecure endpoint",
+			options:    []Option{WithRequireTLSEndpoint()},
+			parameters: map[string]string{v1alpha1.StorageClassRequireTLSEndpoint: "false"},
+			port:       80,
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:       "invalid storage class parameter",
+			parameters: map[string]string{v1alpha1.StorageClassRequireTLSEndpoint: "sometimes"},
+			port:       443,
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := newTestBucket()
+			bucket.Spec.Endpoint.BucketPort = tt.port
+			var deleted bool
+			p := &fakeProvisioner{
+				bucket: bucket,
+				deleteFunc: func(ob *v1alpha1.ObjectBucket) error {
+					deleted = true
+					return nil
+				},
+			}
+			obc := newTestClaim(testName)
+			c := newTestController(p, []runtime.Object{newTestStorageClass(tt.parameters)}, []runtime.Object{obc}, tt.options...)
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+			if deleted != tt.wantDeleted {
+				t.Errorf("wanted bucket deleted %v, got %v", tt.wantDeleted, deleted)
+			}
+		})
+	}
+}
+
+func TestBucketCleanupTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		// deleteClaim deletes the claim while the provisioner is called, rather than rejecting the
+		// bucket for its insecure endpoint
+		deleteClaim bool
+		wantErr     bool
+		wantEvent   string
+	}{
+		{
+			name:    "rejected bucket",
+			wantErr: true,
+		},
+		{
+			name:        "claim deleted while provisioning",
+			deleteClaim: true,
+			wantEvent:   reasonBucketLeaked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := newTestBucket()
+			bucket.Spec.Endpoint.BucketPort = 80
+			unblock := make(chan struct{})
+			defer close(unblock)
+			p := &fakeProvisioner{
+				bucket: bucket,
+				deleteFunc: func(ob *v1alpha1.ObjectBucket) error {
+					<-unblock
+					return nil
+				},
+			}
+			obc := newTestClaim(testName)
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc},
+				WithRequireTLSEndpoint(), WithDeleteTimeout(10*time.Millisecond))
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+			if tt.deleteClaim {
+				p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+					got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+					if err != nil {
+						return nil, err
+					}
+					now := metav1.Now()
+					got.DeletionTimestamp = &now
+					if _, err = updateClaim(c.libClientset, got); err != nil {
+						return nil, err
+					}
+					return p.newBucket(options), nil
+				}
+			}
+
+			synced := make(chan error, 1)
+			go func() {
+				_, err := c.syncHandler(testKey(obc))
+				synced <- err
+			}()
+			select {
+			case err := <-synced:
+				if (err != nil) != tt.wantErr {
+					t.Errorf("wanted error %v, got %v", tt.wantErr, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("sync waited for the hung cleanup")
+			}
+
+			var gotEvent bool
+			for len(recorder.Events) > 0 {
+				if strings.HasPrefix(<-recorder.Events, corev1.EventTypeWarning+" "+tt.wantEvent+" ") {
+					gotEvent = true
+				}
+			}
+			if tt.wantEvent != "" && !gotEvent {
+				t.Errorf("wanted %s event", tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestPreserveBucket(t *testing.T) {
+	preserve := bucketerrors.NewPreserveBucket("bucket was adopted")
+	tests := []struct {
+		name string
+		// provisionErr is returned by Provision along with the bucket
+		provisionErr error
+		// validationErr is returned by the child validator
+		validationErr error
+		// deleteClaim deletes the claim while the provisioner is called
+		deleteClaim bool
+		wantDeleted bool
+		wantRevoked bool
+	}{
+		{
+			name:          "rejected bucket is deleted",
+			validationErr: fmt.Errorf("invalid"),
+			wantDeleted:   true,
+		},
+		{
+			name:          "rejected bucket is preserved",
+			validationErr: preserve,
+			wantRevoked:   true,
+		},
+		{
+			name:         "bucket the provisioner failed on is preserved",
+			provisionErr: preserve,
+			wantRevoked:  true,
+		},
+		{
+			name:         "bucket of claim deleted while provisioning is preserved",
+			provisionErr: preserve,
+			deleteClaim:  true,
+			wantRevoked:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted, revoked bool
+			p := &fakeProvisioner{
+				deleteFunc: func(ob *v1alpha1.ObjectBucket) error {
+					deleted = true
+					return nil
+				},
+				revokeFunc: func(ob *v1alpha1.ObjectBucket) error {
+					revoked = true
+					return nil
+				},
+			}
+			obc := newTestClaim(testName)
+			validator := func(kind string, data map[string]string) error {
+				return tt.validationErr
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc}, WithChildValidators(validator))
+			p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+				if tt.deleteClaim {
+					got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+					if err != nil {
+						return nil, err
+					}
+					now := metav1.Now()
+					got.DeletionTimestamp = &now
+					if _, err = updateClaim(c.libClientset, got); err != nil {
+						return nil, err
+					}
+				}
+				ob := newTestBucket()
+				ob.Spec.Endpoint.BucketName = options.BucketName
+				return ob, tt.provisionErr
+			}
+
+			_, _ = c.syncHandler(testKey(obc))
+			if deleted != tt.wantDeleted {
+				t.Errorf("wanted bucket deleted %v, got %v", tt.wantDeleted, deleted)
+			}
+			if revoked != tt.wantRevoked {
+				t.Errorf("wanted access revoked %v, got %v", tt.wantRevoked, revoked)
+			}
+		})
+	}
+}
+
+func TestUnownedChildren(t *testing.T) {
+	tests := []struct {
+		name      string
+		owners    []metav1.OwnerReference
+		wantPhase v1alpha1.ObjectBucketClaimStatusPhase
+	}{
+		{
+			name:      "unrelated secret blocks provisioning",
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+		},
+		{
+			name:      "secret of the claim is updated",
+			owners:    []metav1.OwnerReference{makeOwnerReference(newTestClaim(testName))},
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:       testNamespace,
+					Name:            testName,
+					OwnerReferences: tt.owners,
+				},
+				StringData: map[string]string{"password": "user-data"},
+			}
+			var provisioned bool
+			p := &fakeProvisioner{bucket: newTestBucket()}
+			p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+				provisioned = true
+				return p.newBucket(options), nil
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil), secret}, []runtime.Object{obc})
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+			if tt.wantPhase != v1alpha1.ObjectBucketClaimStatusPhaseFailed {
+				return
+			}
+
+			if provisioned {
+				t.Errorf("wanted bucket not to be provisioned")
+			}
+			got, err := c.clientset.CoreV1().Secrets(testNamespace).Get(context.TODO(), testName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting secret: %v", err)
+			}
+			if !reflect.DeepEqual(got.StringData, secret.StringData) {
+				t.Errorf("wanted secret data %v to be kept, got %v", secret.StringData, got.StringData)
+			}
+			select {
+			case event := <-recorder.Events:
+				if !strings.HasPrefix(event, corev1.EventTypeWarning+" "+reasonResourceConflict+" ") {
+					t.Errorf("wanted %s event with reason %q, got %q", corev1.EventTypeWarning, reasonResourceConflict, event)
+				}
+			default:
+				t.Errorf("wanted event with reason %q, got none", reasonResourceConflict)
+			}
+		})
+	}
+}
+
+func TestSetLabelsValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		wantErr bool
+	}{
+		{
+			name:   "valid labels",
+			labels: map[string]string{"example.com/tier": "gold"},
+		},
+		{
+			name:    "invalid value",
+			labels:  map[string]string{"tier": "gold plated"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid key",
+			labels:  map[string]string{"tier!": "gold"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestController(&fakeProvisioner{}, nil, nil)
+			errs := setLabels(c, tt.labels)
+			if (len(errs) > 0) != tt.wantErr {
+				t.Errorf("setLabels() errors = %v, wantErr %v", errs, tt.wantErr)
+			}
+			_, set := c.labels()["tier"]
+			_, setQualified := c.labels()["example.com/tier"]
+			if (set || setQualified) == tt.wantErr {
+				t.Errorf("wanted labels set %v, got %v", !tt.wantErr, c.labels())
+			}
+		})
+	}
+}
+
+func TestObjectBucketLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		obLabels map[string]string
+		want     string
+	}{
+		{
+			name:     "labels are added to the OB",
+			obLabels: map[string]string{"example.com/inventory": "buckets"},
+			want:     "buckets",
+		},
+		{
+			name:     "invalid labels are ignored",
+			obLabels: map[string]string{"example.com/inventory": "all buckets"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			c := newTestController(
+				&fakeProvisioner{bucket: newTestBucket()},
+				[]runtime.Object{newTestStorageClass(nil)},
+				[]runtime.Object{obc},
+				WithObjectBucketLabels(tt.obLabels))
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			ob, err := c.objectBucketForClaimKey(testKey(obc))
+			if err != nil {
+				t.Fatalf("error getting OB: %v", err)
+			}
+			if got := ob.Labels["example.com/inventory"]; got != tt.want {
+				t.Errorf("wanted OB label %q, got %q", tt.want, got)
+			}
+			if got := ob.Labels[provisionerLabelKey]; got != labelValue(provisionerName) {
+				t.Errorf("wanted OB provisioner label %q, got %q", labelValue(provisionerName), got)
+			}
+
+			// the claim and the resources generated in its namespace are not labeled
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			secret, err := c.clientset.CoreV1().Secrets(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting secret: %v", err)
+			}
+			configMap, err := c.clientset.CoreV1().ConfigMaps(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting configmap: %v", err)
+			}
+			for _, obj := range []metav1.Object{bound, secret, configMap} {
+				if _, ok := obj.GetLabels()["example.com/inventory"]; ok {
+					t.Errorf("wanted %T not to have OB label, got %v", obj, obj.GetLabels())
+				}
+			}
+		})
+	}
+}
+
+func TestEnqueueOBCNow(t *testing.T) {
+	obc := newTestClaim(testName)
+	c := newTestController(&fakeProvisioner{}, nil, nil)
+	key := testKey(obc)
+	// the claim failed a few times and is waiting for its backoff
+	for i := 0; i < 3; i++ {
+		c.queue.AddRateLimited(key)
+	}
+
+	c.enqueueOBCNow(obc)
+
+	if n := c.queue.NumRequeues(key); n != 0 {
+		t.Errorf("wanted backoff reset, got %d requeues", n)
+	}
+	if c.queue.Len() != 1 {
+		t.Fatalf("wanted claim queued immediately, got queue length %d", c.queue.Len())
+	}
+	if got, _ := c.queue.Get(); got != key {
+		t.Errorf("wanted claim %q queued, got %v", key, got)
+	}
+}
+
+func TestValidateBucketName(t *testing.T) {
+	tests := []struct {
+		name         string
+		generateName string
+		wantOutcome  reconcileOutcome
+		wantPhase    v1alpha1.ObjectBucketClaimStatusPhase
+		wantReason   string
+	}{
+		{
+			name:         "accepted",
+			generateName: testNamespace + "-",
+			wantOutcome:  outcomeProvisioned,
+			wantPhase:    v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:         "rejected",
+			generateName: "other-",
+			wantOutcome:  outcomeFailedTerminal,
+			wantPhase:    v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason:   reasonBucketNameRejected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			obc.Spec.GenerateBucketName = tt.generateName
+			provisioned := false
+			p := &fakeBucketNameValidator{
+				fakeProvisioner: fakeProvisioner{
+					provisionFunc: func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+						provisioned = true
+						return newTestBucket(), nil
+					},
+				},
+				validateFunc: func(obc *v1alpha1.ObjectBucketClaim, name string) error {
+					if !strings.HasPrefix(name, obc.Namespace+"-") {
+						return fmt.Errorf("bucket names must start with the namespace %q", obc.Namespace)
+					}
+					return nil
+				},
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			if outcome != tt.wantOutcome {
+				t.Errorf("wanted outcome %q, got %q", tt.wantOutcome, outcome)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if got.Status.Reason != tt.wantReason {
+				t.Errorf("wanted status reason %q, got %q", tt.wantReason, got.Status.Reason)
+			}
+			if wantProvisioned := tt.wantPhase == v1alpha1.ObjectBucketClaimStatusPhaseBound; provisioned != wantProvisioned {
+				t.Errorf("wanted bucket provisioned %t, got %t", wantProvisioned, provisioned)
+			}
+		})
+	}
+}
+
+func TestAdoptBucket(t *testing.T) {
+	const adopted = "legacy-bucket"
+
+	tests := []struct {
+		name        string
+		parameters  map[string]string
+		wantOutcome reconcileOutcome
+		wantPhase   v1alpha1.ObjectBucketClaimStatusPhase
+		wantReason  string
+	}{
+		{
+			name:        "adoption allowed",
+			parameters:  map[string]string{v1alpha1.StorageClassAllowBucketAdoption: "true"},
+			wantOutcome: outcomeGranted,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:        "adoption not allowed",
+			wantOutcome: outcomeFailedTerminal,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason:  reasonAdoptionRejected,
+		},
+		{
+			name:        "invalid parameter",
+			parameters:  map[string]string{v1alpha1.StorageClassAllowBucketAdoption: "maybe"},
+			wantOutcome: outcomeFailedTerminal,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason:  reasonInvalidParameter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			obc.Annotations = map[string]string{v1alpha1.AdoptBucketAnnotation: adopted}
+			var granted string
+			p := &fakeProvisioner{
+				provisionFunc: func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+					granted = options.BucketName
+					ob := newTestBucket()
+					ob.Spec.Endpoint.BucketName = options.BucketName
+					return ob, nil
+				},
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(tt.parameters)}, []runtime.Object{obc})
+
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			if outcome != tt.wantOutcome {
+				t.Errorf("wanted outcome %q, got %q", tt.wantOutcome, outcome)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if got.Status.Reason != tt.wantReason {
+				t.Errorf("wanted status reason %q, got %q", tt.wantReason, got.Status.Reason)
+			}
+			if tt.wantPhase != v1alpha1.ObjectBucketClaimStatusPhaseBound {
+				if granted != "" {
+					t.Errorf("wanted no bucket granted, got %q", granted)
+				}
+				return
+			}
+			if granted != adopted || got.Spec.BucketName != adopted {
+				t.Errorf("wanted bucket %q granted and named by the claim, got %q and %q", adopted, granted, got.Spec.BucketName)
+			}
+
+			// the adopted bucket is not deleted with the claim
+			now := metav1.Now()
+			got.DeletionTimestamp = &now
+			if _, err = updateClaim(c.libClientset, got); err != nil {
+				t.Fatalf("error deleting claim: %v", err)
+			}
+			if outcome, err = c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing deleted claim: %v", err)
+			}
+			if outcome != outcomeRevoked {
+				t.Errorf("wanted outcome %q, got %q", outcomeRevoked, outcome)
+			}
+		})
+	}
+}
+
+func TestSkipLabelPropagation(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		wantLabels bool
+	}{
+		{
+			name:       "labels are propagated by default",
+			wantLabels: true,
+		},
+		{
+			name:       "labels are not propagated",
+			annotation: "true",
+		},
+		{
+			name:       "invalid annotation is ignored",
+			annotation: "maybe",
+			wantLabels: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			if tt.annotation != "" {
+				obc.Annotations = map[string]string{v1alpha1.SkipLabelPropagationAnnotation: tt.annotation}
+			}
+			c := newTestController(
+				&fakeProvisioner{bucket: newTestBucket()},
+				[]runtime.Object{newTestStorageClass(nil)},
+				[]runtime.Object{obc},
+				WithObjectBucketLabels(map[string]string{"example.com/inventory": "buckets"}))
+			c.SetLabels(map[string]string{"example.com/tier": "gold"})
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			ob, err := c.objectBucketForClaimKey(testKey(obc))
+			if err != nil {
+				t.Fatalf("error getting OB: %v", err)
+			}
+			secret, err := c.clientset.CoreV1().Secrets(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting secret: %v", err)
+			}
+			configMap, err := c.clientset.CoreV1().ConfigMaps(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting configmap: %v", err)
+			}
+			if _, ok := ob.Labels["example.com/inventory"]; ok != tt.wantLabels {
+				t.Errorf("wanted OB label %v, got %v", tt.wantLabels, ob.Labels)
+			}
+			for _, obj := range []metav1.Object{bound, ob, secret, configMap} {
+				if got := obj.GetLabels()[provisionerLabelKey]; got != labelValue(provisionerName) {
+					t.Errorf("wanted %T provisioner label %q, got %q", obj, labelValue(provisionerName), got)
+				}
+				if _, ok := obj.GetLabels()["example.com/tier"]; ok != tt.wantLabels {
+					t.Errorf("wanted %T label %v, got %v", obj, tt.wantLabels, obj.GetLabels())
+				}
+			}
+
+			// labels are not backfilled either when the claim is synced again
+			if _, err = c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing bound claim: %v", err)
+			}
+			bound, err = c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if _, ok := bound.Labels["example.com/tier"]; ok != tt.wantLabels {
+				t.Errorf("wanted claim label %v after resync, got %v", tt.wantLabels, bound.Labels)
+			}
+		})
+	}
+}
+
+func TestDeleteAllManaged(t *testing.T) {
+	newClaim := func(name, provisioner string) *v1alpha1.ObjectBucketClaim {
+		obc := newTestClaim(name)
+		if provisioner != "" {
+			obc.Labels = map[string]string{provisionerLabelKey: labelValue(provisioner)}
+		}
+		return obc
+	}
+	claims := []runtime.Object{
+		newClaim("managed-1", provisionerName),
+		newClaim("managed-2", provisionerName),
+		newClaim("other", "other.io/provisioner"),
+		newClaim("unlabeled", ""),
+	}
+
+	tests := []struct {
+		name      string
+		options   []Option
+		wantErr   bool
+		wantNames []string
+	}{
+		{
+			name:      "disabled by default",
+			wantErr:   true,
+			wantNames: []string{"managed-1", "managed-2", "other", "unlabeled"},
+		},
+		{
+			name:      "managed claims are deleted",
+			options:   []Option{WithBulkDeletion()},
+			wantNames: []string{"other", "unlabeled"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestController(&fakeProvisioner{}, nil, claims, tt.options...)
+
+			err := c.DeleteAllManaged(context.TODO())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DeleteAllManaged() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			remaining, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(testNamespace).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("error listing claims: %v", err)
+			}
+			var names []string
+			for _, obc := range remaining.Items {
+				names = append(names, obc.Name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("wanted remaining claims %v, got %v", tt.wantNames, names)
+			}
+		})
+	}
+}
+
+func TestMissingObjectBucket(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		wantPhase  v1alpha1.ObjectBucketClaimStatusPhase
+		wantOB     bool
+	}{
+		{
+			name:      "new bucket is provisioned again",
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantOB:    true,
+		},
+		{
+			name:       "claim of existing bucket is failed",
+			parameters: map[string]string{v1alpha1.StorageClassBucket: "existing-bucket"},
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{newTestStorageClass(tt.parameters)}, []runtime.Object{obc})
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			// the OB is deleted out from under the bound claim
+			ob, err := c.objectBucketForClaimKey(testKey(obc))
+			if err != nil {
+				t.Fatalf("error getting OB: %v", err)
+			}
+			if err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Delete(context.TODO(), ob.Name, metav1.DeleteOptions{}); err != nil {
+				t.Fatalf("error deleting OB: %v", err)
+			}
+
+			if _, err = c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing bound claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+			ob, err = getObFromKey(testKey(obc), c.libClientset)
+			if err != nil {
+				t.Fatalf("error getting OB: %v", err)
+			}
+			if (ob != nil) != tt.wantOB {
+				t.Errorf("wanted OB %v, got %v", tt.wantOB, ob != nil)
+			}
+
+			var missing bool
+			for len(recorder.Events) > 0 {
+				if strings.HasPrefix(<-recorder.Events, corev1.EventTypeWarning+" "+reasonObjectBucketMissing+" ") {
+					missing = true
+				}
+			}
+			if !missing {
+				t.Errorf("wanted event with reason %q, got none", reasonObjectBucketMissing)
+			}
+		})
+	}
+}
+
+func TestActiveWorkers(t *testing.T) {
+	const worker = 3
+	obc := newTestClaim(testName)
+	// the provisioner blocks until the worker's key is checked
+	provisioning, release := make(chan struct{}), make(chan struct{})
+	p := &fakeProvisioner{
+		provisionFunc: func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+			close(provisioning)
+			<-release
+			return newTestBucket(), nil
+		},
+	}
+	c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+	c.queue.Add(testKey(obc))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.processNextItemInQueue(worker)
+	}()
+	<-provisioning
+	if got, want := c.ActiveWorkers(), map[int]string{worker: testKey(obc)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted active workers %v, got %v", want, got)
+	}
+	close(release)
+	<-done
+	if got := c.ActiveWorkers(); len(got) != 0 {
+		t.Errorf("wanted no active workers once the item is finished, got %v", got)
+	}
+
+	// workers update their keys while they are read, for the race detector
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.setActiveKey(worker, fmt.Sprintf("%s/%s-%d", testNamespace, testName, j))
+				c.ActiveWorkers()
+				c.setActiveKey(worker, "")
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := c.ActiveWorkers(); len(got) != 0 {
+		t.Errorf("wanted no active workers, got %v", got)
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	tests := []struct {
+		name  string
+		debug bool
+		paths map[string]string
+	}{
+		{
+			name: "metrics only by default",
+			paths: map[string]string{
+				"/metrics":       "obc_queue_deferrals_total",
+				"/debug/pprof/":  "",
+				"/debug/workers": "",
+			},
+		},
+		{
+			name:  "debug handlers",
+			debug: true,
+			paths: map[string]string{
+				"/metrics":       "obc_queue_deferrals_total",
+				"/debug/pprof/":  "goroutine",
+				"/debug/workers": `{"0":"ns/obc"}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stopCh := make(chan struct{})
+			activeWorkers := func() map[int]string { return map[int]string{0: "ns/obc"} }
+			addr, _, err := serveMetrics("127.0.0.1:0", tt.debug, activeWorkers, stopCh)
+			if err != nil {
+				t.Fatalf("error serving metrics: %v", err)
+			}
+			url := "http://" + addr.String()
+
+			// an empty want is a path which is not served
+			for path, want := range tt.paths {
+				resp, err := http.Get(url + path)
+				if err != nil {
+					t.Fatalf("error getting %s: %v", path, err)
+				}
+				body, err := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					t.Fatalf("error reading %s: %v", path, err)
+				}
+				if want == "" {
+					if resp.StatusCode != http.StatusNotFound {
+						t.Errorf("wanted %s not to be served, got status %d", path, resp.StatusCode)
+					}
+				} else if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), want) {
+					t.Errorf("wanted %s to return %q, got status %d", path, want, resp.StatusCode)
+				}
+			}
+
+			// the server shuts down with the controller
+			close(stopCh)
+			waitForMetricsShutdown(t, url)
+		})
+	}
+}
+
+func TestMetricsShutdownOnStartError(t *testing.T) {
+	// the port of a closed listener is very likely to be free
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error finding a free port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	p := &fakePreflighter{
+		preflightFunc: func(ctx context.Context) error {
+			return fmt.Errorf("backend unreachable")
+		},
+	}
+	c := newTestController(p, nil, nil, WithMetricsBindAddress(address), WithPreflight(0))
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if err = c.Start(stopCh); err == nil {
+		t.Fatal("wanted Start to fail")
+	}
+	waitForMetricsShutdown(t, "http://"+address)
+}
+
+// waitForMetricsShutdown polls the metrics server at the given URL until it no longer answers.
+func waitForMetricsShutdown(t *testing.T, url string) {
+	t.Helper()
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		resp, err := http.Get(url + "/metrics")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err != nil, nil
+	})
+	if err != nil {
+		t.Errorf("wanted metrics server to shut down: %v", err)
+	}
+}
+
+func TestProvisionerRequeueAfter(t *testing.T) {
+	const after = 200 * time.Millisecond
+
+	obc := newTestClaim(testName)
+	p := &fakeProvisioner{
+		provisionFunc: func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+			return nil, bucketerrors.NewRequeueAfterError("bucket is being created", after)
+		},
+	}
+	c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+
+	start := time.Now()
+	c.queue.Add(testKey(obc))
+	if !c.processNextItemInQueue(0) {
+		t.Fatalf("queue shut down unexpectedly")
+	}
+	if got := c.queue.Len(); got != 0 {
+		t.Errorf("wanted claim not to be requeued before %v, got %d keys", after, got)
+	}
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return c.queue.Len() == 1, nil
+	})
+	if err != nil {
+		t.Fatalf("claim was not requeued: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < after {
+		t.Errorf("wanted claim to be requeued after %v, got %v", after, elapsed)
+	}
+	if got := c.queue.NumRequeues(testKey(obc)); got != 0 {
+		t.Errorf("wanted rate limiter backoff to be reset, got %d requeues", got)
+	}
+
+	// the reconciler returns the delay to the caller's control loop
+	r := &Reconciler{Name: provisionerName, claimController: c}
+	result, err := r.Reconcile(context.TODO(), Request{NamespacedName: types.NamespacedName{Namespace: obc.Namespace, Name: obc.Name}})
+	if err != nil {
+		t.Fatalf("error reconciling claim: %v", err)
+	}
+	if result.RequeueAfter != after {
+		t.Errorf("wanted result to requeue after %v, got %v", after, result.RequeueAfter)
+	}
+}
+
+func TestProvisionerErrorCode(t *testing.T) {
+	obc := newTestClaim(testName)
+	fail := true
+	p := &fakeProvisioner{bucket: newTestBucket()}
+	p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+		if fail {
+			return nil, bucketerrors.NewProvisionerError("QuotaExceeded", "account has 100 buckets")
+		}
+		return p.newBucket(options), nil
+	}
+	c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+	recorder := record.NewFakeRecorder(10)
+	c.recorder = recorder
+
+	if _, err := c.syncHandler(testKey(obc)); err == nil {
+		t.Fatalf("wanted claim to be requeued")
+	}
+	pending, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if pending.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhasePending || pending.Status.Reason != reasonProvisionerError ||
+		pending.Status.Code != "QuotaExceeded" || pending.Status.Message != "account has 100 buckets" {
+		t.Errorf("wanted Pending claim with reason %q, code %q and details, got %+v", reasonProvisionerError, "QuotaExceeded", pending.Status)
+	}
+	want := corev1.EventTypeWarning + " " + reasonProvisionerError + " provisioning bucket failed with code QuotaExceeded: account has 100 buckets"
+	if event := <-recorder.Events; event != want {
+		t.Errorf("wanted event %q, got %q", want, event)
+	}
+
+	// the code is cleared once the bucket is provisioned
+	fail = false
+	if _, err = c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+	bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if bound.Status.Code != "" {
+		t.Errorf("wanted code to be cleared, got %q", bound.Status.Code)
+	}
+}
+
+func TestWaitForCapacity(t *testing.T) {
+	obc := newTestClaim(testName)
+	var provisioned bool
+	p := &fakeCapacityChecker{fakeProvisioner: fakeProvisioner{bucket: newTestBucket()}}
+	p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+		provisioned = true
+		return p.newBucket(options), nil
+	}
+	c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+	blocked := testutil.ToFloat64(capacityBlockedTotal)
+
+	if _, err := c.syncHandler(testKey(obc)); err == nil {
+		t.Fatalf("wanted claim to be requeued")
+	}
+	if provisioned {
+		t.Errorf("wanted bucket not to be provisioned while at capacity")
+	}
+	pending, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if pending.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhasePending || pending.Status.Reason != reasonWaitingForCapacity {
+		t.Errorf("wanted phase %q with reason %q, got %q with %q", v1alpha1.ObjectBucketClaimStatusPhasePending, reasonWaitingForCapacity, pending.Status.Phase, pending.Status.Reason)
+	}
+	if !meta.IsStatusConditionTrue(pending.Status.Conditions, v1alpha1.ObjectBucketClaimConditionWaitingForCapacity) {
+		t.Errorf("wanted condition %q to be true", v1alpha1.ObjectBucketClaimConditionWaitingForCapacity)
+	}
+	if got := testutil.ToFloat64(capacityBlockedTotal) - blocked; got != 1 {
+		t.Errorf("wanted 1 capacity-blocked claim, got %v", got)
+	}
+
+	p.hasCapacity = true
+	if _, err = c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+	bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if meta.IsStatusConditionTrue(bound.Status.Conditions, v1alpha1.ObjectBucketClaimConditionWaitingForCapacity) {
+		t.Errorf("wanted condition %q to be cleared", v1alpha1.ObjectBucketClaimConditionWaitingForCapacity)
+	}
+}
+
+func TestLongClaimName(t *testing.T) {
+	// valid for the claim, but too long for its OB, whose name includes the namespace
+	obc := newTestClaim(strings.Repeat("a", 250))
+	var provisioned bool
+	p := &fakeProvisioner{
+		provisionFunc: func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+			provisioned = true
+			return nil, fmt.Errorf("unexpected call")
+		},
+	}
+	c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+	recorder := record.NewFakeRecorder(10)
+	c.recorder = recorder
+
+	if _, err := c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseFailed)
+	if provisioned {
+		t.Errorf("wanted bucket not to be provisioned")
+	}
+	failed, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if len(failed.Finalizers) > 0 {
+		t.Errorf("wanted no finalizers on rejected claim, got %v", failed.Finalizers)
+	}
+	if failed.Status.Reason != reasonInvalidName {
+		t.Errorf("wanted status reason %q, got %q", reasonInvalidName, failed.Status.Reason)
+	}
+}
+
+func TestLongClaimNameHashing(t *testing.T) {
+	tests := []struct {
+		name              string
+		resourceNamespace string
+	}{
+		{
+			name: "OB name is hashed",
+		},
+		{
+			name:              "OB and resource namespace names are hashed",
+			resourceNamespace: "bucket-secrets",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// valid for the claim, but too long for its OB, whose name includes the namespace
+			obc := newTestClaim(strings.Repeat("a", 250))
+			options := []Option{WithLongNameHashing()}
+			if tt.resourceNamespace != "" {
+				options = append(options, WithResourceNamespace(tt.resourceNamespace))
+			}
+			c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc}, options...)
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+			// a second reconcile finds the resources under the same names
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error resyncing claim: %v", err)
+			}
+
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			obName := got.Spec.ObjectBucketName
+			if msgs := validation.IsDNS1123Subdomain(obName); len(msgs) > 0 {
+				t.Errorf("wanted a valid OB name, got %q: %v", obName, msgs)
+			}
+			if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), obName, metav1.GetOptions{}); err != nil {
+				t.Errorf("error getting OB %q: %v", obName, err)
+			}
+			obs, err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("error listing OBs: %v", err)
+			}
+			if len(obs.Items) != 1 {
+				t.Errorf("wanted a single OB, got %d", len(obs.Items))
+			}
+
+			childKey, err := childKeyForClaimKey(testKey(obc), tt.resourceNamespace)
+			if err != nil {
+				t.Fatalf("error getting child key: %v", err)
+			}
+			if _, err = configMapForClaimKey(childKey, c.clientset); err != nil {
+				t.Errorf("error getting configmap %q: %v", childKey, err)
+			}
+			_, childName, _ := cache.SplitMetaNamespaceKey(childKey)
+			if got.Status.ConfigMapName != childName || got.Status.SecretName != childName {
+				t.Errorf("wanted configmap and secret names %q in status, got %q and %q", childName, got.Status.ConfigMapName, got.Status.SecretName)
+			}
+		})
+	}
+}
+
+func TestSecretDrift(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     []Option
+		wantKey     string
+		wantDrifted bool
+		wantEvent   string
+	}{
+		{
+			name:    "rotated credentials are restored by default",
+			wantKey: "test-access-key",
+		},
+		{
+			name:        "rotated credentials are flagged",
+			options:     []Option{WithSecretDrift(SecretDriftFlag)},
+			wantKey:     "rotated-access-key",
+			wantDrifted: true,
+			wantEvent:   corev1.EventTypeWarning + " " + reasonCredentialsDrifted,
+		},
+		{
+			name:    "rotated credentials are restored with an unknown policy",
+			options: []Option{WithSecretDrift("Unknown")},
+			wantKey: "test-access-key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc}, tt.options...)
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			// an external secret manager rotates the credentials
+			secret, err := secretForClaimKey(testKey(obc), c.clientset)
+			if err != nil {
+				t.Fatalf("error getting secret: %v", err)
+			}
+			secret.StringData = nil
+			secret.Data = map[string][]byte{
+				v1alpha1.AwsKeyField:    []byte("rotated-access-key"),
+				v1alpha1.AwsSecretField: []byte("rotated-secret-key"),
+			}
+			if _, err = c.clientset.CoreV1().Secrets(secret.Namespace).Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+				t.Fatalf("error rotating secret: %v", err)
+			}
+			for len(recorder.Events) > 0 {
+				<-recorder.Events
+			}
+
+			if _, err = c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error resyncing claim: %v", err)
+			}
+
+			secret, err = secretForClaimKey(testKey(obc), c.clientset)
+			if err != nil {
+				t.Fatalf("error getting secret: %v", err)
+			}
+			if got := secretCredentials(secret, map[string]string{v1alpha1.AwsKeyField: ""})[v1alpha1.AwsKeyField]; got != tt.wantKey {
+				t.Errorf("wanted access key %q in secret, got %q", tt.wantKey, got)
+			}
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if drifted := meta.IsStatusConditionTrue(got.Status.Conditions, v1alpha1.ObjectBucketClaimConditionCredentialsDrifted); drifted != tt.wantDrifted {
+				t.Errorf("wanted condition %q %v, got %v", v1alpha1.ObjectBucketClaimConditionCredentialsDrifted, tt.wantDrifted, drifted)
+			}
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			if tt.wantEvent != "" && (len(events) == 0 || !strings.HasPrefix(events[0], tt.wantEvent+" ")) {
+				t.Errorf("wanted %q event, got %q", tt.wantEvent, events)
+			}
+		})
+	}
+}
+
+func TestKeepConfigMapOnRevoke(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		wantKept   bool
+	}{
+		{
+			name:       "configmap is released with the claim",
+			parameters: map[string]string{v1alpha1.StorageClassBucket: "existing-bucket"},
+		},
+		{
+			name: "configmap is kept",
+			parameters: map[string]string{
+				v1alpha1.StorageClassBucket:                "existing-bucket",
+				v1alpha1.StorageClassKeepConfigMapOnRevoke: "true",
+			},
+			wantKept: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{newTestStorageClass(tt.parameters)}, []runtime.Object{obc})
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			now := metav1.Now()
+			bound.DeletionTimestamp = &now
+			if _, err = updateClaim(c.libClientset, bound); err != nil {
+				t.Fatalf("error deleting claim: %v", err)
+			}
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing deleted claim: %v", err)
+			}
+			if outcome != outcomeRevoked {
+				t.Errorf("wanted outcome %q, got %q", outcomeRevoked, outcome)
+			}
+
+			// the claim's lifecycle completes either way
+			released, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if len(released.Finalizers) > 0 {
+				t.Errorf("wanted claim finalizers to be removed, got %v", released.Finalizers)
+			}
+			configMap, err := c.clientset.CoreV1().ConfigMaps(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting configmap: %v", err)
+			}
+			if len(configMap.Finalizers) > 0 {
+				t.Errorf("wanted configmap finalizers to be removed, got %v", configMap.Finalizers)
+			}
+			// a configmap owned by the claim is garbage collected with it
+			if kept := !objectIsOwnedByClaim(obc, configMap.OwnerReferences); kept != tt.wantKept {
+				t.Errorf("wanted configmap kept %v, got %v", tt.wantKept, kept)
+			}
+		})
+	}
+}
+
+func TestPreviousProvisionerNames(t *testing.T) {
+	const renamed = "renamed.io/bucket"
+
+	tests := []struct {
+		name        string
+		options     []Option
+		wantOutcome reconcileOutcome
+	}{
+		{
+			name:        "claim of previous name is cleaned up",
+			options:     []Option{WithPreviousProvisionerNames(provisionerName)},
+			wantOutcome: outcomeDeleted,
+		},
+		{
+			name:        "claim of other name is ignored",
+			wantOutcome: outcomeSkippedUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			old := newTestController(
+				&fakeProvisioner{bucket: newTestBucket()},
+				[]runtime.Object{newTestStorageClass(nil)},
+				[]runtime.Object{obc})
+			if _, err := old.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, old, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			factory := informers.NewSharedInformerFactory(old.libClientset, 0)
+			c := NewController(
+				renamed,
+				&fakeProvisioner{},
+				old.clientset,
+				old.libClientset,
+				factory.Objectbucket().V1alpha1().ObjectBucketClaims(),
+				factory.Objectbucket().V1alpha1().ObjectBuckets(),
+				tt.options...)
+
+			// the bound claim of the previous name is never provisioned again
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing bound claim: %v", err)
+			}
+			if outcome != outcomeSkippedUnsupported {
+				t.Errorf("wanted bound claim outcome %q, got %q", outcomeSkippedUnsupported, outcome)
+			}
+
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			now := metav1.Now()
+			bound.DeletionTimestamp = &now
+			if _, err = updateClaim(c.libClientset, bound); err != nil {
+				t.Fatalf("error deleting claim: %v", err)
+			}
+			outcome, err = c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing deleted claim: %v", err)
+			}
+			if outcome != tt.wantOutcome {
+				t.Errorf("wanted outcome %q, got %q", tt.wantOutcome, outcome)
+			}
+		})
+	}
+}
+
+func TestCreatedByLabel(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		// wantLabel is the label marking the OB as created by the controller
+		wantLabel string
+		// adminCreated removes the label, as for an OB created by an admin, and unreferenced also
+		// removes the OB's claimRef
+		adminCreated bool
+		unreferenced bool
+		wantOutcome  reconcileOutcome
+	}{
+		{
+			name:        "OB created by the controller is cleaned up",
+			wantLabel:   createdByLabelKey,
+			wantOutcome: outcomeDeleted,
+		},
+		{
+			name:        "configured label",
+			options:     []Option{WithCreatedByLabel("example.com/managed", "true")},
+			wantLabel:   "example.com/managed",
+			wantOutcome: outcomeDeleted,
+		},
+		{
+			name:         "OB created by an admin referencing the claim is cleaned up",
+			wantLabel:    createdByLabelKey,
+			adminCreated: true,
+			wantOutcome:  outcomeDeleted,
+		},
+		{
+			name:         "OB created by an admin without a claimRef is left",
+			wantLabel:    createdByLabelKey,
+			adminCreated: true,
+			unreferenced: true,
+			wantOutcome:  outcomeAbandoned,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			cleanups := 0
+			p := &fakeProvisioner{
+				bucket: newTestBucket(),
+				deleteFunc: func(ob *v1alpha1.ObjectBucket) error {
+					cleanups++
+					return nil
+				},
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc}, tt.options...)
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+			ob, err := c.objectBucketForClaimKey(testKey(obc))
+			if err != nil {
+				t.Fatalf("error getting OB: %v", err)
+			}
+			if _, ok := ob.Labels[tt.wantLabel]; !ok {
+				t.Fatalf("wanted OB labeled with %s, got %v", tt.wantLabel, ob.Labels)
+			}
+			if tt.adminCreated {
+				delete(ob.Labels, tt.wantLabel)
+				if tt.unreferenced {
+					ob.Spec.ClaimRef = nil
+				}
+				if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Update(context.TODO(), ob, metav1.UpdateOptions{}); err != nil {
+					t.Fatalf("error updating OB: %v", err)
+				}
+			}
+
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			now := metav1.Now()
+			bound.DeletionTimestamp = &now
+			if _, err = updateClaim(c.libClientset, bound); err != nil {
+				t.Fatalf("error deleting claim: %v", err)
+			}
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing deleted claim: %v", err)
+			}
+			if outcome != tt.wantOutcome {
+				t.Errorf("wanted outcome %q, got %q", tt.wantOutcome, outcome)
+			}
+			wantCleanups := 0
+			if tt.wantOutcome == outcomeDeleted {
+				wantCleanups = 1
+			}
+			if cleanups != wantCleanups {
+				t.Errorf("wanted %d cleanups, got %d", wantCleanups, cleanups)
+			}
+		})
+	}
+}
+
+func TestStorageClassProvisionerEdited(t *testing.T) {
+	const other = "other.io/bucket"
+
+	tests := []struct {
+		name string
+		// provisionedBy provisions the claim, after which the class is edited to name editedTo
+		provisionedBy string
+		editedTo      string
+		wantOutcome   reconcileOutcome
+		wantCleanups  int
+	}{
+		{
+			name:          "claim is cleaned up after class names another provisioner",
+			provisionedBy: provisionerName,
+			editedTo:      other,
+			wantOutcome:   outcomeDeleted,
+			wantCleanups:  1,
+		},
+		{
+			name:          "claim of another provisioner is ignored after class names this provisioner",
+			provisionedBy: other,
+			editedTo:      provisionerName,
+			wantOutcome:   outcomeSkippedUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			class := newTestStorageClass(nil)
+			class.Provisioner = tt.provisionedBy
+			cleanups := 0
+			p := &fakeProvisioner{
+				bucket: newTestBucket(),
+				deleteFunc: func(ob *v1alpha1.ObjectBucket) error {
+					cleanups++
+					return nil
+				},
+			}
+			c := newTestController(p, []runtime.Object{class}, []runtime.Object{obc})
+			factory := informers.NewSharedInformerFactory(c.libClientset, 0)
+			provisioning := NewController(
+				tt.provisionedBy,
+				&fakeProvisioner{bucket: newTestBucket()},
+				c.clientset,
+				c.libClientset,
+				factory.Objectbucket().V1alpha1().ObjectBucketClaims(),
+				factory.Objectbucket().V1alpha1().ObjectBuckets())
+			if _, err := provisioning.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			class.Provisioner = tt.editedTo
+			if _, err := c.clientset.StorageV1().StorageClasses().Update(context.TODO(), class, metav1.UpdateOptions{}); err != nil {
+				t.Fatalf("error editing StorageClass: %v", err)
+			}
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			now := metav1.Now()
+			bound.DeletionTimestamp = &now
+			if _, err = updateClaim(c.libClientset, bound); err != nil {
+				t.Fatalf("error deleting claim: %v", err)
+			}
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing deleted claim: %v", err)
+			}
+			if outcome != tt.wantOutcome {
+				t.Errorf("wanted outcome %q, got %q", tt.wantOutcome, outcome)
+			}
+			if cleanups != tt.wantCleanups {
+				t.Errorf("wanted %d bucket cleanups, got %d", tt.wantCleanups, cleanups)
+			}
+		})
+	}
+}
+
+func TestProvisioningWrites(t *testing.T) {
+	obc := newTestClaim(testName)
+	c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+	writes := map[string]int{}
+	countWrite := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if verb := action.GetVerb(); verb == "update" || verb == "patch" {
+			writes[action.GetResource().Resource+"/"+action.GetSubresource()]++
+		}
+		return false, nil, nil
+	}
+	c.libClientset.(*externalFake.Clientset).PrependReactor("*", "*", countWrite)
+
+	// the claim's metadata, bucket name and references are written once each, and its status when
+	// it moves to Pending, starts provisioning and is bound
+	if _, err := c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	want := map[string]int{"objectbucketclaims/": 3, "objectbucketclaims/status": 3, "objectbuckets/status": 1}
+	if !reflect.DeepEqual(writes, want) {
+		t.Errorf("wanted writes %v provisioning the claim, got %v", want, writes)
+	}
+
+	// syncing the bound claim again only refreshes its status
+	writes = map[string]int{}
+	if _, err := c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing bound claim: %v", err)
+	}
+	want = map[string]int{"objectbucketclaims/status": 1}
+	if !reflect.DeepEqual(writes, want) {
+		t.Errorf("wanted writes %v syncing the bound claim, got %v", want, writes)
+	}
+}
+
+func TestProvisionDuration(t *testing.T) {
+	clock := testingclock.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	p := &fakeProvisioner{bucket: newTestBucket()}
+	p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+		clock.Step(1500 * time.Millisecond)
+		return p.newBucket(options), nil
+	}
+	obc := newTestClaim(testName)
+	c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc}, WithClock(clock))
+
+	if _, err := c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if got.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		t.Fatalf("wanted phase %q, got %q", v1alpha1.ObjectBucketClaimStatusPhaseBound, got.Status.Phase)
+	}
+	if got.Status.ProvisionDurationSeconds != 1.5 {
+		t.Errorf("wanted provision duration 1.5s, got %vs", got.Status.ProvisionDurationSeconds)
+	}
+}
+
+func TestClaimDeletedWhileProvisioning(t *testing.T) {
+	tests := []struct {
+		name string
+		// deleteOn is the resource whose creation the claim is deleted during, or empty to delete it
+		// while the provisioner is called
+		deleteOn   string
+		parameters map[string]string
+	}{
+		{
+			name: "deleted during Provision",
+		},
+		{
+			name:       "deleted during Grant",
+			parameters: map[string]string{v1alpha1.StorageClassBucket: "existing-bucket"},
+		},
+		{
+			name:     "deleted while creating the secret",
+			deleteOn: "secrets",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			cleanups := 0
+			p := &fakeProvisioner{
+				bucket: newTestBucket(),
+				deleteFunc: func(ob *v1alpha1.ObjectBucket) error {
+					cleanups++
+					return nil
+				},
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(tt.parameters)}, []runtime.Object{obc})
+			deleteClaim := func() {
+				got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("error getting claim: %v", err)
+				}
+				now := metav1.Now()
+				got.DeletionTimestamp = &now
+				if _, err = updateClaim(c.libClientset, got); err != nil {
+					t.Fatalf("error deleting claim: %v", err)
+				}
+			}
+			if tt.deleteOn == "" {
+				p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+					deleteClaim()
+					return p.newBucket(options), nil
+				}
+			} else {
+				c.clientset.(*fake.Clientset).PrependReactor("create", tt.deleteOn, func(action k8stesting.Action) (bool, runtime.Object, error) {
+					deleteClaim()
+					return false, nil, nil
+				})
+			}
+
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			if outcome != outcomeDeleted {
+				t.Errorf("wanted outcome %q, got %q", outcomeDeleted, outcome)
+			}
+			if cleanups != 1 {
+				t.Errorf("wanted bucket cleaned up once, got %d", cleanups)
+			}
+
+			obs, err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("error listing OBs: %v", err)
+			}
+			if len(obs.Items) != 0 {
+				t.Errorf("wanted no OB, got %d", len(obs.Items))
+			}
+			// the secret is left to be garbage collected with the claim
+			if secret, err := c.clientset.CoreV1().Secrets(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{}); err == nil && len(secret.Finalizers) > 0 {
+				t.Errorf("wanted secret to be released, got finalizers %v", secret.Finalizers)
+			} else if err != nil && !errors.IsNotFound(err) {
+				t.Fatalf("error getting secret: %v", err)
+			}
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if hasFinalizer(got) {
+				t.Errorf("wanted finalizer to be removed, got %v", got.Finalizers)
+			}
+		})
+	}
+}
+
+func TestEventRecorder(t *testing.T) {
+	custom := record.NewFakeRecorder(10)
+	tests := []struct {
+		name     string
+		recorder record.EventRecorder
+	}{
+		{
+			name:     "custom recorder",
+			recorder: custom,
+		},
+		{
+			name: "events disabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			class := newTestStorageClass(map[string]string{v1alpha1.StorageClassProvisionTimeout: "soon"})
+			c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{class}, []runtime.Object{obc},
+				WithEventRecorder(tt.recorder))
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseFailed)
+
+			if tt.recorder == nil {
+				if _, ok := c.recorder.(discardRecorder); !ok {
+					t.Errorf("wanted events to be discarded, got recorder %T", c.recorder)
+				}
+				return
+			}
+			select {
+			case event := <-custom.Events:
+				if !strings.HasPrefix(event, corev1.EventTypeWarning+" "+reasonInvalidParameter+" ") {
+					t.Errorf("wanted %s event with reason %q, got %q", corev1.EventTypeWarning, reasonInvalidParameter, event)
+				}
+			default:
+				t.Errorf("wanted event with reason %q, got none", reasonInvalidParameter)
+			}
+		})
+	}
+}
+
+func TestProvisioningMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		want       v1alpha1.ObjectBucketClaimProvisioningMode
+	}{
+		{
+			name: "new bucket",
+			want: v1alpha1.ObjectBucketClaimProvisioningModeDynamic,
+		},
+		{
+			name:       "existing bucket",
+			parameters: map[string]string{v1alpha1.StorageClassBucket: "existing-bucket"},
+			want:       v1alpha1.ObjectBucketClaimProvisioningModeGrant,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{newTestStorageClass(tt.parameters)}, []runtime.Object{obc})
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if got.Status.ProvisioningMode != tt.want {
+				t.Errorf("wanted provisioning mode %q, got %q", tt.want, got.Status.ProvisioningMode)
+			}
+		})
+	}
+}
+
+func TestUnsupportedProvisionerEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		phase      v1alpha1.ObjectBucketClaimStatusPhase
+		wantEvents int
+	}{
+		{
+			name:       "new claim is reported once",
+			wantEvents: 1,
+		},
+		{
+			name:  "claim picked up by another controller is not reported",
+			phase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class := newTestStorageClass(nil)
+			class.Provisioner = "other.io/bucket"
+			obc := newTestClaim(testName)
+			obc.UID = types.UID("obc-uid")
+			obc.Status.Phase = tt.phase
+			c := newTestController(&fakeProvisioner{}, []runtime.Object{class}, []runtime.Object{obc})
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			for i := 0; i < 3; i++ {
+				outcome, err := c.syncHandler(testKey(obc))
+				if err != nil {
+					t.Fatalf("error syncing claim: %v", err)
+				}
+				if outcome != outcomeSkippedUnsupported {
+					t.Fatalf("wanted outcome %q, got %q", outcomeSkippedUnsupported, outcome)
+				}
+			}
+			if len(recorder.Events) != tt.wantEvents {
+				t.Fatalf("wanted %d events, got %d", tt.wantEvents, len(recorder.Events))
+			}
+			if tt.wantEvents > 0 {
+				if event := <-recorder.Events; !strings.Contains(event, reasonUnsupportedProvisioner) {
+					t.Errorf("wanted event with reason %q, got %q", reasonUnsupportedProvisioner, event)
+				}
+			}
+
+			// a deleted claim is forgotten, so a claim recreated under the same UID is reported again
+			c.forgetDeletedClaim(cache.DeletedFinalStateUnknown{Key: testKey(obc), Obj: obc})
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			if len(recorder.Events) != tt.wantEvents {
+				t.Errorf("wanted %d events after the claim was forgotten, got %d", tt.wantEvents, len(recorder.Events))
+			}
+		})
+	}
+}
+
+func TestVersioning(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         map[string]string
+		wantPhase      v1alpha1.ObjectBucketClaimStatusPhase
+		wantVersioning bool
+	}{
+		{
+			name:      "versioning not requested",
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:           "versioning requested",
+			config:         map[string]string{v1alpha1.AdditionalConfigVersioning: "true"},
+			wantPhase:      v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantVersioning: true,
+		},
+		{
+			name:      "versioning disabled",
+			config:    map[string]string{v1alpha1.AdditionalConfigVersioning: "false"},
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:      "invalid value",
+			config:    map[string]string{v1alpha1.AdditionalConfigVersioning: "sometimes"},
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var provisioned, versioning bool
+			p := &fakeProvisioner{bucket: newTestBucket()}
+			p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+				provisioned, versioning = true, options.Versioning
+				return p.newBucket(options), nil
+			}
+			obc := newTestClaim(testName)
+			obc.Spec.AdditionalConfig = tt.config
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+			if wantProvisioned := tt.wantPhase == v1alpha1.ObjectBucketClaimStatusPhaseBound; provisioned != wantProvisioned {
+				t.Errorf("wanted provisioned %v, got %v", wantProvisioned, provisioned)
+			}
+			if versioning != tt.wantVersioning {
+				t.Errorf("wanted versioning %v, got %v", tt.wantVersioning, versioning)
+			}
+		})
+	}
+}
+
+func TestAccessMode(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    map[string]string
+		schema    api.AdditionalConfigSchema
+		wantPhase v1alpha1.ObjectBucketClaimStatusPhase
+		wantMode  api.AccessMode
+	}{
+		{
+			name:      "read-write by default",
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantMode:  api.AccessModeReadWrite,
+		},
+		{
+			name:      "read-only requested",
+			config:    map[string]string{v1alpha1.AdditionalConfigAccess: "read"},
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantMode:  api.AccessModeRead,
+		},
+		{
+			name:      "read-write requested",
+			config:    map[string]string{v1alpha1.AdditionalConfigAccess: "readwrite"},
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantMode:  api.AccessModeReadWrite,
+		},
+		{
+			name:      "invalid value",
+			config:    map[string]string{v1alpha1.AdditionalConfigAccess: "write"},
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+		},
+		{
+			name:      "key interpreted by provisioner",
+			config:    map[string]string{v1alpha1.AdditionalConfigAccess: "admin"},
+			schema:    api.AdditionalConfigSchema{v1alpha1.AdditionalConfigAccess: nil},
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantMode:  api.AccessModeReadWrite,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mode api.AccessMode
+			p := &fakeValidator{fakeProvisioner: fakeProvisioner{bucket: newTestBucket()}, schema: tt.schema}
+			p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+				mode = options.AccessMode
+				return p.newBucket(options), nil
+			}
+			obc := newTestClaim(testName)
+			obc.Spec.AdditionalConfig = tt.config
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+			if mode != tt.wantMode {
+				t.Errorf("wanted access mode %q, got %q", tt.wantMode, mode)
+			}
+		})
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	const lifecycle = `[{"id":"logs","prefix":"logs/","expirationDays":30}]`
+	rules := []api.LifecycleRule{{ID: "logs", Prefix: "logs/", ExpirationDays: 30}}
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		unsupported bool
+		brownfield  bool
+		wantPhase   v1alpha1.ObjectBucketClaimStatusPhase
+		wantApplied []api.LifecycleRule
+	}{
+		{
+			name:      "no rules requested",
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:        "rules applied to new bucket",
+			config:      map[string]string{v1alpha1.AdditionalConfigLifecycle: lifecycle},
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantApplied: rules,
+		},
+		{
+			name:       "rules not applied to existing bucket",
+			config:     map[string]string{v1alpha1.AdditionalConfigLifecycle: lifecycle},
+			brownfield: true,
+			wantPhase:  v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:      "invalid rules",
+			config:    map[string]string{v1alpha1.AdditionalConfigLifecycle: `[{"id":"logs"}]`},
+			wantPhase: v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+		},
+		{
+			name:        "rules not supported by provisioner",
+			config:      map[string]string{v1alpha1.AdditionalConfigLifecycle: lifecycle},
+			unsupported: true,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options, applied []api.LifecycleRule
+			base := fakeProvisioner{bucket: newTestBucket()}
+			base.provisionFunc = func(o *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+				options = o.Lifecycle
+				return base.newBucket(o), nil
+			}
+			var p api.Provisioner = &fakeLifecycleApplier{
+				fakeUpdater: fakeUpdater{fakeProvisioner: base},
+				applyFunc: func(ob *v1alpha1.ObjectBucket, rules []api.LifecycleRule) error {
+					applied = rules
+					return nil
+				},
+			}
+			if tt.unsupported {
+				p = &base
+			}
+			parameters := map[string]string{}
+			if tt.brownfield {
+				parameters[v1alpha1.StorageClassBucket] = "existing-bucket"
+			}
+			obc := newTestClaim(testName)
+			obc.Spec.AdditionalConfig = tt.config
+			c := newTestController(p, []runtime.Object{newTestStorageClass(parameters)}, []runtime.Object{obc})
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+			if !reflect.DeepEqual(applied, tt.wantApplied) {
+				t.Errorf("wanted rules %v applied, got %v", tt.wantApplied, applied)
+			}
+			if !tt.brownfield && !reflect.DeepEqual(options, tt.wantApplied) {
+				t.Errorf("wanted rules %v passed to Provision, got %v", tt.wantApplied, options)
+			}
+		})
+	}
+}
+
+func TestBoundClaimInvalidAdditionalConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]string
+	}{
+		{name: "versioning", config: map[string]string{v1alpha1.AdditionalConfigVersioning: "sometimes"}},
+		{name: "access mode", config: map[string]string{v1alpha1.AdditionalConfigAccess: "write"}},
+		{name: "lifecycle", config: map[string]string{v1alpha1.AdditionalConfigLifecycle: `[{"id":"logs"}]`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var provisioned bool
+			p := &fakeProvisioner{bucket: newTestBucket()}
+			p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+				provisioned = true
+				return p.newBucket(options), nil
+			}
+			obc := newTestClaim(testName)
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			claims := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(testNamespace)
+			bound, err := claims.Get(context.TODO(), testName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			bound.Spec.AdditionalConfig = tt.config
+			if _, err = claims.Update(context.TODO(), bound, metav1.UpdateOptions{}); err != nil {
+				t.Fatalf("error updating claim: %v", err)
+			}
+			for len(recorder.Events) > 0 {
+				<-recorder.Events
+			}
+
+			provisioned = false
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("wanted bound claim not to be failed, got %v", err)
+			}
+			if outcome != outcomeFailedTerminal {
+				t.Errorf("wanted outcome %q, got %q", outcomeFailedTerminal, outcome)
+			}
+			if provisioned {
+				t.Errorf("wanted bucket not to be provisioned again")
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			var warned bool
+			for len(recorder.Events) > 0 {
+				if strings.HasPrefix(<-recorder.Events, corev1.EventTypeWarning+" "+reasonInvalidAdditionalConfig+" ") {
+					warned = true
+				}
+			}
+			if !warned {
+				t.Errorf("wanted %s event", reasonInvalidAdditionalConfig)
+			}
+		})
+	}
+}
+
+func TestUpdateLifecycle(t *testing.T) {
+	const lifecycle = `[{"id":"logs","expirationDays":30}]`
+
+	tests := []struct {
+		name        string
+		adopted     bool
+		oldConfig   map[string]string
+		newConfig   map[string]string
+		wantApplies int
+		wantApplied []api.LifecycleRule
+		wantUpdated bool
+	}{
+		{
+			name:        "rules added",
+			newConfig:   map[string]string{v1alpha1.AdditionalConfigLifecycle: lifecycle},
+			wantApplies: 1,
+			wantApplied: []api.LifecycleRule{{ID: "logs", ExpirationDays: 30}},
+			wantUpdated: true,
+		},
+		{
+			name:        "rules removed",
+			oldConfig:   map[string]string{v1alpha1.AdditionalConfigLifecycle: lifecycle},
+			newConfig:   map[string]string{"tenant": "a"},
+			wantApplies: 1,
+			wantUpdated: true,
+		},
+		{
+			name:        "rules unchanged",
+			oldConfig:   map[string]string{v1alpha1.AdditionalConfigLifecycle: lifecycle},
+			newConfig:   map[string]string{v1alpha1.AdditionalConfigLifecycle: lifecycle, "tenant": "a"},
+			wantUpdated: true,
+		},
+		{
+			name:        "rules not applied to adopted bucket",
+			adopted:     true,
+			newConfig:   map[string]string{v1alpha1.AdditionalConfigLifecycle: lifecycle},
+			wantUpdated: true,
+		},
+		{
+			name:      "invalid rules are not applied",
+			newConfig: map[string]string{v1alpha1.AdditionalConfigLifecycle: `{"id":"logs"}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			obc.Spec.AdditionalConfig = tt.newConfig
+			obc.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhaseBound
+			ob := newTestBucket()
+			ob.Name = "obc-" + testNamespace + "-" + testName
+			ob.Spec.StorageClassName = className
+			ob.Spec.Endpoint.AdditionalConfigData = tt.oldConfig
+			// an adoption class names no bucket, so only the OB tells the adopted bucket apart
+			parameters := map[string]string{}
+			if tt.adopted {
+				obc.Annotations = map[string]string{v1alpha1.AdoptBucketAnnotation: "existing-bucket"}
+				ob.Spec.Provisioning = v1alpha1.ObjectBucketProvisioningGrant
+				parameters[v1alpha1.StorageClassAllowBucketAdoption] = "true"
+				parameters[v1alpha1.StorageClassAllowBrownfieldUpdates] = "true"
+			}
+
+			var applies int
+			var applied []api.LifecycleRule
+			var updated bool
+			p := &fakeLifecycleApplier{
+				fakeUpdater: fakeUpdater{updateFunc: func(ob *v1alpha1.ObjectBucket) error {
+					updated = true
+					return nil
+				}},
+				applyFunc: func(ob *v1alpha1.ObjectBucket, rules []api.LifecycleRule) error {
+					applies++
+					applied = rules
+					return nil
+				},
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(parameters)}, []runtime.Object{obc, ob})
+			c.recorder = record.NewFakeRecorder(10)
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			if applies != tt.wantApplies || !reflect.DeepEqual(applied, tt.wantApplied) {
+				t.Errorf("wanted rules %v applied %d times, got %v applied %d times", tt.wantApplied, tt.wantApplies, applied, applies)
+			}
+			if updated != tt.wantUpdated {
+				t.Errorf("wanted updated %v, got %v", tt.wantUpdated, updated)
+			}
+		})
+	}
+}
+
+func TestDetectRegion(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		host       string
+		region     string
+		want       string
+	}{
+		{
+			name:       "region detected from AWS endpoint",
+			parameters: map[string]string{v1alpha1.StorageClassDetectRegion: "true"},
+			host:       "s3.eu-west-1.amazonaws.com",
+			want:       "eu-west-1",
+		},
+		{
+			name:       "region of provisioner is kept",
+			parameters: map[string]string{v1alpha1.StorageClassDetectRegion: "true"},
+			host:       "s3.eu-west-1.amazonaws.com",
+			region:     "us-east-2",
+			want:       "us-east-2",
+		},
+		{
+			name:       "region of custom endpoint is unknown",
+			parameters: map[string]string{v1alpha1.StorageClassDetectRegion: "true"},
+			host:       "rgw.storage.example.com",
+		},
+		{
+			name: "detection is opt-in",
+			host: "s3.eu-west-1.amazonaws.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := newTestBucket()
+			bucket.Spec.Endpoint.BucketHost = tt.host
+			bucket.Spec.Endpoint.Region = tt.region
+			obc := newTestClaim(testName)
+			c := newTestController(
+				&fakeProvisioner{bucket: bucket},
+				[]runtime.Object{newTestStorageClass(tt.parameters)},
+				[]runtime.Object{obc})
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			cm, err := c.clientset.CoreV1().ConfigMaps(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting configmap: %v", err)
+			}
+			if got := cm.Data[bucketRegion]; got != tt.want {
+				t.Errorf("wanted %s %q, got %q", bucketRegion, tt.want, got)
+			}
+			// the region is only detected for the configmap
+			ob, err := c.objectBucketForClaimKey(testKey(obc))
+			if err != nil {
+				t.Fatalf("error getting OB: %v", err)
+			}
+			if ob.Spec.Endpoint.Region != tt.region {
+				t.Errorf("wanted OB region %q, got %q", tt.region, ob.Spec.Endpoint.Region)
+			}
+		})
+	}
+}
+
+func TestCreationOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   []Option
+		wantOrder []string
+	}{
+		{
+			name:      "secret first",
+			wantOrder: []string{"secrets", "configmaps", "objectbuckets"},
+		},
+		{
+			name:      "configmap first",
+			options:   []Option{WithConfigMapFirst()},
+			wantOrder: []string{"configmaps", "secrets", "objectbuckets"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc}, tt.options...)
+			var order []string
+			recordCreate := func(action k8stesting.Action) (bool, runtime.Object, error) {
+				order = append(order, action.GetResource().Resource)
+				return false, nil, nil
+			}
+			c.clientset.(*fake.Clientset).PrependReactor("create", "*", recordCreate)
+			c.libClientset.(*externalFake.Clientset).PrependReactor("create", "objectbuckets", recordCreate)
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			if !reflect.DeepEqual(order, tt.wantOrder) {
+				t.Errorf("wanted resources created in order %v, got %v", tt.wantOrder, order)
+			}
+		})
+	}
+}
+
+func TestChildValidators(t *testing.T) {
+	tests := []struct {
+		name        string
+		validators  []ChildValidator
+		wantOutcome reconcileOutcome
+		wantPhase   v1alpha1.ObjectBucketClaimStatusPhase
+		wantReason  string
+	}{
+		{
+			name:        "valid",
+			validators:  []ChildValidator{MaxChildSize(1 << 20), ForbiddenChildKeys("token")},
+			wantOutcome: outcomeProvisioned,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+		{
+			name:        "oversize",
+			validators:  []ChildValidator{MaxChildSize(16)},
+			wantOutcome: outcomeFailedTerminal,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason:  reasonChildValidationFailed,
+		},
+		{
+			name:        "forbidden key",
+			validators:  []ChildValidator{ForbiddenChildKeys(v1alpha1.AwsSecretField)},
+			wantOutcome: outcomeFailedTerminal,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason:  reasonChildValidationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			deleted := false
+			p := &fakeProvisioner{
+				bucket: newTestBucket(),
+				deleteFunc: func(ob *v1alpha1.ObjectBucket) error {
+					deleted = true
+					return nil
+				},
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc}, WithChildValidators(tt.validators...))
+
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			if outcome != tt.wantOutcome {
+				t.Errorf("wanted outcome %q, got %q", tt.wantOutcome, outcome)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if got.Status.Reason != tt.wantReason {
+				t.Errorf("wanted status reason %q, got %q", tt.wantReason, got.Status.Reason)
+			}
+
+			// a rejected bucket is deleted before either child is created
+			rejected := tt.wantPhase == v1alpha1.ObjectBucketClaimStatusPhaseFailed
+			if deleted != rejected {
+				t.Errorf("wanted bucket deleted %t, got %t", rejected, deleted)
+			}
+			secrets, err := c.clientset.CoreV1().Secrets(obc.Namespace).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("error listing secrets: %v", err)
+			}
+			configMaps, err := c.clientset.CoreV1().ConfigMaps(obc.Namespace).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("error listing configmaps: %v", err)
+			}
+			if created := len(secrets.Items) > 0 || len(configMaps.Items) > 0; created == rejected {
+				t.Errorf("wanted children created %t, got %d secrets and %d configmaps", !rejected, len(secrets.Items), len(configMaps.Items))
+			}
+		})
+	}
+}
+
+func TestCredentialsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     []Option
+		validateErr error
+		wantOutcome reconcileOutcome
+		wantPhase   v1alpha1.ObjectBucketClaimStatusPhase
+		wantReason  string
+		wantCalls   int
+	}{
+		{
+			name:        "valid credentials",
+			options:     []Option{WithCredentialsValidation()},
+			wantOutcome: outcomeProvisioned,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseBound,
+			wantCalls:   1,
+		},
+		{
+			name:        "invalid credentials",
+			options:     []Option{WithCredentialsValidation()},
+			validateErr: fmt.Errorf("access denied"),
+			wantOutcome: outcomeFailedTerminal,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+			wantReason:  reasonInvalidCredentials,
+			wantCalls:   1,
+		},
+		{
+			name:        "validation not enabled",
+			validateErr: fmt.Errorf("access denied"),
+			wantOutcome: outcomeProvisioned,
+			wantPhase:   v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			calls, deleted := 0, false
+			p := &fakeCredentialsValidator{
+				fakeProvisioner: fakeProvisioner{
+					bucket: newTestBucket(),
+					deleteFunc: func(ob *v1alpha1.ObjectBucket) error {
+						deleted = true
+						return nil
+					},
+				},
+				validateFunc: func(ob *v1alpha1.ObjectBucket) error {
+					calls++
+					if ob.Spec.Authentication.AccessKeys.AccessKeyID != "test-access-key" {
+						t.Errorf("wanted the returned credentials validated, got %+v", ob.Spec.Authentication.AccessKeys)
+					}
+					return tt.validateErr
+				},
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc}, tt.options...)
+
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			if outcome != tt.wantOutcome {
+				t.Errorf("wanted outcome %q, got %q", tt.wantOutcome, outcome)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("wanted %d validations, got %d", tt.wantCalls, calls)
+			}
+			waitForClaimPhase(t, c, obc, tt.wantPhase)
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if got.Status.Reason != tt.wantReason {
+				t.Errorf("wanted status reason %q, got %q", tt.wantReason, got.Status.Reason)
+			}
+
+			// the bucket with broken credentials is deleted and no secret is written
+			rejected := tt.wantPhase == v1alpha1.ObjectBucketClaimStatusPhaseFailed
+			if deleted != rejected {
+				t.Errorf("wanted bucket deleted %t, got %t", rejected, deleted)
+			}
+			_, err = c.clientset.CoreV1().Secrets(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if written := err == nil; written == rejected {
+				t.Errorf("wanted secret written %t, got error %v", !rejected, err)
+			}
+		})
+	}
+}
+
+func TestResourceCreationForbidden(t *testing.T) {
+	obc := newTestClaim(testName)
+	c := newTestController(
+		&fakeProvisioner{bucket: newTestBucket()},
+		[]runtime.Object{newTestStorageClass(nil)},
+		[]runtime.Object{obc})
+	quotaExceeded := true
+	c.clientset.(*fake.Clientset).PrependReactor("create", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if !quotaExceeded {
+			return false, nil, nil
+		}
+		return true, nil, errors.NewForbidden(corev1.Resource("secrets"), obc.Name, fmt.Errorf("exceeded quota: compute-resources, requested: count/secrets=1"))
+	})
+	key := testKey(obc)
+	getClaim := func() *v1alpha1.ObjectBucketClaim {
+		got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("error getting claim: %v", err)
+		}
+		return got
+	}
+
+	c.queue.Add(key)
+	if !c.processNextItemInQueue(0) {
+		t.Fatal("queue unexpectedly shut down")
+	}
+	// the claim is requeued with the forbidden backoff rather than the rate limiter's
+	if n := c.queue.NumRequeues(key); n != 0 {
+		t.Errorf("wanted no rate limited requeues, got %d", n)
+	}
+	if n := c.forbiddenBackoff.NumRequeues(key); n != 1 {
+		t.Errorf("wanted 1 forbidden requeue, got %d", n)
+	}
+	cond := meta.FindStatusCondition(getClaim().Status.Conditions, v1alpha1.ObjectBucketClaimConditionResourceCreationForbidden)
+	if cond == nil || cond.Status != metav1.ConditionTrue || !strings.Contains(cond.Message, "exceeded quota") {
+		t.Fatalf("wanted condition %q with the API message, got %+v", v1alpha1.ObjectBucketClaimConditionResourceCreationForbidden, cond)
+	}
+
+	quotaExceeded = false
+	c.queue.Add(key)
+	if !c.processNextItemInQueue(0) {
+		t.Fatal("queue unexpectedly shut down")
+	}
+	got := getClaim()
+	if got.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseBound {
+		t.Fatalf("wanted phase %q, got %q", v1alpha1.ObjectBucketClaimStatusPhaseBound, got.Status.Phase)
+	}
+	if meta.IsStatusConditionTrue(got.Status.Conditions, v1alpha1.ObjectBucketClaimConditionResourceCreationForbidden) {
+		t.Errorf("wanted condition %q cleared", v1alpha1.ObjectBucketClaimConditionResourceCreationForbidden)
+	}
+	if n := c.forbiddenBackoff.NumRequeues(key); n != 0 {
+		t.Errorf("wanted forbidden backoff reset, got %d requeues", n)
+	}
+}
+
+func TestErrorClassifier(t *testing.T) {
+	const delay = 10 * time.Millisecond
+	throttled := fmt.Errorf("backend throttled")
+
+	tests := []struct {
+		name            string
+		provisionErr    error
+		classifier      ErrorClassifier
+		wantRateLimited bool
+		wantRequeued    bool
+	}{
+		{
+			name:            "default retries after backoff",
+			provisionErr:    throttled,
+			wantRateLimited: true,
+			wantRequeued:    true,
+		},
+		{
+			name:         "default retries after provisioner's delay",
+			provisionErr: bucketerrors.NewRequeueAfterError("bucket is being created", delay),
+			wantRequeued: true,
+		},
+		{
+			name:         "retried after delay",
+			provisionErr: throttled,
+			classifier: func(err error) RequeueDecision {
+				return RequeueDecision{Action: RequeueActionAfter, After: delay}
+			},
+			wantRequeued: true,
+		},
+		{
+			name:         "forgotten",
+			provisionErr: throttled,
+			classifier: func(err error) RequeueDecision {
+				return RequeueDecision{Action: RequeueActionForget}
+			},
+		},
+		{
+			name:         "rate limited",
+			provisionErr: bucketerrors.NewRequeueAfterError("bucket is being created", delay),
+			classifier: func(err error) RequeueDecision {
+				return RequeueDecision{Action: RequeueActionRateLimited}
+			},
+			wantRateLimited: true,
+			wantRequeued:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			p := &fakeProvisioner{
+				provisionFunc: func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+					return nil, tt.provisionErr
+				},
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc}, WithErrorClassifier(tt.classifier))
+			key := testKey(obc)
+
+			c.queue.Add(key)
+			if !c.processNextItemInQueue(0) {
+				t.Fatal("queue unexpectedly shut down")
+			}
+			if rateLimited := c.queue.NumRequeues(key) > 0; rateLimited != tt.wantRateLimited {
+				t.Errorf("wanted rate limited %v, got %d requeues", tt.wantRateLimited, c.queue.NumRequeues(key))
+			}
+			// rate limited requeues are delayed by at most the base delay of the default rate
+			// limiter
+			time.Sleep(10 * delay)
+			if requeued := c.queue.Len() > 0; requeued != tt.wantRequeued {
+				t.Errorf("wanted requeued %v, got queue length %d", tt.wantRequeued, c.queue.Len())
+			}
+		})
+	}
+}
+
+func TestResourceNamespace(t *testing.T) {
+	const resourceNamespace = "bucket-secrets"
+
+	obc := newTestClaim(testName)
+	c := newTestController(
+		&fakeProvisioner{bucket: newTestBucket()},
+		[]runtime.Object{newTestStorageClass(nil)},
+		[]runtime.Object{obc},
+		WithResourceNamespace(resourceNamespace))
+
+	if _, err := c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+	childKey := resourceNamespace + "/" + fmt.Sprintf(objectBucketNameFormat, obc.Namespace, obc.Name)
+	secret, err := secretForClaimKey(childKey, c.clientset)
+	if err != nil {
+		t.Fatalf("error getting secret: %v", err)
+	}
+	configMap, err := configMapForClaimKey(childKey, c.clientset)
+	if err != nil {
+		t.Fatalf("error getting configmap: %v", err)
+	}
+	for _, child := range []metav1.Object{secret, configMap} {
+		if len(child.GetOwnerReferences()) != 0 {
+			t.Errorf("wanted no owner references on %s, got %v", child.GetName(), child.GetOwnerReferences())
+		}
+		if !childIsOwnedByClaim(obc, child) {
+			t.Errorf("wanted %s owned by the claim, got annotations %v", child.GetName(), child.GetAnnotations())
+		}
+	}
+	if _, err = secretForClaimKey(testKey(obc), c.clientset); !errors.IsNotFound(err) {
+		t.Errorf("wanted no secret in the claim's namespace, got error %v", err)
+	}
+
+	// the resources are not garbage collected, so they are deleted with the claim
+	bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	now := metav1.Now()
+	bound.DeletionTimestamp = &now
+	if _, err = updateClaim(c.libClientset, bound); err != nil {
+		t.Fatalf("error deleting claim: %v", err)
+	}
+	if _, err = c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing deleted claim: %v", err)
+	}
+	if _, err = secretForClaimKey(childKey, c.clientset); !errors.IsNotFound(err) {
+		t.Errorf("wanted secret deleted, got error %v", err)
+	}
+	if _, err = configMapForClaimKey(childKey, c.clientset); !errors.IsNotFound(err) {
+		t.Errorf("wanted configmap deleted, got error %v", err)
+	}
+}
+
+func TestDeletionOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     []Option
+		wantDeleted []string
+	}{
+		{
+			name:        "OB first, children garbage collected",
+			wantDeleted: []string{"objectbuckets"},
+		},
+		{
+			name:        "children first",
+			options:     []Option{WithChildrenDeletedFirst()},
+			wantDeleted: []string{"secrets", "configmaps", "objectbuckets"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			c := newTestController(
+				&fakeProvisioner{bucket: newTestBucket()},
+				[]runtime.Object{newTestStorageClass(nil)},
+				[]runtime.Object{obc},
+				tt.options...)
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			// the fake clientset assigns no UIDs, without which the OB is not deleted
+			ob, err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), fmt.Sprintf(objectBucketNameFormat, obc.Namespace, obc.Name), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting OB: %v", err)
+			}
+			ob.UID = "ob-uid"
+			if _, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Update(context.TODO(), ob, metav1.UpdateOptions{}); err != nil {
+				t.Fatalf("error updating OB: %v", err)
+			}
+
+			var deleted []string
+			recordDelete := func(action k8stesting.Action) (bool, runtime.Object, error) {
+				deleted = append(deleted, action.GetResource().Resource)
+				return false, nil, nil
+			}
+			c.clientset.(*fake.Clientset).PrependReactor("delete", "*", recordDelete)
+			c.libClientset.(*externalFake.Clientset).PrependReactor("delete", "objectbuckets", recordDelete)
+
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			now := metav1.Now()
+			bound.DeletionTimestamp = &now
+			if _, err = updateClaim(c.libClientset, bound); err != nil {
+				t.Fatalf("error deleting claim: %v", err)
+			}
+			if _, err = c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing deleted claim: %v", err)
+			}
+			if !reflect.DeepEqual(deleted, tt.wantDeleted) {
+				t.Errorf("wanted resources deleted in order %v, got %v", tt.wantDeleted, deleted)
+			}
+		})
+	}
+}
+
+func TestProvisioningRecordedOnObjectBucket(t *testing.T) {
+	brownfield := map[string]string{v1alpha1.StorageClassBucket: "existing-bucket"}
+	tests := []struct {
+		name             string
+		parameters       map[string]string
+		editedParameters map[string]string
+		wantProvisioning v1alpha1.ObjectBucketProvisioning
+		wantOutcome      reconcileOutcome
+	}{
+		{
+			name:             "provisioned bucket is deleted after class is edited to grant access",
+			editedParameters: brownfield,
+			wantProvisioning: v1alpha1.ObjectBucketProvisioningDynamic,
+			wantOutcome:      outcomeDeleted,
+		},
+		{
+			name:             "granted access is revoked after class is edited to provision",
+			parameters:       brownfield,
+			wantProvisioning: v1alpha1.ObjectBucketProvisioningGrant,
+			wantOutcome:      outcomeRevoked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			class := newTestStorageClass(tt.parameters)
+			c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{class}, []runtime.Object{obc})
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+			obName, err := objectBucketNameFromClaimKey(testKey(obc))
+			if err != nil {
+				t.Fatalf("error composing OB name: %v", err)
+			}
+			ob, err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), obName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting OB: %v", err)
+			}
+			if ob.Spec.Provisioning != tt.wantProvisioning {
+				t.Errorf("wanted OB provisioning %q, got %q", tt.wantProvisioning, ob.Spec.Provisioning)
+			}
+
+			class.Parameters = tt.editedParameters
+			if _, err = c.clientset.StorageV1().StorageClasses().Update(context.TODO(), class, metav1.UpdateOptions{}); err != nil {
+				t.Fatalf("error editing StorageClass: %v", err)
+			}
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			now := metav1.Now()
+			bound.DeletionTimestamp = &now
+			if _, err = updateClaim(c.libClientset, bound); err != nil {
+				t.Fatalf("error deleting claim: %v", err)
+			}
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing deleted claim: %v", err)
+			}
+			if outcome != tt.wantOutcome {
+				t.Errorf("wanted outcome %q, got %q", tt.wantOutcome, outcome)
+			}
+		})
+	}
+}
+
+func TestOnReleased(t *testing.T) {
+	tests := []struct {
+		name       string
+		onReleased error
+	}{
+		{
+			name: "hook is called before the bucket is deleted",
+		},
+		{
+			name:       "hook failure does not block deletion",
+			onReleased: fmt.Errorf("archive unavailable"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			p := &fakeReleaseHandler{
+				fakeProvisioner: fakeProvisioner{
+					bucket: newTestBucket(),
+					deleteFunc: func(ob *v1alpha1.ObjectBucket) error {
+						calls = append(calls, "Delete")
+						return nil
+					},
+				},
+				onReleasedFunc: func(ob *v1alpha1.ObjectBucket) error {
+					calls = append(calls, "OnReleased:"+string(ob.Status.Phase))
+					return tt.onReleased
+				},
+			}
+			obc := newTestClaim(testName)
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			now := metav1.Now()
+			bound.DeletionTimestamp = &now
+			if _, err = updateClaim(c.libClientset, bound); err != nil {
+				t.Fatalf("error deleting claim: %v", err)
+			}
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("error syncing deleted claim: %v", err)
+			}
+			if outcome != outcomeDeleted {
+				t.Errorf("wanted outcome %q, got %q", outcomeDeleted, outcome)
+			}
+
+			want := []string{"OnReleased:" + string(v1alpha1.ObjectBucketStatusPhaseReleased), "Delete"}
+			if !reflect.DeepEqual(calls, want) {
+				t.Errorf("wanted provisioner calls %v, got %v", want, calls)
+			}
+			released := 0
+			for len(recorder.Events) > 0 {
+				if strings.HasPrefix(<-recorder.Events, corev1.EventTypeNormal+" "+reasonReleased) {
+					released++
+				}
+			}
+			if released != 2 {
+				t.Errorf("wanted a %s event on the claim and the OB, got %d", reasonReleased, released)
+			}
+		})
+	}
+}
+
+func TestReleaseClaimConflict(t *testing.T) {
+	tests := []struct {
+		name      string
+		conflicts int
+		wantSyncs int
+	}{
+		{
+			name:      "conflict is retried",
+			conflicts: 1,
+			wantSyncs: 1,
+		},
+		{
+			name:      "persistent conflict requeues without deleting the bucket again",
+			conflicts: retry.DefaultRetry.Steps,
+			wantSyncs: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deletes int
+			p := &fakeProvisioner{
+				bucket: newTestBucket(),
+				deleteFunc: func(ob *v1alpha1.ObjectBucket) error {
+					deletes++
+					return nil
+				},
+			}
+			obc := newTestClaim(testName)
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			conflicts := tt.conflicts
+			c.libClientset.(*externalFake.Clientset).PrependReactor("update", "objectbucketclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				claim := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.ObjectBucketClaim)
+				if conflicts == 0 || len(claim.Finalizers) != 0 {
+					return false, nil, nil
+				}
+				conflicts--
+				return true, nil, errors.NewConflict(v1alpha1.Resource("objectbucketclaims"), claim.Name, fmt.Errorf("claim was modified"))
+			})
+
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			now := metav1.Now()
+			bound.DeletionTimestamp = &now
+			if _, err = updateClaim(c.libClientset, bound); err != nil {
+				t.Fatalf("error deleting claim: %v", err)
+			}
+			for i := 1; i <= tt.wantSyncs; i++ {
+				_, err = c.syncHandler(testKey(obc))
+				if wantErr := i < tt.wantSyncs; (err != nil) != wantErr {
+					t.Fatalf("sync %d: wantErr %v, error = %v", i, wantErr, err)
+				}
+			}
+
+			if deletes != 1 {
+				t.Errorf("wanted bucket deleted once, got %d", deletes)
+			}
+			released, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if len(released.Finalizers) != 0 {
+				t.Errorf("wanted finalizer removed, got %v", released.Finalizers)
+			}
+		})
+	}
+}
+
+func TestConflictRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		subresource string
+		retries     int
+		wantErr     bool
+	}{
+		{
+			name:    "conflicting claim update is retried",
+			retries: 1,
+		},
+		{
+			name:        "conflicting status update is retried",
+			subresource: "status",
+			retries:     1,
+		},
+		{
+			name:    "conflict fails the sync without retries",
+			wantErr: true,
+		},
+		{
+			name:        "conflicting status update fails the sync without retries",
+			subresource: "status",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			c := newTestController(
+				&fakeProvisioner{bucket: newTestBucket()},
+				[]runtime.Object{newTestStorageClass(nil)},
+				[]runtime.Object{obc},
+				WithConflictRetries(tt.retries))
+
+			// the first update is preceded by a concurrent edit of the claim, which it conflicts with
+			conflicted := false
+			client := c.libClientset.(*externalFake.Clientset)
+			client.PrependReactor("update", "objectbucketclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if conflicted || action.GetSubresource() != tt.subresource {
+					return false, nil, nil
+				}
+				conflicted = true
+				claim := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.ObjectBucketClaim)
+				current, err := client.Tracker().Get(action.GetResource(), claim.Namespace, claim.Name)
+				if err != nil {
+					return true, nil, err
+				}
+				edited := current.(*v1alpha1.ObjectBucketClaim).DeepCopy()
+				edited.Annotations = map[string]string{"edited": "true"}
+				if err = client.Tracker().Update(action.GetResource(), edited, claim.Namespace); err != nil {
+					return true, nil, err
+				}
+				return true, nil, errors.NewConflict(v1alpha1.Resource("objectbucketclaims"), claim.Name, fmt.Errorf("claim was modified"))
+			})
+
+			_, err := c.syncHandler(testKey(obc))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wantErr %v, error = %v", tt.wantErr, err)
+			}
+			if !conflicted {
+				t.Fatal("wanted an update to conflict")
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if got.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseBound {
+				t.Errorf("wanted phase %q, got %q", v1alpha1.ObjectBucketClaimStatusPhaseBound, got.Status.Phase)
+			}
+			if got.Spec.ObjectBucketName == "" {
+				t.Error("wanted the claim to reference its OB")
+			}
+			if got.Annotations["edited"] != "true" {
+				t.Errorf("wanted the concurrent edit to be preserved, got annotations %v", got.Annotations)
+			}
+		})
+	}
+}
+
+func TestConflictingSpecEdit(t *testing.T) {
+	const renamed = "renamed"
+	obc := newTestClaim(testName)
+	c := newTestController(
+		&fakeProvisioner{bucket: newTestBucket()},
+		[]runtime.Object{newTestStorageClass(nil)},
+		[]runtime.Object{obc},
+		WithConflictRetries(1))
+
+	// the first update of the claim's spec is preceded by a user editing the spec, which it
+	// conflicts with
+	conflicted := false
+	client := c.libClientset.(*externalFake.Clientset)
+	client.PrependReactor("update", "objectbucketclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicted || action.GetSubresource() != "" {
+			return false, nil, nil
+		}
+		conflicted = true
+		claim := action.(k8stesting.UpdateAction).GetObject().(*v1alpha1.ObjectBucketClaim)
+		current, err := client.Tracker().Get(action.GetResource(), claim.Namespace, claim.Name)
+		if err != nil {
+			return true, nil, err
+		}
+		edited := current.(*v1alpha1.ObjectBucketClaim).DeepCopy()
+		edited.Spec.GenerateBucketName = renamed
+		if err = client.Tracker().Update(action.GetResource(), edited, claim.Namespace); err != nil {
+			return true, nil, err
+		}
+		return true, nil, errors.NewConflict(v1alpha1.Resource("objectbucketclaims"), claim.Name, fmt.Errorf("claim was modified"))
+	})
+
+	// the reconcile does not write the bucket name it composed from the previous spec
+	if _, err := c.syncHandler(testKey(obc)); err == nil {
+		t.Fatal("wanted the conflict to fail the sync")
+	}
+	got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if got.Spec.BucketName != "" || got.Spec.GenerateBucketName != renamed {
+		t.Fatalf("wanted the edited spec preserved, got bucketName %q and generateBucketName %q", got.Spec.BucketName, got.Spec.GenerateBucketName)
+	}
+
+	// the requeued reconcile applies the edited spec
+	if _, err = c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+	got, err = c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if !strings.HasPrefix(got.Spec.BucketName, renamed) {
+		t.Errorf("wanted bucket name generated from %q, got %q", renamed, got.Spec.BucketName)
+	}
+}
+
+func TestDeletedClaimWithoutFinalizer(t *testing.T) {
+	tests := []struct {
+		name        string
+		cleanedUp   bool
+		wantDeleted bool
+	}{
+		{
+			name:        "finalizer removed by user",
+			wantDeleted: true,
+		},
+		{
+			name:      "finalizer removed after cleanup",
+			cleanedUp: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted bool
+			p := &fakeProvisioner{
+				bucket: newTestBucket(),
+				deleteFunc: func(ob *v1alpha1.ObjectBucket) error {
+					deleted = true
+					return nil
+				},
+			}
+			obc := newTestClaim(testName)
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+			obName := fmt.Sprintf(objectBucketNameFormat, obc.Namespace, obc.Name)
+			if tt.cleanedUp {
+				if err := c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Delete(context.TODO(), obName, metav1.DeleteOptions{}); err != nil {
+					t.Fatalf("error deleting OB: %v", err)
+				}
+			}
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			now := metav1.Now()
+			bound.DeletionTimestamp = &now
+			bound.Finalizers = nil
+			if _, err = updateClaim(c.libClientset, bound); err != nil {
+				t.Fatalf("error deleting claim: %v", err)
+			}
+			if _, err = c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing deleted claim: %v", err)
+			}
+
+			if deleted != tt.wantDeleted {
+				t.Errorf("wanted bucket deleted %v, got %v", tt.wantDeleted, deleted)
+			}
+			select {
+			case event := <-recorder.Events:
+				if !tt.wantDeleted || !strings.Contains(event, reasonFinalizerMissing) {
+					t.Errorf("unexpected event %q", event)
+				}
+			default:
+				if tt.wantDeleted {
+					t.Errorf("wanted event with reason %q, got none", reasonFinalizerMissing)
+				}
+			}
+		})
+	}
+}
+
+func TestMissingBucketName(t *testing.T) {
+	tests := []struct {
+		name       string
+		bucketName string
+	}{
+		{
+			name: "empty parameter and no bucket name in claim",
+		},
+		{
+			name:       "blank parameter",
+			bucketName: "  ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			obc.Spec.GenerateBucketName = ""
+			var provisioned bool
+			p := &fakeProvisioner{
+				provisionFunc: func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+					provisioned = true
+					return nil, fmt.Errorf("unexpected call")
+				},
+			}
+			class := newTestStorageClass(map[string]string{v1alpha1.StorageClassBucket: tt.bucketName})
+			c := newTestController(p, []runtime.Object{class}, []runtime.Object{obc})
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+
+			outcome, err := c.syncHandler(testKey(obc))
+			if err != nil {
+				t.Fatalf("wanted claim not to be requeued, got error %v", err)
+			}
+			if outcome != outcomeFailedTerminal {
+				t.Errorf("wanted outcome %q, got %q", outcomeFailedTerminal, outcome)
+			}
+			if provisioned {
+				t.Errorf("wanted bucket not to be provisioned")
+			}
+			failed, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting claim: %v", err)
+			}
+			if failed.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhaseFailed || failed.Status.Reason != reasonBucketNameMissing {
+				t.Errorf("wanted phase %q with reason %q, got %q with %q", v1alpha1.ObjectBucketClaimStatusPhaseFailed, reasonBucketNameMissing, failed.Status.Phase, failed.Status.Reason)
+			}
+			if event := <-recorder.Events; !strings.Contains(event, reasonBucketNameMissing) {
+				t.Errorf("wanted event with reason %q, got %q", reasonBucketNameMissing, event)
+			}
+		})
+	}
+}
+
+func TestWaitForCacheSync(t *testing.T) {
+	tests := []struct {
+		name     string
+		syncedIn time.Duration
+		retries  int
+		stopped  bool
+		wantErr  bool
+	}{
+		{
+			name:     "delayed sync is waited out",
+			syncedIn: 250 * time.Millisecond,
+			retries:  2,
+		},
+		{
+			name:     "retries are exhausted",
+			syncedIn: time.Hour,
+			retries:  1,
+			wantErr:  true,
+		},
+		{
+			name:     "stop channel aborts waiting",
+			syncedIn: time.Hour,
+			retries:  10,
+			stopped:  true,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestController(&fakeProvisioner{}, nil, nil, WithCacheSyncTimeout(100*time.Millisecond, tt.retries))
+			start := time.Now()
+			hasSynced := func() bool {
+				return time.Since(start) >= tt.syncedIn
+			}
+			stopCh := make(chan struct{})
+			if tt.stopped {
+				close(stopCh)
+			}
+
+			if err := c.waitForCacheSync(stopCh, hasSynced); (err != nil) != tt.wantErr {
+				t.Errorf("waitForCacheSync() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("wanted waiting to end promptly, took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestShutdownSummary(t *testing.T) {
+	var got ShutdownSummary
+	c := newTestController(&fakeProvisioner{}, nil, nil, WithShutdownSummary(func(s ShutdownSummary) {
+		got = s
+	}))
+
+	phases := []v1alpha1.ObjectBucketClaimStatusPhase{
+		v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		v1alpha1.ObjectBucketClaimStatusPhaseBound,
+		v1alpha1.ObjectBucketClaimStatusPhasePending,
+		v1alpha1.ObjectBucketClaimStatusPhaseFailed,
+	}
+	for i, phase := range phases {
+		obc := newTestClaim(fmt.Sprintf("%s-%d", testName, i))
+		obc.Labels = c.labels()
+		obc.Status.Phase = phase
+		if err := c.obcInformer.Informer().GetIndexer().Add(obc); err != nil {
+			t.Fatalf("error adding claim to the cache: %v", err)
+		}
+	}
+	// claims of other provisioners are not counted
+	other := newTestClaim(testName + "-other")
+	other.Status.Phase = v1alpha1.ObjectBucketClaimStatusPhaseBound
+	if err := c.obcInformer.Informer().GetIndexer().Add(other); err != nil {
+		t.Fatalf("error adding claim to the cache: %v", err)
+	}
+	c.queue.Add("unprocessed/claim")
+
+	c.reportShutdownSummary()
+
+	want := ShutdownSummary{Bound: 2, Pending: 1, Failed: 1, Queued: 1}
+	if got != want {
+		t.Errorf("wanted shutdown summary %+v, got %+v", want, got)
+	}
+}
+
+func TestReconcileOrphanedBuckets(t *testing.T) {
+	tests := []struct {
+		name        string
+		claimExists bool
+		provisioner string
+		obLabels    map[string]string
+		selector    string
+		wantCleanUp bool
+	}{
+		{
+			name:        "orphaned OB is cleaned up",
+			provisioner: provisionerName,
+			wantCleanUp: true,
+		},
+		{
+			name:        "OB of an existing claim is kept",
+			claimExists: true,
+			provisioner: provisionerName,
+		},
+		{
+			name:        "OB of another provisioner is kept",
+			provisioner: "other.io/provisioner",
+		},
+		{
+			name:        "OB matching the selector is cleaned up",
+			provisioner: provisionerName,
+			obLabels:    map[string]string{"env": "staging"},
+			selector:    "env=staging",
+			wantCleanUp: true,
+		},
+		{
+			name:        "OB not matching the selector is kept",
+			provisioner: provisionerName,
+			obLabels:    map[string]string{"env": "production"},
+			selector:    "env=staging",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			obName, err := objectBucketNameFromClaimKey(testKey(obc))
+			if err != nil {
+				t.Fatalf("error composing OB name: %v", err)
+			}
+			reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+			ob := newTestBucket()
+			ob.ObjectMeta = metav1.ObjectMeta{
+				Name:        obName,
+				UID:         "ob-uid",
+				Finalizers:  []string{finalizer},
+				Labels:      tt.obLabels,
+				Annotations: map[string]string{provisionerAnnotation: tt.provisioner},
+			}
+			ob.Spec.StorageClassName = className
+			ob.Spec.ReclaimPolicy = &reclaimPolicy
+			ob.Spec.Provisioning = v1alpha1.ObjectBucketProvisioningDynamic
+			ob.Spec.ClaimRef = &corev1.ObjectReference{Kind: v1alpha1.ObjectBucketClaimGVK().Kind, Namespace: obc.Namespace, Name: obc.Name}
+			libObjects := []runtime.Object{ob}
+			if tt.claimExists {
+				libObjects = append(libObjects, obc)
+			}
+			deleted := false
+			p := &fakeProvisioner{
+				deleteFunc: func(ob *v1alpha1.ObjectBucket) error {
+					deleted = true
+					return nil
+				},
+			}
+			options := []Option{WithOrphanReconcileOnStartup()}
+			if tt.selector != "" {
+				selector, err := labels.Parse(tt.selector)
+				if err != nil {
+					t.Fatalf("error parsing selector: %v", err)
+				}
+				options = append(options, WithOrphanSelector(selector))
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, libObjects, options...)
+			obIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if err = obIndexer.Add(ob); err != nil {
+				t.Fatalf("error adding OB to informer: %v", err)
+			}
+			c.obLister = listers.NewObjectBucketLister(obIndexer)
+
+			c.reconcileOrphanedBuckets()
+
+			if deleted != tt.wantCleanUp {
+				t.Errorf("wanted bucket deleted %t, got %t", tt.wantCleanUp, deleted)
+			}
+			_, err = c.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), obName, metav1.GetOptions{})
+			if gone := errors.IsNotFound(err); gone != tt.wantCleanUp {
+				t.Errorf("wanted OB deleted %t, got error %v", tt.wantCleanUp, err)
+			}
+		})
+	}
+}
+
+func TestLifecycleHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		initErr     error
+		shutdownErr error
+		wantErr     bool
+		wantCalls   []string
+	}{
+		{
+			name:      "provisioner is shut down after its last call",
+			wantCalls: []string{"init", "provisioned", "shutdown"},
+		},
+		{
+			name:        "shutdown error is not returned",
+			shutdownErr: fmt.Errorf("connections left open"),
+			wantCalls:   []string{"init", "provisioned", "shutdown"},
+		},
+		{
+			name:      "failed initialization stops Start",
+			initErr:   fmt.Errorf("backend unreachable"),
+			wantErr:   true,
+			wantCalls: []string{"init"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				lock  sync.Mutex
+				calls []string
+			)
+			called := func(call string) {
+				lock.Lock()
+				defer lock.Unlock()
+				calls = append(calls, call)
+			}
+			stopCh := make(chan struct{})
+			obc := newTestClaim(testName)
+			p := &fakeLifecycleHandler{
+				fakeProvisioner: fakeProvisioner{
+					provisionFunc: func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+						// the controller is stopped while the call is in flight
+						close(stopCh)
+						time.Sleep(50 * time.Millisecond)
+						called("provisioned")
+						return newTestBucket(), nil
+					},
+				},
+				initFunc: func(ctx context.Context) error {
+					called("init")
+					return tt.initErr
+				},
+				shutdownFunc: func(ctx context.Context) error {
+					called("shutdown")
+					return tt.shutdownErr
+				},
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+			c.obcHasSynced = func() bool { return true }
+			c.obHasSynced = func() bool { return true }
+			c.queue.Add(testKey(obc))
+
+			if err := c.Start(stopCh); (err != nil) != tt.wantErr {
+				t.Fatalf("Start() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("wanted calls %v, got %v", tt.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestStopLeavesQueuedClaims(t *testing.T) {
+	stopCh := make(chan struct{})
+	first, second := newTestClaim("first"), newTestClaim("second")
+	var provisioned []string
+	p := &fakeProvisioner{
+		provisionFunc: func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+			// the controller is stopped while the first claim is in flight
+			if len(provisioned) == 0 {
+				close(stopCh)
+			}
+			provisioned = append(provisioned, options.ObjectBucketClaim.Name)
+			return newTestBucket(), nil
+		},
+	}
+	c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{first, second})
+	c.obcHasSynced = func() bool { return true }
+	c.obHasSynced = func() bool { return true }
+	c.queue.Add(testKey(first))
+	c.queue.Add(testKey(second))
+
+	if err := c.Start(stopCh); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if want := []string{"first"}; !reflect.DeepEqual(provisioned, want) {
+		t.Errorf("wanted claims %v provisioned, got %v", want, provisioned)
+	}
+	waitForClaimPhase(t, c, first, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+}
+
+func TestControllerVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		required string
+		wantErr  bool
+	}{
+		{
+			name:     "same version",
+			required: api.ControllerVersion,
+		},
+		{
+			name:     "older version",
+			required: "v0.9.0",
+		},
+		{
+			name:     "newer version",
+			required: "999.0.0",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid version",
+			required: "latest",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestController(&fakeVersionRequirer{required: tt.required}, nil, nil)
+			if err := c.checkControllerVersion(); (err != nil) != tt.wantErr {
+				t.Errorf("checkControllerVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// an incompatible provisioner stops Start before any claim is processed
+	c := newTestController(&fakeVersionRequirer{required: "999.0.0"}, nil, nil)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if err := c.Start(stopCh); err == nil || !strings.Contains(err.Error(), "999.0.0") {
+		t.Errorf("wanted Start to fail on the required version, got %v", err)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	tests := []struct {
+		name        string
+		preflight   func(ctx context.Context) error
+		timeout     time.Duration
+		wantErr     bool
+		wantSuccess float64
+	}{
+		{
+			name:        "preflight succeeds",
+			preflight:   func(ctx context.Context) error { return nil },
+			wantSuccess: 1,
+		},
+		{
+			name:      "backend unreachable",
+			preflight: func(ctx context.Context) error { return fmt.Errorf("connection refused") },
+			wantErr:   true,
+		},
+		{
+			name: "preflight times out",
+			preflight: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			timeout: 100 * time.Millisecond,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &fakePreflighter{preflightFunc: tt.preflight}
+			c := newTestController(p, nil, nil, WithPreflight(tt.timeout))
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+
+			if err := c.runPreflight(stopCh); (err != nil) != tt.wantErr {
+				t.Errorf("runPreflight() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := testutil.ToFloat64(preflightSucceeded); got != tt.wantSuccess {
+				t.Errorf("wanted preflight metric %v, got %v", tt.wantSuccess, got)
+			}
+		})
+	}
+
+	// a failed preflight stops Start before any claim is processed
+	p := &fakePreflighter{preflightFunc: func(ctx context.Context) error { return fmt.Errorf("invalid credentials") }}
+	c := newTestController(p, nil, nil, WithPreflight(time.Second))
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if err := c.Start(stopCh); err == nil || !strings.Contains(err.Error(), "invalid credentials") {
+		t.Errorf("wanted Start to fail with the preflight error, got %v", err)
+	}
+}
+
+func TestSetLabelsRelabelsChildren(t *testing.T) {
+	obc := newTestClaim(testName)
+	c := newTestController(&fakeProvisioner{bucket: newTestBucket()}, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+	if _, err := c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+	bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	if err = c.obcInformer.Informer().GetIndexer().Add(bound); err != nil {
+		t.Fatalf("error adding claim to lister: %v", err)
+	}
+
+	const labelKey = "team"
+	c.SetLabels(map[string]string{labelKey: "storage"})
+	if n := c.queue.Len(); n != 1 {
+		t.Fatalf("wanted the managed claim queued, got %d queued", n)
+	}
+	if !c.processNextItemInQueue(0) {
+		t.Fatal("queue unexpectedly shut down")
+	}
+
+	ob, cm, secret, errs := c.getExistingResourcesFromKey(testKey(obc))
+	if len(errs) > 0 {
+		t.Fatalf("error getting resources: %v", errs)
+	}
+	for _, obj := range []metav1.Object{ob, cm, secret} {
+		if got := obj.GetLabels()[labelKey]; got != "storage" {
+			t.Errorf("wanted %s labeled with %s=storage, got %v", obj.GetName(), labelKey, obj.GetLabels())
+		}
+	}
+
+	// setting labels which are already set queues nothing
+	c.SetLabels(map[string]string{labelKey: "storage"})
+	if n := c.queue.Len(); n != 0 {
+		t.Errorf("wanted no claims queued for unchanged labels, got %d queued", n)
+	}
+}
+
+func TestBackfillLabels(t *testing.T) {
+	obc := newTestClaim(testName)
+	p := &fakeProvisioner{bucket: newTestBucket()}
+	c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+	if _, err := c.syncHandler(testKey(obc)); err != nil {
+		t.Fatalf("error syncing claim: %v", err)
+	}
+	waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+	// the resources predate a label added by the provisioner, and the OBC has lost a label
+	const labelKey = "team"
+	c.SetLabels(map[string]string{labelKey: "storage"})
+	bound, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	bound.Labels = nil
+	if _, err = updateClaim(c.libClientset, bound); err != nil {
+		t.Fatalf("error updating claim: %v", err)
+	}
+	// provisioning again fails, so only the backfill labels the resources
+	p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+		return nil, fmt.Errorf("backend unavailable")
+	}
+	if _, err = c.syncHandler(testKey(obc)); err == nil {
+		t.Fatal("wanted error provisioning claim again")
+	}
+
+	ob, cm, secret, errs := c.getExistingResourcesFromKey(testKey(obc))
+	if len(errs) > 0 {
+		t.Fatalf("error getting resources: %v", errs)
+	}
+	got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	for _, obj := range []metav1.Object{got, ob, cm, secret} {
+		labels := obj.GetLabels()
+		if labels[provisionerLabelKey] != labelValue(provisionerName) || labels[labelKey] != "storage" {
+			t.Errorf("wanted %s labeled with %s and %s, got %v", obj.GetName(), provisionerLabelKey, labelKey, labels)
+		}
+	}
+}
+
+func TestBackfillLabelsFromCache(t *testing.T) {
+	// the ownership check before provisioning reads the configmap and secret in either case
+	tests := []struct {
+		name     string
+		stale    bool
+		uncached bool
+		wantGets int
+	}{
+		{
+			name:     "labels already backfilled",
+			wantGets: 2,
+		},
+		{
+			name:     "configmap lacks a label",
+			stale:    true,
+			wantGets: 4,
+		},
+		{
+			// the child informer only watches labeled resources, so the configmap is read from the API
+			// server by the staleness check and by the configmap healing too
+			name:     "configmap lacks a label and is not cached",
+			stale:    true,
+			uncached: true,
+			wantGets: 6,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			p := &fakeProvisioner{bucket: newTestBucket()}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc}, WithReconcileChildren())
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+
+			// the informers hold the resources as provisioned
+			ob, cm, secret, errs := c.getExistingResourcesFromKey(testKey(obc))
+			if len(errs) > 0 {
+				t.Fatalf("error getting resources: %v", errs)
+			}
+			if tt.stale {
+				cm.Labels = nil
+				var err error
+				if cm, err = c.clientset.CoreV1().ConfigMaps(cm.Namespace).Update(context.TODO(), cm, metav1.UpdateOptions{}); err != nil {
+					t.Fatalf("error updating configmap: %v", err)
+				}
+			}
+			if !tt.uncached {
+				if err := c.childInformerFactory.Core().V1().ConfigMaps().Informer().GetIndexer().Add(cm); err != nil {
+					t.Fatalf("error adding configmap to informer: %v", err)
+				}
+			}
+			if err := c.childInformerFactory.Core().V1().Secrets().Informer().GetIndexer().Add(secret); err != nil {
+				t.Fatalf("error adding secret to informer: %v", err)
+			}
+			obIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if err := obIndexer.Add(ob); err != nil {
+				t.Fatalf("error adding OB to informer: %v", err)
+			}
+			c.obLister = listers.NewObjectBucketLister(obIndexer)
+
+			gets := 0
+			c.clientset.(*fake.Clientset).PrependReactor("get", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if r := action.GetResource().Resource; r == "configmaps" || r == "secrets" {
+					gets++
+				}
+				return false, nil, nil
+			})
+			// provisioning again fails, so only the backfill reads the resources
+			p.provisionFunc = func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+				return nil, fmt.Errorf("backend unavailable")
+			}
+			if _, err := c.syncHandler(testKey(obc)); err == nil {
+				t.Fatal("wanted error provisioning claim again")
+			}
+			if gets != tt.wantGets {
+				t.Errorf("wanted %d configmap and secret reads, got %d", tt.wantGets, gets)
+			}
+			got, err := c.clientset.CoreV1().ConfigMaps(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting configmap: %v", err)
+			}
+			if got.Labels[provisionerLabelKey] != labelValue(provisionerName) {
+				t.Errorf("wanted configmap labeled with %s, got %v", provisionerLabelKey, got.Labels)
+			}
+		})
+	}
+}
+
+func TestHealConfigMap(t *testing.T) {
+	tests := []struct {
+		name       string
+		removeKeys []string
+		deleteCM   bool
+		cached     bool
+		unlabeled  bool
+		wantHealed bool
+	}{
+		{
+			name:       "missing host",
+			removeKeys: []string{bucketHost},
+			wantHealed: true,
+		},
+		{
+			name:       "missing configmap",
+			deleteCM:   true,
+			wantHealed: true,
+		},
+		{
+			name:       "missing host read from the child informer",
+			removeKeys: []string{bucketHost},
+			cached:     true,
+			wantHealed: true,
+		},
+		{
+			name: "complete configmap",
+		},
+		{
+			name:   "complete configmap read from the child informer",
+			cached: true,
+		},
+		{
+			// the child informer only watches labeled resources
+			name:      "complete unlabeled configmap missing from the child informer",
+			cached:    true,
+			unlabeled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := newTestClaim(testName)
+			p := &fakeProvisioner{bucket: newTestBucket()}
+			var options []Option
+			if tt.cached {
+				options = append(options, WithReconcileChildren())
+			}
+			c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc}, options...)
+
+			if _, err := c.syncHandler(testKey(obc)); err != nil {
+				t.Fatalf("error syncing claim: %v", err)
+			}
+			waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+			cm, err := c.clientset.CoreV1().ConfigMaps(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting configmap: %v", err)
+			}
+			want := cm.Data
+			if tt.deleteCM {
+				if err = c.clientset.CoreV1().ConfigMaps(obc.Namespace).Delete(context.TODO(), obc.Name, metav1.DeleteOptions{}); err != nil {
+					t.Fatalf("error deleting configmap: %v", err)
+				}
+			} else {
+				corrupted := cm.DeepCopy()
+				corrupted.Data = map[string]string{}
+				for k, v := range want {
+					corrupted.Data[k] = v
+				}
+				for _, k := range tt.removeKeys {
+					delete(corrupted.Data, k)
+				}
+				if tt.unlabeled {
+					corrupted.Labels = nil
+				}
+				if corrupted, err = c.clientset.CoreV1().ConfigMaps(obc.Namespace).Update(context.TODO(), corrupted, metav1.UpdateOptions{}); err != nil {
+					t.Fatalf("error updating configmap: %v", err)
+				}
+				if tt.cached && !tt.unlabeled {
+					if err = c.childInformerFactory.Core().V1().ConfigMaps().Informer().GetIndexer().Add(corrupted); err != nil {
+						t.Fatalf("error adding configmap to informer: %v", err)
+					}
+				}
+			}
+
+			// the provisioner fails so that only healing can restore the configmap
+			p.provisionFunc = func(*api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+				return nil, fmt.Errorf("backend unavailable")
+			}
+			recorder := record.NewFakeRecorder(10)
+			c.recorder = recorder
+			if _, err = c.syncHandler(testKey(obc)); err == nil {
+				t.Fatalf("wanted provisioning error, got none")
+			}
+
+			got, err := c.clientset.CoreV1().ConfigMaps(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("error getting configmap: %v", err)
+			}
+			if !reflect.DeepEqual(got.Data, want) {
+				t.Errorf("wanted configmap data %v, got %v", want, got.Data)
+			}
+			healed := false
+			close(recorder.Events)
+			for event := range recorder.Events {
+				if strings.HasPrefix(event, corev1.EventTypeNormal+" "+reasonConfigMapHealed+" ") {
+					healed = true
+				}
+			}
+			if healed != tt.wantHealed {
+				t.Errorf("wanted healed event %v, got %v", tt.wantHealed, healed)
+			}
+		})
+	}
+}
+
+func TestClientBucketNameResync(t *testing.T) {
+	obc := newTestClaim(testName)
+	p := &fakeProvisioner{
+		provisionFunc: func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error) {
+			ob := newTestBucket()
+			ob.Spec.Endpoint.BucketName = options.BucketName
+			ob.Spec.Endpoint.ClientBucketName = "client-" + options.BucketName
+			return ob, nil
+		},
+	}
+	c := newTestController(p, []runtime.Object{newTestStorageClass(nil)}, []runtime.Object{obc})
+
+	// the second sync re-syncs the bound claim, whose bucket name must still match its OB
+	for i := 0; i < 2; i++ {
+		outcome, err := c.syncHandler(testKey(obc))
+		if err != nil {
+			t.Fatalf("error syncing claim (sync %d): %v", i+1, err)
+		}
+		if outcome != outcomeProvisioned {
+			t.Errorf("wanted outcome %q, got %q (sync %d)", outcomeProvisioned, outcome, i+1)
+		}
+		waitForClaimPhase(t, c, obc, v1alpha1.ObjectBucketClaimStatusPhaseBound)
+	}
+
+	got, err := c.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting claim: %v", err)
+	}
+	cm, err := c.clientset.CoreV1().ConfigMaps(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error getting configmap: %v", err)
+	}
+	if want := "client-" + got.Spec.BucketName; cm.Data[bucketName] != want {
+		t.Errorf("wanted %s %q, got %q", bucketName, want, cm.Data[bucketName])
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	libscheme "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned/scheme"
+)
+
+// Reasons of the events recorded on OBCs by the controller
+const (
+	reasonProvisioningTimedOut       = "ProvisioningTimedOut"
+	reasonDeprovisioningTimedOut     = "DeprovisioningTimedOut"
+	reasonInvalidParameter           = "InvalidParameter"
+	reasonUpdateDegraded             = "UpdateDegraded"
+	reasonPreconditionFailed         = "PreconditionFailed"
+	reasonBucketLeaked               = "BucketLeaked"
+	reasonStuckPending               = "StuckPending"
+	reasonInvalidAdditionalConfig    = "InvalidAdditionalConfig"
+	reasonStorageClassChangeRejected = "StorageClassChangeRejected"
+	reasonInsecureEndpoint           = "InsecureEndpoint"
+	reasonResourceConflict           = "ResourceConflict"
+	reasonObjectBucketMissing        = "ObjectBucketMissing"
+	reasonInvalidName                = "InvalidName"
+	reasonUnsupportedProvisioner     = "UnsupportedProvisioner"
+	reasonBucketNameMissing          = "BucketNameMissing"
+	reasonInvalidBucketName          = "InvalidBucketName"
+	reasonAdditionalConfigUpdated    = "AdditionalConfigUpdated"
+	reasonProvisionerError           = "ProvisionerError"
+	reasonFinalizerMissing           = "FinalizerMissing"
+	reasonReleased                   = "Released"
+	reasonCredentialsDrifted         = "CredentialsDrifted"
+	reasonBrownfieldUpdateRejected   = "BrownfieldUpdateRejected"
+	reasonBucketNameCollision        = "BucketNameCollision"
+	reasonBucketMissing              = "BucketMissing"
+	reasonClassQuotaExceeded         = "ClassQuotaExceeded"
+	reasonReclaimPolicyChanged       = "ReclaimPolicyChanged"
+	reasonAdoptionRejected           = "AdoptionRejected"
+	reasonChildValidationFailed      = "ChildValidationFailed"
+	reasonBucketNameRejected         = "BucketNameRejected"
+	reasonInvalidCredentials         = "InvalidCredentials"
+	reasonConfigMapHealed            = "ConfigMapHealed"
+)
+
+// Reasons recorded in the status of pending OBCs, describing what the controller is waiting on
+const (
+	reasonWaitingForStorageClass = "WaitingForStorageClass"
+	reasonWaitingForBucketClass  = "WaitingForBucketClass"
+	reasonValidatingParameters   = "ValidatingParameters"
+	reasonProvisioningBucket     = "ProvisioningBucket"
+	reasonGrantingAccess         = "GrantingAccess"
+	reasonWaitingForCapacity     = "WaitingForCapacity"
+)
+
+// messageValidatingParameters is recorded with reasonValidatingParameters
+const messageValidatingParameters = "validating parameters"
+
+// newEventRecorder returns a recorder which writes events to the API server on behalf of the
+// named provisioner.
+func newEventRecorder(clientset kubernetes.Interface, provisionerName string) record.EventRecorder {
+	// the scheme must know both core and objectbucket.io types so that events can reference
+	// any resource managed by the controller
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(libscheme.AddToScheme(scheme))
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartStructuredLogging(0)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme, corev1.EventSource{Component: provisionerName})
+}
+
+// discardRecorder is an event recorder which drops all events, used when events are disabled.
+type discardRecorder struct{}
+
+var _ record.EventRecorder = discardRecorder{}
+
+func (discardRecorder) Event(object runtime.Object, eventtype, reason, message string) {}
+
+func (discardRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+}
+
+func (discardRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+}
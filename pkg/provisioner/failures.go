@@ -0,0 +1,145 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	bucketerrors "github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api/errors"
+)
+
+// terminalError is returned by the claim handlers when reconciling a claim has failed in a way
+// that retrying cannot resolve, eg. an invalid StorageClass parameter. Rather than requeuing the
+// claim, it is moved to the Failed phase and a warning event with the error's reason is recorded.
+type terminalError struct {
+	reason string
+	err    error
+}
+
+func (e *terminalError) Error() string {
+	return e.err.Error()
+}
+
+func newTerminalError(reason string, err error) error {
+	return &terminalError{reason: reason, err: err}
+}
+
+func asTerminalError(err error) (*terminalError, bool) {
+	terr, ok := err.(*terminalError)
+	return terr, ok
+}
+
+// failClaim moves the claim to the Failed phase and records the failure as a warning event and in
+// the claim's status. An error is only returned if the claim's status could not be updated, in
+// which case the claim should be requeued so that the failure is eventually recorded.
+func (c *obcController) failClaim(obc *v1alpha1.ObjectBucketClaim, failure *terminalError) error {
+	log.Error(failure.err, "claim failed", "reason", failure.reason)
+	c.recorder.Event(obc, corev1.EventTypeWarning, failure.reason, failure.Error())
+
+	// the handler which failed may have updated the claim, so fetch the latest version before
+	// updating its phase
+	obc, err := claimForKey(namespacedKey(obc.Namespace, obc.Name), c.libClientset)
+	if err != nil {
+		return fmt.Errorf("error getting OBC to record failure: %v", err)
+	}
+	obc.Status.Reason, obc.Status.Message = failure.reason, failure.Error()
+	_, err = c.updateClaimPhase(obc, v1alpha1.ObjectBucketClaimStatusPhaseFailed)
+	return err
+}
+
+const (
+	// forbiddenBaseDelay and forbiddenMaxDelay bound the backoff of claims whose resources may not
+	// be created, which is longer than that of other failures since it is resolved by an
+	// administrator rather than by retrying
+	forbiddenBaseDelay = 30 * time.Second
+	forbiddenMaxDelay  = 10 * time.Minute
+)
+
+func newForbiddenRateLimiter() workqueue.RateLimiter {
+	return workqueue.NewItemExponentialFailureRateLimiter(forbiddenBaseDelay, forbiddenMaxDelay)
+}
+
+// forbiddenError is returned by the claim handlers when the API server forbids creating a
+// resource of the claim, eg. because a resource quota is exceeded or an admission webhook rejected
+// it. The claim is requeued with the forbidden backoff rather than the rate limiter's.
+type forbiddenError struct {
+	resource string
+	err      error
+}
+
+func (e *forbiddenError) Error() string {
+	return fmt.Sprintf("creating %s for OBC is forbidden: %v", e.resource, e.err)
+}
+
+func asForbiddenError(err error) (*forbiddenError, bool) {
+	ferr, ok := err.(*forbiddenError)
+	return ferr, ok
+}
+
+// resourceCreationForbidden records on the claim's status that creating the resource is forbidden
+// and returns the forbiddenError for the claim to be requeued. A failure to update the status is
+// logged, since the claim is retried anyway.
+func (c *obcController) resourceCreationForbidden(obc *v1alpha1.ObjectBucketClaim, resource string, err error) error {
+	ferr := &forbiddenError{resource: resource, err: err}
+	log.Error(err, "resource creation forbidden", "resource", resource)
+	setResourceCreationForbiddenCondition(obc, ferr.Error(), c.clock.Now())
+	if _, uerr := c.updateClaimPhase(obc, obc.Status.Phase); uerr != nil {
+		log.Error(uerr, "failed to set condition", "type", v1alpha1.ObjectBucketClaimConditionResourceCreationForbidden)
+	}
+	return ferr
+}
+
+// RequeueAction is how a claim whose reconcile returned an error is requeued.
+type RequeueAction string
+
+const (
+	// RequeueActionRateLimited retries the claim after the rate limiter's backoff, which grows with
+	// each consecutive failure of the claim
+	RequeueActionRateLimited RequeueAction = "RateLimited"
+	// RequeueActionAfter retries the claim after the decision's delay, and resets its backoff
+	RequeueActionAfter RequeueAction = "After"
+	// RequeueActionForget does not retry the claim until it is requeued by an event, eg. an edit,
+	// as retrying cannot resolve the error
+	RequeueActionForget RequeueAction = "Forget"
+)
+
+// RequeueDecision is the decision of an ErrorClassifier.
+type RequeueDecision struct {
+	Action RequeueAction
+	// After is the delay of RequeueActionAfter
+	After time.Duration
+}
+
+// ErrorClassifier decides how a claim whose reconcile returned the given error is requeued. It is
+// not called for errors which fail the claim, nor for the forbidden creation of its resources,
+// which is retried with its own backoff.
+type ErrorClassifier func(err error) RequeueDecision
+
+// DefaultErrorClassifier retries a claim after the delay of a RequeueAfterErr of the api/errors
+// package, ie. when the provisioner knows when the claim can make progress, and otherwise after
+// the rate limiter's backoff.
+func DefaultErrorClassifier(err error) RequeueDecision {
+	if after, ok := bucketerrors.RequeueAfter(err); ok {
+		return RequeueDecision{Action: RequeueActionAfter, After: after}
+	}
+	return RequeueDecision{Action: RequeueActionRateLimited}
+}
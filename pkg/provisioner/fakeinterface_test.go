@@ -17,14 +17,27 @@ limitations under the License.
 package provisioner
 
 import (
+	"context"
 	"fmt"
 	// "sigs.k8s.io/Controller-runtime/pkg/client/fake"
 
+	corev1 "k8s.io/api/core/v1"
+
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
 )
 
-type fakeProvisioner struct{}
+type fakeProvisioner struct {
+	// bucket, when non-nil, is copied and returned by Provision and Grant in place of an empty
+	// ObjectBucket
+	bucket *v1alpha1.ObjectBucket
+	// provisionFunc, when non-nil, is called by Provision and Grant in place of the default behavior
+	provisionFunc func(options *api.BucketOptions) (*v1alpha1.ObjectBucket, error)
+	// deleteFunc, when non-nil, is called by Delete and Revoke
+	deleteFunc func(ob *v1alpha1.ObjectBucket) error
+	// revokeFunc, when non-nil, is called by Revoke in place of deleteFunc
+	revokeFunc func(ob *v1alpha1.ObjectBucket) error
+}
 
 var _ api.Provisioner = &fakeProvisioner{}
 
@@ -40,7 +53,10 @@ func (p *fakeProvisioner) Provision(options *api.BucketOptions) (*v1alpha1.Objec
 	if options == nil || options.ObjectBucketClaim == nil {
 		return nil, fmt.Errorf("got nil ptr")
 	}
-	return &v1alpha1.ObjectBucket{}, nil
+	if p.provisionFunc != nil {
+		return p.provisionFunc(options)
+	}
+	return p.newBucket(options), nil
 }
 
 // Grant provides a simple method for testing purposes
@@ -48,13 +64,29 @@ func (p *fakeProvisioner) Grant(options *api.BucketOptions) (*v1alpha1.ObjectBuc
 	if options == nil || options.ObjectBucketClaim == nil {
 		return nil, fmt.Errorf("got nil ptr")
 	}
-	return &v1alpha1.ObjectBucket{}, nil
+	if p.provisionFunc != nil {
+		return p.provisionFunc(options)
+	}
+	return p.newBucket(options), nil
+}
+
+func (p *fakeProvisioner) newBucket(options *api.BucketOptions) *v1alpha1.ObjectBucket {
+	if p.bucket == nil {
+		return &v1alpha1.ObjectBucket{}
+	}
+	ob := p.bucket.DeepCopy()
+	if ob.Spec.Endpoint != nil {
+		ob.Spec.Endpoint.BucketName = options.BucketName
+	}
+	return ob
 }
 
 // Delete provides a simple method for testing purposes
 func (p *fakeProvisioner) Delete(ob *v1alpha1.ObjectBucket) (err error) {
 	if ob == nil {
 		err = fmt.Errorf("got nil object bucket pointer")
+	} else if p.deleteFunc != nil {
+		err = p.deleteFunc(ob)
 	}
 	return err
 }
@@ -63,6 +95,194 @@ func (p *fakeProvisioner) Delete(ob *v1alpha1.ObjectBucket) (err error) {
 func (p *fakeProvisioner) Revoke(ob *v1alpha1.ObjectBucket) (err error) {
 	if ob == nil {
 		err = fmt.Errorf("got nil object bucket pointer")
+	} else if p.revokeFunc != nil {
+		err = p.revokeFunc(ob)
+	} else if p.deleteFunc != nil {
+		err = p.deleteFunc(ob)
 	}
 	return err
 }
+
+// fakeUpdater is a fakeProvisioner which supports updates
+type fakeUpdater struct {
+	fakeProvisioner
+	// updateFunc, when non-nil, is called by Update
+	updateFunc func(ob *v1alpha1.ObjectBucket) error
+}
+
+var _ api.Updater = &fakeUpdater{}
+
+// Update provides a simple method for testing purposes
+func (p *fakeUpdater) Update(ob *v1alpha1.ObjectBucket) error {
+	if ob == nil {
+		return fmt.Errorf("got nil object bucket pointer")
+	}
+	if p.updateFunc != nil {
+		return p.updateFunc(ob)
+	}
+	return nil
+}
+
+// fakePreProvisioner is a fakeProvisioner which validates claims before provisioning
+type fakePreProvisioner struct {
+	fakeProvisioner
+	preProvisionFunc func(obc *v1alpha1.ObjectBucketClaim, namespace *corev1.Namespace) error
+}
+
+var _ api.PreProvisioner = &fakePreProvisioner{}
+
+// PreProvision provides a simple method for testing purposes
+func (p *fakePreProvisioner) PreProvision(obc *v1alpha1.ObjectBucketClaim, namespace *corev1.Namespace) error {
+	if obc == nil || namespace == nil {
+		return fmt.Errorf("got nil ptr")
+	}
+	return p.preProvisionFunc(obc, namespace)
+}
+
+// fakeValidator is a fakeProvisioner which declares the additionalConfig it supports
+type fakeValidator struct {
+	fakeProvisioner
+	schema api.AdditionalConfigSchema
+}
+
+var _ api.AdditionalConfigValidator = &fakeValidator{}
+
+// AdditionalConfigSchema provides a simple method for testing purposes
+func (p *fakeValidator) AdditionalConfigSchema() api.AdditionalConfigSchema {
+	return p.schema
+}
+
+// fakePreflighter is a fakeProvisioner which checks its wiring at startup
+type fakePreflighter struct {
+	fakeProvisioner
+	preflightFunc func(ctx context.Context) error
+}
+
+var _ api.Preflighter = &fakePreflighter{}
+
+// Preflight provides a simple method for testing purposes
+func (p *fakePreflighter) Preflight(ctx context.Context) error {
+	return p.preflightFunc(ctx)
+}
+
+// fakeLifecycleHandler is a fakeProvisioner which holds resources for the controller's lifetime
+type fakeLifecycleHandler struct {
+	fakeProvisioner
+	initFunc     func(ctx context.Context) error
+	shutdownFunc func(ctx context.Context) error
+}
+
+var _ api.LifecycleHandler = &fakeLifecycleHandler{}
+
+// Init provides a simple method for testing purposes
+func (p *fakeLifecycleHandler) Init(ctx context.Context) error {
+	return p.initFunc(ctx)
+}
+
+// Shutdown provides a simple method for testing purposes
+func (p *fakeLifecycleHandler) Shutdown(ctx context.Context) error {
+	return p.shutdownFunc(ctx)
+}
+
+// fakeVersionRequirer is a fakeProvisioner which requires a minimum controller version
+type fakeVersionRequirer struct {
+	fakeProvisioner
+	required string
+}
+
+var _ api.VersionRequirer = &fakeVersionRequirer{}
+
+// RequiredControllerVersion provides a simple method for testing purposes
+func (p *fakeVersionRequirer) RequiredControllerVersion() string {
+	return p.required
+}
+
+// fakeBucketNameValidator is a fakeProvisioner which enforces a naming policy on buckets
+type fakeBucketNameValidator struct {
+	fakeProvisioner
+	validateFunc func(obc *v1alpha1.ObjectBucketClaim, name string) error
+}
+
+var _ api.BucketNameValidator = &fakeBucketNameValidator{}
+
+// ValidateBucketName provides a simple method for testing purposes
+func (p *fakeBucketNameValidator) ValidateBucketName(obc *v1alpha1.ObjectBucketClaim, name string) error {
+	return p.validateFunc(obc, name)
+}
+
+// fakeCredentialsValidator is a fakeProvisioner which checks the credentials of buckets
+type fakeCredentialsValidator struct {
+	fakeProvisioner
+	validateFunc func(ob *v1alpha1.ObjectBucket) error
+}
+
+var _ api.CredentialsValidator = &fakeCredentialsValidator{}
+
+// ValidateCredentials provides a simple method for testing purposes
+func (p *fakeCredentialsValidator) ValidateCredentials(ob *v1alpha1.ObjectBucket) error {
+	return p.validateFunc(ob)
+}
+
+// fakeLifecycleApplier is a fakeUpdater which applies lifecycle rules
+type fakeLifecycleApplier struct {
+	fakeUpdater
+	applyFunc func(ob *v1alpha1.ObjectBucket, rules []api.LifecycleRule) error
+}
+
+var _ api.LifecycleApplier = &fakeLifecycleApplier{}
+
+// ApplyLifecycle provides a simple method for testing purposes
+func (p *fakeLifecycleApplier) ApplyLifecycle(ob *v1alpha1.ObjectBucket, rules []api.LifecycleRule) error {
+	if ob == nil {
+		return fmt.Errorf("got nil object bucket pointer")
+	}
+	return p.applyFunc(ob, rules)
+}
+
+// fakeCapacityChecker is a fakeProvisioner whose object store may be at capacity
+type fakeCapacityChecker struct {
+	fakeProvisioner
+	hasCapacity bool
+}
+
+var _ api.CapacityChecker = &fakeCapacityChecker{}
+
+// HasCapacity provides a simple method for testing purposes
+func (p *fakeCapacityChecker) HasCapacity(options *api.BucketOptions) (bool, error) {
+	if options == nil {
+		return false, fmt.Errorf("got nil options")
+	}
+	return p.hasCapacity, nil
+}
+
+// fakeBucketChecker is a fakeProvisioner whose buckets may be missing
+type fakeBucketChecker struct {
+	fakeProvisioner
+	exists bool
+}
+
+var _ api.BucketChecker = &fakeBucketChecker{}
+
+// BucketExists provides a simple method for testing purposes
+func (p *fakeBucketChecker) BucketExists(ob *v1alpha1.ObjectBucket) (bool, error) {
+	if ob == nil {
+		return false, fmt.Errorf("got nil object bucket pointer")
+	}
+	return p.exists, nil
+}
+
+// fakeReleaseHandler is a fakeProvisioner which handles the release of buckets
+type fakeReleaseHandler struct {
+	fakeProvisioner
+	onReleasedFunc func(ob *v1alpha1.ObjectBucket) error
+}
+
+var _ api.ReleaseHandler = &fakeReleaseHandler{}
+
+// OnReleased provides a simple method for testing purposes
+func (p *fakeReleaseHandler) OnReleased(ob *v1alpha1.ObjectBucket) error {
+	if ob == nil {
+		return fmt.Errorf("got nil object bucket pointer")
+	}
+	return p.onReleasedFunc(ob)
+}
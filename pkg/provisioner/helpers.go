@@ -18,13 +18,24 @@ package provisioner
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
@@ -32,6 +43,7 @@ import (
 
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned"
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
 )
 
 func makeObjectReference(claim *v1alpha1.ObjectBucketClaim) *corev1.ObjectReference {
@@ -72,6 +84,15 @@ func objectIsOwnedByClaim(obc *v1alpha1.ObjectBucketClaim, ownerRefs []metav1.Ow
 	return false
 }
 
+// childIsOwnedByClaim returns true if the generated configmap or secret is owned by the claim: by an
+// owner reference, or, in another namespace than the claim, by the claim annotation.
+func childIsOwnedByClaim(obc *v1alpha1.ObjectBucketClaim, child metav1.Object) bool {
+	if child.GetNamespace() != obc.Namespace {
+		return child.GetAnnotations()[claimAnnotation] == namespacedKey(obc.Namespace, obc.Name)
+	}
+	return objectIsOwnedByClaim(obc, child.GetOwnerReferences())
+}
+
 func bucketIsOwnedByClaim(obc *v1alpha1.ObjectBucketClaim, ob *v1alpha1.ObjectBucket) bool {
 	ref := ob.Spec.ClaimRef
 	emptyRef := corev1.ObjectReference{}
@@ -95,6 +116,15 @@ func shouldProvision(obc *v1alpha1.ObjectBucketClaim) bool {
 	return true
 }
 
+// namespacedKey returns the canonical namespace/name key of an object, as produced by
+// cache.MetaNamespaceKeyFunc.
+func namespacedKey(namespace, name string) string {
+	if len(namespace) == 0 {
+		return name
+	}
+	return namespace + "/" + name
+}
+
 func claimRefForKey(key string, c versioned.Interface) (*corev1.ObjectReference, error) {
 	claim, err := claimForKey(key, c)
 	if err != nil {
@@ -118,9 +148,13 @@ func isNewBucketByStorageClass(sc *storagev1.StorageClass) bool {
 	return len(sc.Parameters[v1alpha1.StorageClassBucket]) == 0
 }
 
-// Return true if this OB is for a new bucket vs an existing bucket.
+// Return true if this OB is for a new bucket vs an existing bucket. The operation recorded in the OB
+// is used when present, as its storage class may have been edited since the bucket was provisioned.
 func isNewBucketByObjectBucket(c kubernetes.Interface, ob *v1alpha1.ObjectBucket) bool {
-	// temp: get bucket name from OB's storage class
+	if ob.Spec.Provisioning != "" {
+		return ob.Spec.Provisioning == v1alpha1.ObjectBucketProvisioningDynamic
+	}
+	// get bucket name from OB's storage class for OBs which predate the recorded operation
 	class, err := storageClassForObjectBucket(ob, c)
 	if err != nil || class == nil {
 		log.Error(err, "unable to get StorageClass of ObjectBucket")
@@ -129,6 +163,20 @@ func isNewBucketByObjectBucket(c kubernetes.Interface, ob *v1alpha1.ObjectBucket
 	return len(class.Parameters[v1alpha1.StorageClassBucket]) == 0
 }
 
+// effectiveReclaimPolicy returns the reclaim policy which handleDeleteClaim applies to the bucket of
+// the given OB: Delete for a new bucket whose OB has the Delete policy, otherwise Retain, as access
+// to the bucket is only revoked. Empty if the OB has no reclaim policy, in which case the bucket is
+// neither deleted nor revoked.
+func effectiveReclaimPolicy(c kubernetes.Interface, ob *v1alpha1.ObjectBucket) corev1.PersistentVolumeReclaimPolicy {
+	if ob.Spec.ReclaimPolicy == nil {
+		return ""
+	}
+	if *ob.Spec.ReclaimPolicy == corev1.PersistentVolumeReclaimDelete && isNewBucketByObjectBucket(c, ob) {
+		return corev1.PersistentVolumeReclaimDelete
+	}
+	return corev1.PersistentVolumeReclaimRetain
+}
+
 func composeConfigMapName(obc *v1alpha1.ObjectBucketClaim) string {
 	return obc.Name
 }
@@ -137,6 +185,38 @@ func composeSecretName(obc *v1alpha1.ObjectBucketClaim) string {
 	return obc.Name
 }
 
+// childKeyForClaimKey returns the namespace/name key of the configmap and secret generated for the
+// claim with the given key. They are named after the claim in its namespace, or, in another
+// resource namespace, after the claim's namespace and name like the OB, so that the resources of
+// claims of different namespaces do not collide.
+func childKeyForClaimKey(key, resourceNamespace string) (string, error) {
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return "", err
+	}
+	if resourceNamespace == "" || resourceNamespace == ns {
+		return key, nil
+	}
+	return namespacedKey(resourceNamespace, generatedName(ns, name)), nil
+}
+
+// placeChild moves a generated configmap or secret to the namespace and name of the child key. A
+// child in another namespace than the claim cannot be owned by it, so its owner reference is
+// replaced by the claim annotation, and it is deleted explicitly rather than garbage collected.
+func placeChild(child metav1.Object, obc *v1alpha1.ObjectBucketClaim, childKey string) error {
+	ns, name, err := cache.SplitMetaNamespaceKey(childKey)
+	if err != nil {
+		return err
+	}
+	child.SetNamespace(ns)
+	child.SetName(name)
+	if ns != obc.Namespace {
+		child.SetOwnerReferences(nil)
+		addAnnotations(child, map[string]string{claimAnnotation: namespacedKey(obc.Namespace, obc.Name)})
+	}
+	return nil
+}
+
 func configMapForClaimKey(key string, c kubernetes.Interface) (*corev1.ConfigMap, error) {
 	logD.Info("getting configMap for key", "key", key)
 	ns, name, err := cache.SplitMetaNamespaceKey(key)
@@ -176,20 +256,83 @@ func objectBucketNameFromClaimKey(key string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf(objectBucketNameFormat, ns, name), nil
+	return generatedName(ns, name), nil
+}
+
+// generatedName returns the name of the OB, or of a configmap or secret in another resource
+// namespace, generated for the claim with the given namespace and name. A name which would be too
+// long is truncated and suffixed with a hash of the full name, so that it stays unique and is the
+// same on every reconcile. Such names cannot be used without hashing, so hashing does not change
+// the name of any existing resource.
+func generatedName(namespace, name string) string {
+	full := fmt.Sprintf(objectBucketNameFormat, namespace, name)
+	if len(full) <= validation.DNS1123SubdomainMaxLength {
+		return full
+	}
+	sum := sha256.Sum256([]byte(full))
+	hash := hex.EncodeToString(sum[:])[:generatedNameHashLength]
+	// the truncated name must not end in a separator, which cannot precede the hash's separator
+	prefix := strings.TrimRight(full[:validation.DNS1123SubdomainMaxLength-len(hash)-1], "-.")
+	return prefix + "-" + hash
 }
 
+// validateGeneratedNames returns the reasons why the names of the configmap, secret and OB generated
+// for the claim would be rejected by the API server, if any. The OB's name is derived from both the
+// namespace and the name of the claim, so it may be too long even though the claim's name is valid,
+// unless long names are hashed.
+func validateGeneratedNames(obc *v1alpha1.ObjectBucketClaim, hashLongNames bool) []string {
+	obName := fmt.Sprintf(objectBucketNameFormat, obc.Namespace, obc.Name)
+	if hashLongNames {
+		obName = generatedName(obc.Namespace, obc.Name)
+	}
+	var errs []string
+	for _, name := range []struct{ kind, name string }{
+		{"configmap", composeConfigMapName(obc)},
+		{"secret", composeSecretName(obc)},
+		{"ObjectBucket", obName},
+	} {
+		for _, msg := range validation.IsDNS1123Subdomain(name.name) {
+			errs = append(errs, fmt.Sprintf("%s name %q: %s", name.kind, name.name, msg))
+		}
+	}
+	return errs
+}
+
+var errBucketNameMissing = errors.New("expected either bucketName or generateBucketName defined")
+
+// composeBucketName returns the claim's bucketName or, if it has none, a name generated from its
+// generateBucketName prefix. errBucketNameMissing is returned if the claim has neither, and an
+// error if the prefix cannot begin a valid bucket name.
 func composeBucketName(obc *v1alpha1.ObjectBucketClaim) (string, error) {
 	if obc.Spec.BucketName == "" && obc.Spec.GenerateBucketName == "" {
-		return "", fmt.Errorf("expected either bucketName or generateBucketName defined")
+		return "", errBucketNameMissing
 	}
 	bucketName := obc.Spec.BucketName
 	if bucketName == "" {
+		if err := validateBucketNamePrefix(obc.Spec.GenerateBucketName); err != nil {
+			return "", fmt.Errorf("invalid generateBucketName %q: %v", obc.Spec.GenerateBucketName, err)
+		}
 		bucketName = generateBucketName(obc.Spec.GenerateBucketName)
 	}
 	return bucketName, nil
 }
 
+var bucketNamePrefixChars = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*$`)
+
+// validateBucketNamePrefix checks that a generated bucket name starting with the given prefix
+// follows the S3 bucket naming rules: lowercase letters, digits, periods and hyphens, beginning
+// with a letter or digit, without adjacent periods. The generated suffix ends the name with a
+// digit or letter, and the prefix is truncated to fit the maximum length.
+func validateBucketNamePrefix(prefix string) error {
+	switch {
+	case !bucketNamePrefixChars.MatchString(prefix):
+		return fmt.Errorf("must begin with a lowercase letter or digit and contain only lowercase letters, digits, periods and hyphens")
+	case strings.Contains(prefix, ".."):
+		return fmt.Errorf("must not contain adjacent periods")
+	}
+	return nil
+}
+
 const (
 	maxNameLen     = 63
 	uuidSuffixLen  = 36
@@ -200,6 +343,8 @@ func generateBucketName(prefix string) string {
 	if len(prefix) >= maxBaseNameLen {
 		prefix = prefix[:maxBaseNameLen-1]
 	}
+	// a period may not precede the suffix's hyphen
+	prefix = strings.TrimRight(prefix, ".")
 	return fmt.Sprintf("%s-%s", prefix, uuid.New())
 }
 
@@ -208,7 +353,12 @@ func storageClassForClaim(c kubernetes.Interface, obc *v1alpha1.ObjectBucketClai
 		return nil, fmt.Errorf("got nil ObjectBucketClaim pointer")
 	}
 	if obc.Spec.StorageClassName == "" {
-		return nil, fmt.Errorf("no StorageClass defined for ObjectBucketClaim \"%s/%s\"", obc.Namespace, obc.Name)
+		class, err := defaultStorageClass(c)
+		if err != nil {
+			return nil, fmt.Errorf("no StorageClass defined for ObjectBucketClaim \"%s/%s\": %v", obc.Namespace, obc.Name, err)
+		}
+		log.Info("using default StorageClass", "name", class.Name)
+		return class, nil
 	}
 	logD.Info("getting ObjectBucketClaim's StorageClass")
 	class, err := c.StorageV1().StorageClasses().Get(context.TODO(), obc.Spec.StorageClassName, metav1.GetOptions{})
@@ -219,23 +369,76 @@ func storageClassForClaim(c kubernetes.Interface, obc *v1alpha1.ObjectBucketClai
 	return class, nil
 }
 
+// defaultStorageClass returns the storage class marked with the objectbucket.io default class
+// annotation or, if there is none, the one marked with the cluster's standard default class
+// annotation. It is an error if no class or more than one class is so marked.
+func defaultStorageClass(c kubernetes.Interface) (*storagev1.StorageClass, error) {
+	logD.Info("listing StorageClasses to find default")
+	classes, err := c.StorageV1().StorageClasses().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing StorageClasses: %v", err)
+	}
+	for _, annotation := range []string{v1alpha1.DefaultStorageClassAnnotation, isDefaultStorageClassAnnotation, betaIsDefaultStorageClassAnnotation} {
+		var defaults []*storagev1.StorageClass
+		for i := range classes.Items {
+			if classes.Items[i].Annotations[annotation] == "true" {
+				defaults = append(defaults, &classes.Items[i])
+			}
+		}
+		switch len(defaults) {
+		case 0:
+			continue
+		case 1:
+			return defaults[0], nil
+		default:
+			names := make([]string, 0, len(defaults))
+			for _, class := range defaults {
+				names = append(names, class.Name)
+			}
+			return nil, fmt.Errorf("%d StorageClasses are marked default with %q: %s", len(defaults), annotation, strings.Join(names, ", "))
+		}
+	}
+	return nil, fmt.Errorf("no default StorageClass")
+}
+
+// claimStorageClassName returns the name of the storage class of the OB's claim, which differs
+// from the OB's storage class if the provisioner set it.
+func claimStorageClassName(ob *v1alpha1.ObjectBucket) string {
+	if name, ok := ob.Annotations[claimStorageClassAnnotation]; ok {
+		return name
+	}
+	return ob.Spec.StorageClassName
+}
+
+// storageClassForObjectBucket returns the storage class of the OB's claim.
 func storageClassForObjectBucket(ob *v1alpha1.ObjectBucket, c kubernetes.Interface) (*storagev1.StorageClass, error) {
 	if ob == nil {
 		return nil, fmt.Errorf("got nil ObjectBucket pointer")
 	}
-	if ob.Spec.StorageClassName == "" {
+	className := claimStorageClassName(ob)
+	if className == "" {
 		return nil, fmt.Errorf("no StorageClass defined for ObjectBucket %q", ob.Name)
 	}
-	logD.Info("getting ObjectBucket's storage class", "name", ob.Spec.StorageClassName)
-	class, err := c.StorageV1().StorageClasses().Get(context.TODO(), ob.Spec.StorageClassName, metav1.GetOptions{})
+	logD.Info("getting ObjectBucket's storage class", "name", className)
+	class, err := c.StorageV1().StorageClasses().Get(context.TODO(), className, metav1.GetOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("error getting StorageClass %q: %v", ob.Spec.StorageClassName, err)
+		return nil, fmt.Errorf("error getting StorageClass %q: %v", className, err)
 	}
 	log.Info("got StorageClass", "name")
 
 	return class, nil
 }
 
+// validateLabels returns the reasons why the keys and values of the labels are invalid, if any.
+func validateLabels(labels map[string]string) []string {
+	var errs []string
+	for k, v := range labels {
+		errs = append(errs, validation.IsQualifiedName(k)...)
+		errs = append(errs, validation.IsValidLabelValue(v)...)
+	}
+	return errs
+}
+
 func addLabels(obj metav1.Object, newLabels map[string]string) {
 	labels := obj.GetLabels()
 	if labels == nil {
@@ -250,6 +453,40 @@ func addLabels(obj metav1.Object, newLabels map[string]string) {
 	obj.SetLabels(labels)
 }
 
+func addAnnotations(obj metav1.Object, newAnnotations map[string]string) {
+	if len(newAnnotations) == 0 {
+		return
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	for k, v := range newAnnotations {
+		annotations[k] = v
+	}
+	obj.SetAnnotations(annotations)
+}
+
+// changedEntries returns the entries of wanted which are missing from existing or differ from it.
+func changedEntries(existing, wanted map[string]string) map[string]string {
+	changed := make(map[string]string)
+	for k, v := range wanted {
+		if current, ok := existing[k]; !ok || current != v {
+			changed[k] = v
+		}
+	}
+	return changed
+}
+
+// mergeGeneratedMeta adds the labels, annotations and finalizers of a generated object to the
+// existing object, and makes the existing object's owner that of the generated object.
+func mergeGeneratedMeta(existing, generated metav1.Object) {
+	addLabels(existing, generated.GetLabels())
+	addAnnotations(existing, generated.GetAnnotations())
+	addFinalizers(existing, generated.GetFinalizers())
+	existing.SetOwnerReferences(generated.GetOwnerReferences())
+}
+
 func addFinalizers(obj metav1.Object, newFilalizers []string) {
 	finalizers := obj.GetFinalizers()
 	finalizerMap := make(map[string]struct{})
@@ -265,6 +502,16 @@ func addFinalizers(obj metav1.Object, newFilalizers []string) {
 	obj.SetFinalizers(finalizers)
 }
 
+// hasFinalizer reports whether the controller's finalizer is set on the object.
+func hasFinalizer(obj metav1.Object) bool {
+	for _, f := range obj.GetFinalizers() {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
 func removeFinalizer(obj metav1.Object) {
 	finalizers := obj.GetFinalizers()
 	for i, f := range finalizers {
@@ -286,3 +533,695 @@ func labelValue(v string) string {
 	}
 	return strings.Replace(v, "/", "-", -1)
 }
+
+var errProvisionTimeout = errors.New("timed out waiting for provisioner")
+
+// callWithTimeout calls provision and waits at most timeout for it to return, returning
+// errProvisionTimeout if it does not. A timeout of 0 waits indefinitely. The Provisioner interface
+// does not accept a context, so the call cannot be cancelled and is left to finish in the
+// background after a timeout. If the abandoned call later returns a bucket, it is passed to
+// abandoned, when not nil, so that the bucket is not leaked.
+func callWithTimeout(timeout time.Duration, provision func() (*v1alpha1.ObjectBucket, error), abandoned func(*v1alpha1.ObjectBucket)) (*v1alpha1.ObjectBucket, error) {
+	if timeout == 0 {
+		return provision()
+	}
+
+	type result struct {
+		ob  *v1alpha1.ObjectBucket
+		err error
+	}
+	// buffered so that an abandoned call does not block forever sending its result
+	done := make(chan result, 1)
+	go func() {
+		ob, err := provision()
+		done <- result{ob: ob, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.ob, r.err
+	case <-timer.C:
+		if abandoned != nil {
+			go func() {
+				r := <-done
+				if r.err == nil && r.ob != nil && !apiequality.Semantic.DeepEqual(*r.ob, v1alpha1.ObjectBucket{}) {
+					abandoned(r.ob)
+				}
+			}()
+		}
+		return nil, errProvisionTimeout
+	}
+}
+
+// isTLSEndpoint reports whether the bucket endpoint of the OB uses TLS: its host has the https
+// scheme, or has no scheme and the port is 443.
+func isTLSEndpoint(ob *v1alpha1.ObjectBucket) bool {
+	if ob.Spec.Connection == nil || ob.Spec.Endpoint == nil {
+		return false
+	}
+	host := strings.ToLower(ob.Spec.Endpoint.BucketHost)
+	switch {
+	case strings.HasPrefix(host, "https://"):
+		return true
+	case strings.Contains(host, "://"):
+		return false
+	}
+	return ob.Spec.Endpoint.BucketPort == 443
+}
+
+// hasAuthentication returns true if the provisioner returned credentials for accessing the bucket.
+func hasAuthentication(ob *v1alpha1.ObjectBucket) bool {
+	auth := ob.Spec.Authentication
+	return auth != nil && (auth.AccessKeys != nil || len(auth.AdditionalSecretData) > 0)
+}
+
+// regionHostPatterns match the hosts of well-known object stores which encode the region, with
+// the region as the first submatch.
+var regionHostPatterns = []*regexp.Regexp{
+	// AWS, eg. s3.eu-west-1.amazonaws.com, bucket.s3-us-west-2.amazonaws.com or
+	// s3.dualstack.cn-north-1.amazonaws.com.cn
+	regexp.MustCompile(`(?:^|\.)s3[.-](?:dualstack\.)?([a-z]{2}(?:-gov)?-[a-z]+-[0-9])\.amazonaws\.com(?:\.cn)?$`),
+	// Wasabi and Backblaze B2, eg. s3.eu-central-1.wasabisys.com or s3.us-west-004.backblazeb2.com
+	regexp.MustCompile(`(?:^|\.)s3\.([a-z]{2}-[a-z]+-[0-9]+)\.(?:wasabisys|backblazeb2)\.com$`),
+	// DigitalOcean Spaces, eg. nyc3.digitaloceanspaces.com
+	regexp.MustCompile(`(?:^|\.)([a-z]{3}[0-9])\.digitaloceanspaces\.com$`),
+}
+
+// regionFromHost returns the region encoded in the host of a bucket endpoint, or "" if the host
+// does not match a known pattern. The legacy global AWS endpoint is in us-east-1.
+func regionFromHost(host string) string {
+	host = strings.ToLower(host)
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+len("://"):]
+	}
+	if i := strings.IndexAny(host, ":/"); i >= 0 {
+		host = host[:i]
+	}
+	if host == "s3.amazonaws.com" || strings.HasSuffix(host, ".s3.amazonaws.com") {
+		return "us-east-1"
+	}
+	for _, pattern := range regionHostPatterns {
+		if m := pattern.FindStringSubmatch(host); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// detectRegionForClass returns whether the storage class asks for the region of endpoints to be
+// detected from their host when the provisioner does not set it.
+func detectRegionForClass(class *storagev1.StorageClass) (bool, error) {
+	value, ok := class.Parameters[v1alpha1.StorageClassDetectRegion]
+	if !ok {
+		return false, nil
+	}
+	detect, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("StorageClass %q parameter %q must be a boolean, got %q", class.Name, v1alpha1.StorageClassDetectRegion, value)
+	}
+	return detect, nil
+}
+
+// allowBrownfieldUpdatesForClass returns whether the storage class allows the additionalConfig of
+// claims of an existing bucket to be updated.
+func allowBrownfieldUpdatesForClass(class *storagev1.StorageClass) (bool, error) {
+	value, ok := class.Parameters[v1alpha1.StorageClassAllowBrownfieldUpdates]
+	if !ok {
+		return false, nil
+	}
+	allow, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("StorageClass %q parameter %q must be a boolean, got %q", class.Name, v1alpha1.StorageClassAllowBrownfieldUpdates, value)
+	}
+	return allow, nil
+}
+
+// allowBucketAdoptionForClass returns whether the storage class allows its claims to adopt existing
+// buckets.
+func allowBucketAdoptionForClass(class *storagev1.StorageClass) (bool, error) {
+	value, ok := class.Parameters[v1alpha1.StorageClassAllowBucketAdoption]
+	if !ok {
+		return false, nil
+	}
+	allow, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("StorageClass %q parameter %q must be a boolean, got %q", class.Name, v1alpha1.StorageClassAllowBucketAdoption, value)
+	}
+	return allow, nil
+}
+
+// skipLabelPropagation returns whether the claim opts out of the propagation of the provisioner
+// labels with the SkipLabelPropagationAnnotation. Values other than booleans are ignored.
+func skipLabelPropagation(obc *v1alpha1.ObjectBucketClaim) bool {
+	skip, _ := strconv.ParseBool(obc.Annotations[v1alpha1.SkipLabelPropagationAnnotation])
+	return skip
+}
+
+// reconcileRequested returns whether the update of a claim sets its ReconcileNowAnnotation to a new
+// value, so that a value which is left in place does not requeue the claim on every update.
+func reconcileRequested(old, new *v1alpha1.ObjectBucketClaim) bool {
+	value := new.Annotations[v1alpha1.ReconcileNowAnnotation]
+	return value != "" && value != old.Annotations[v1alpha1.ReconcileNowAnnotation]
+}
+
+// sensitiveParameterWords are the words which mark a storage class parameter as sensitive, matched
+// case-insensitively anywhere in its key, eg. "secretAccessKey" or "adminPassword".
+var sensitiveParameterWords = []string{"secret", "password", "token", "credential"}
+
+// redactParameters returns a copy of the parameters, for logging, with the values of the keys in
+// redacted and of the keys containing a sensitive word replaced.
+func redactParameters(parameters map[string]string, redacted map[string]bool) map[string]string {
+	result := make(map[string]string, len(parameters))
+	for k, v := range parameters {
+		if redacted[k] || containsSensitiveWord(k) {
+			v = "<redacted>"
+		}
+		result[k] = v
+	}
+	return result
+}
+
+func containsSensitiveWord(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range sensitiveParameterWords {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// bucketNameCollisionForClass returns the policy of the storage class for claims whose new bucket's
+// name is taken, empty if the class does not set one.
+func bucketNameCollisionForClass(class *storagev1.StorageClass) (string, error) {
+	switch value := class.Parameters[v1alpha1.StorageClassBucketNameCollision]; value {
+	case "", v1alpha1.BucketNameCollisionFail, v1alpha1.BucketNameCollisionUniquify:
+		return value, nil
+	default:
+		return "", fmt.Errorf("StorageClass %q parameter %q must be %q or %q, got %q", class.Name, v1alpha1.StorageClassBucketNameCollision, v1alpha1.BucketNameCollisionFail, v1alpha1.BucketNameCollisionUniquify, value)
+	}
+}
+
+// uniqueBucketName returns a new name for the claim's bucket after its name was found to be taken,
+// generated from the claim's generateBucketName or, if it has none, its bucketName.
+func uniqueBucketName(obc *v1alpha1.ObjectBucketClaim) string {
+	prefix := obc.Spec.GenerateBucketName
+	if prefix == "" {
+		prefix = obc.Spec.BucketName
+	}
+	return generateBucketName(prefix)
+}
+
+// versioningForClaim returns whether the claim's additionalConfig asks for object versioning to be
+// enabled on its bucket. A provisioner which declares the key in its additionalConfig schema
+// validates and interprets it itself, so it is ignored here.
+func versioningForClaim(obc *v1alpha1.ObjectBucketClaim, provisioner api.Provisioner) (bool, error) {
+	if additionalConfigDeclared(provisioner, v1alpha1.AdditionalConfigVersioning) {
+		return false, nil
+	}
+	value, ok := obc.Spec.AdditionalConfig[v1alpha1.AdditionalConfigVersioning]
+	if !ok {
+		return false, nil
+	}
+	versioning, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("additionalConfig %q must be a boolean, got %q", v1alpha1.AdditionalConfigVersioning, value)
+	}
+	return versioning, nil
+}
+
+// additionalConfigDeclared reports whether the provisioner declares the additionalConfig key in its
+// schema, in which case it interprets the key itself.
+func additionalConfigDeclared(provisioner api.Provisioner, key string) bool {
+	validator, ok := provisioner.(api.AdditionalConfigValidator)
+	if !ok {
+		return false
+	}
+	_, declared := validator.AdditionalConfigSchema()[key]
+	return declared
+}
+
+// accessModeForClaim returns the access which the claim's additionalConfig requests for its
+// credentials, read-write if none. A provisioner which declares the key in its additionalConfig
+// schema interprets it itself, so it is ignored here.
+func accessModeForClaim(obc *v1alpha1.ObjectBucketClaim, provisioner api.Provisioner) (api.AccessMode, error) {
+	if additionalConfigDeclared(provisioner, v1alpha1.AdditionalConfigAccess) {
+		return api.AccessModeReadWrite, nil
+	}
+	value, ok := obc.Spec.AdditionalConfig[v1alpha1.AdditionalConfigAccess]
+	if !ok {
+		return api.AccessModeReadWrite, nil
+	}
+	switch mode := api.AccessMode(value); mode {
+	case api.AccessModeRead, api.AccessModeReadWrite:
+		return mode, nil
+	}
+	return "", fmt.Errorf("additionalConfig %q must be %q or %q, got %q", v1alpha1.AdditionalConfigAccess, api.AccessModeRead, api.AccessModeReadWrite, value)
+}
+
+// lifecycleForClaim returns the validated lifecycle rules requested by the claim's additionalConfig,
+// nil if none. Requesting rules from a provisioner which cannot apply them is an error. A
+// provisioner which declares the key in its additionalConfig schema interprets it itself, so it is
+// ignored here.
+func lifecycleForClaim(obc *v1alpha1.ObjectBucketClaim, provisioner api.Provisioner) ([]api.LifecycleRule, error) {
+	if additionalConfigDeclared(provisioner, v1alpha1.AdditionalConfigLifecycle) {
+		return nil, nil
+	}
+	value, ok := obc.Spec.AdditionalConfig[v1alpha1.AdditionalConfigLifecycle]
+	if !ok {
+		return nil, nil
+	}
+	rules, err := parseLifecycleRules(value)
+	if err != nil {
+		return nil, fmt.Errorf("additionalConfig %q is invalid: %v", v1alpha1.AdditionalConfigLifecycle, err)
+	}
+	if _, ok := provisioner.(api.LifecycleApplier); ok || len(rules) == 0 {
+		return rules, nil
+	}
+	return nil, fmt.Errorf("additionalConfig %q is not supported by the provisioner", v1alpha1.AdditionalConfigLifecycle)
+}
+
+// maxLifecycleRules is the limit on the rules of a bucket's lifecycle policy shared by common
+// object stores, eg. S3
+const maxLifecycleRules = 1000
+
+// parseLifecycleRules decodes a JSON list of lifecycle rules and validates each rule.
+func parseLifecycleRules(value string) ([]api.LifecycleRule, error) {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	var rules []api.LifecycleRule
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("must be a JSON list of rules: %v", err)
+	}
+	if len(rules) > maxLifecycleRules {
+		return nil, fmt.Errorf("got %d rules, at most %d are allowed", len(rules), maxLifecycleRules)
+	}
+	ids := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		switch {
+		case rule.ID == "":
+			return nil, fmt.Errorf("rule %d has no id", i)
+		case ids[rule.ID]:
+			return nil, fmt.Errorf("rule id %q is not unique", rule.ID)
+		case rule.ExpirationDays < 0 || rule.TransitionDays < 0:
+			return nil, fmt.Errorf("rule %q must not have negative days", rule.ID)
+		case (rule.TransitionDays == 0) != (rule.TransitionStorageClass == ""):
+			return nil, fmt.Errorf("rule %q must set both or neither of transitionDays and transitionStorageClass", rule.ID)
+		case rule.ExpirationDays == 0 && rule.TransitionDays == 0:
+			return nil, fmt.Errorf("rule %q must expire or transition objects", rule.ID)
+		}
+		ids[rule.ID] = true
+	}
+	return rules, nil
+}
+
+// endpointWithRegion returns the endpoint, or a copy with the region detected from its host if
+// detect is set and the provisioner left the region empty.
+func endpointWithRegion(ep *v1alpha1.Endpoint, detect bool) *v1alpha1.Endpoint {
+	if !detect || ep == nil || ep.Region != "" {
+		return ep
+	}
+	region := regionFromHost(ep.BucketHost)
+	if region == "" {
+		return ep
+	}
+	ep = ep.DeepCopy()
+	ep.Region = region
+	return ep
+}
+
+// setSecretGeneratedCondition records on the claim's status whether a credentials secret was
+// generated for it.
+func setSecretGeneratedCondition(obc *v1alpha1.ObjectBucketClaim, generated bool, now time.Time) {
+	condition := metav1.Condition{
+		Type:               v1alpha1.ObjectBucketClaimConditionSecretGenerated,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: obc.Generation,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "SecretGenerated",
+		Message:            "Secret contains the credentials for accessing the bucket",
+	}
+	if !generated {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "NoAuthentication"
+		condition.Message = "Provisioner returned no credentials for accessing the bucket"
+	}
+	meta.SetStatusCondition(&obc.Status.Conditions, condition)
+}
+
+// additionalConfigChanged returns true if the claim's additionalConfig differs from that recorded
+// in its OB.
+func additionalConfigChanged(ob *v1alpha1.ObjectBucket, obc *v1alpha1.ObjectBucketClaim) bool {
+	if ob.Spec.Endpoint == nil {
+		return false
+	}
+	old, new := ob.Spec.Endpoint.AdditionalConfigData, obc.Spec.AdditionalConfig
+	if len(old) == 0 && len(new) == 0 {
+		return false
+	}
+	return !reflect.DeepEqual(old, new)
+}
+
+// configDiff describes the keys which were added, removed or modified between two versions of
+// an additionalConfig. Values are omitted, as they are not needed to explain the change.
+type configDiff struct {
+	Added    []string `json:"added,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+	Modified []string `json:"modified,omitempty"`
+}
+
+// diffConfig returns the sorted keys which were added, removed or modified between the old and
+// new config.
+func diffConfig(old, new map[string]string) configDiff {
+	var diff configDiff
+	for k, v := range new {
+		if oldV, ok := old[k]; !ok {
+			diff.Added = append(diff.Added, k)
+		} else if oldV != v {
+			diff.Modified = append(diff.Modified, k)
+		}
+	}
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			diff.Removed = append(diff.Removed, k)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	return diff
+}
+
+// keys returns all keys of the diff, sorted.
+func (d configDiff) keys() []string {
+	var keys []string
+	keys = append(keys, d.Added...)
+	keys = append(keys, d.Removed...)
+	keys = append(keys, d.Modified...)
+	sort.Strings(keys)
+	return keys
+}
+
+// has reports whether the key was added, removed or modified.
+func (d configDiff) has(key string) bool {
+	for _, k := range d.keys() {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (d configDiff) String() string {
+	return fmt.Sprintf("added %v, removed %v, modified %v", d.Added, d.Removed, d.Modified)
+}
+
+func setUpdateDegradedCondition(obc *v1alpha1.ObjectBucketClaim, message string, now time.Time) {
+	meta.SetStatusCondition(&obc.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ObjectBucketClaimConditionUpdateDegraded,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: obc.Generation,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "RevertFailed",
+		Message:            message,
+	})
+}
+
+func clearUpdateDegradedCondition(obc *v1alpha1.ObjectBucketClaim, now time.Time) {
+	meta.SetStatusCondition(&obc.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ObjectBucketClaimConditionUpdateDegraded,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: obc.Generation,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "Updated",
+		Message:            "additionalConfig is recorded in the ObjectBucket",
+	})
+}
+
+func setBackedOffCondition(obc *v1alpha1.ObjectBucketClaim, interval time.Duration, now time.Time) {
+	meta.SetStatusCondition(&obc.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ObjectBucketClaimConditionBackedOff,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: obc.Generation,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "FailingTooLong",
+		Message:            fmt.Sprintf("claim has failed to become bound for too long and is retried every %v", interval),
+	})
+}
+
+// clearBackedOffCondition marks a claim which was backed off as no longer backed off. No
+// condition is added to claims which were never backed off.
+func clearBackedOffCondition(obc *v1alpha1.ObjectBucketClaim, now time.Time) {
+	if meta.FindStatusCondition(obc.Status.Conditions, v1alpha1.ObjectBucketClaimConditionBackedOff) == nil {
+		return
+	}
+	meta.SetStatusCondition(&obc.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ObjectBucketClaimConditionBackedOff,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: obc.Generation,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "Bound",
+		Message:            "claim is bound",
+	})
+}
+
+func setWaitingForCapacityCondition(obc *v1alpha1.ObjectBucketClaim, now time.Time) {
+	meta.SetStatusCondition(&obc.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ObjectBucketClaimConditionWaitingForCapacity,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: obc.Generation,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "AtCapacity",
+		Message:            "object store is at capacity",
+	})
+}
+
+// clearWaitingForCapacityCondition marks a claim which waited for capacity as no longer waiting.
+// No condition is added to claims which never waited.
+func clearWaitingForCapacityCondition(obc *v1alpha1.ObjectBucketClaim, now time.Time) {
+	if meta.FindStatusCondition(obc.Status.Conditions, v1alpha1.ObjectBucketClaimConditionWaitingForCapacity) == nil {
+		return
+	}
+	meta.SetStatusCondition(&obc.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ObjectBucketClaimConditionWaitingForCapacity,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: obc.Generation,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "Bound",
+		Message:            "claim is bound",
+	})
+}
+
+func setCredentialsDriftedCondition(obc *v1alpha1.ObjectBucketClaim, now time.Time) {
+	meta.SetStatusCondition(&obc.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ObjectBucketClaimConditionCredentialsDrifted,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: obc.Generation,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "SecretChanged",
+		Message:            "the credentials in the secret differ from those of the ObjectBucket",
+	})
+}
+
+// clearCredentialsDriftedCondition marks a claim whose credentials drifted as in sync again. No
+// condition is added to claims whose credentials never drifted.
+func clearCredentialsDriftedCondition(obc *v1alpha1.ObjectBucketClaim, now time.Time) {
+	if meta.FindStatusCondition(obc.Status.Conditions, v1alpha1.ObjectBucketClaimConditionCredentialsDrifted) == nil {
+		return
+	}
+	meta.SetStatusCondition(&obc.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ObjectBucketClaimConditionCredentialsDrifted,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: obc.Generation,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "InSync",
+		Message:            "the credentials in the secret match those of the ObjectBucket",
+	})
+}
+
+// setBucketMissingCondition marks a bound claim whose bucket the provisioner reported to be missing.
+func setBucketMissingCondition(obc *v1alpha1.ObjectBucketClaim, message string, now time.Time) {
+	meta.SetStatusCondition(&obc.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ObjectBucketClaimConditionBucketMissing,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: obc.Generation,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "NotFound",
+		Message:            message,
+	})
+}
+
+// clearBucketMissingCondition marks a claim whose bucket was missing as having a bucket again. No
+// condition is added to claims whose bucket was never missing.
+func clearBucketMissingCondition(obc *v1alpha1.ObjectBucketClaim, now time.Time) {
+	if meta.FindStatusCondition(obc.Status.Conditions, v1alpha1.ObjectBucketClaimConditionBucketMissing) == nil {
+		return
+	}
+	meta.SetStatusCondition(&obc.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ObjectBucketClaimConditionBucketMissing,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: obc.Generation,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "Found",
+		Message:            "the bucket exists in the object store",
+	})
+}
+
+// secretCredentials returns the values of the given credential keys in the secret. Values in
+// StringData, which have not yet been merged into Data, take precedence.
+func secretCredentials(secret *corev1.Secret, keys map[string]string) map[string]string {
+	creds := make(map[string]string, len(keys))
+	for k := range keys {
+		if v, ok := secret.StringData[k]; ok {
+			creds[k] = v
+		} else if v, ok := secret.Data[k]; ok {
+			creds[k] = string(v)
+		}
+	}
+	return creds
+}
+
+func setResourceCreationForbiddenCondition(obc *v1alpha1.ObjectBucketClaim, message string, now time.Time) {
+	meta.SetStatusCondition(&obc.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ObjectBucketClaimConditionResourceCreationForbidden,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: obc.Generation,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "Forbidden",
+		Message:            message,
+	})
+}
+
+// clearResourceCreationForbiddenCondition marks a claim whose resources were forbidden as no longer
+// forbidden. No condition is added to claims whose resources were never forbidden.
+func clearResourceCreationForbiddenCondition(obc *v1alpha1.ObjectBucketClaim, now time.Time) {
+	if meta.FindStatusCondition(obc.Status.Conditions, v1alpha1.ObjectBucketClaimConditionResourceCreationForbidden) == nil {
+		return
+	}
+	meta.SetStatusCondition(&obc.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ObjectBucketClaimConditionResourceCreationForbidden,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: obc.Generation,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             "Created",
+		Message:            "secret and configmap are created",
+	})
+}
+
+// Limits on bucket tags shared by common object stores, eg. S3
+const (
+	maxBucketTags        = 50
+	maxBucketTagKeyLen   = 128
+	maxBucketTagValueLen = 256
+)
+
+// bucketTagChars matches the characters allowed in bucket tag keys and values
+var bucketTagChars = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// bucketTags returns the tags to be applied to the claim's bucket: the fixed tags of the class's
+// "tags" parameter, overridden by the claim's labels or annotations named by its "tagLabels"
+// parameter. A label takes precedence over an annotation with the same key.
+func bucketTags(obc *v1alpha1.ObjectBucketClaim, class *storagev1.StorageClass) (map[string]string, error) {
+	tags := make(map[string]string)
+	if fixed := class.Parameters[v1alpha1.StorageClassTags]; fixed != "" {
+		for _, pair := range strings.Split(fixed, ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("StorageClass %q parameter %q must be comma-separated key=value pairs, got %q", class.Name, v1alpha1.StorageClassTags, fixed)
+			}
+			tags[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	if tagLabels := class.Parameters[v1alpha1.StorageClassTagLabels]; tagLabels != "" {
+		for _, key := range strings.Split(tagLabels, ",") {
+			key = strings.TrimSpace(key)
+			if v, ok := obc.Labels[key]; ok {
+				tags[key] = v
+			} else if v, ok := obc.Annotations[key]; ok {
+				tags[key] = v
+			}
+		}
+	}
+
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	if len(tags) > maxBucketTags {
+		return nil, fmt.Errorf("got %d bucket tags, at most %d are allowed", len(tags), maxBucketTags)
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := validateBucketTag(k, tags[k]); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+func validateBucketTag(key, value string) error {
+	if len(key) == 0 || len(key) > maxBucketTagKeyLen {
+		return fmt.Errorf("bucket tag key %q must be 1 to %d characters", key, maxBucketTagKeyLen)
+	}
+	if len(value) > maxBucketTagValueLen {
+		return fmt.Errorf("value of bucket tag %q must be at most %d characters", key, maxBucketTagValueLen)
+	}
+	if !bucketTagChars.MatchString(key) || !bucketTagChars.MatchString(value) {
+		return fmt.Errorf("bucket tag %q=%q may only contain letters, numbers, spaces and _.:/=+-@", key, value)
+	}
+	return nil
+}
+
+// validateAdditionalConfig returns an error describing each key of config which is unknown to the
+// schema or whose value the schema rejects, or nil if config is valid.
+func validateAdditionalConfig(config map[string]string, schema api.AdditionalConfigSchema) error {
+	keys := make([]string, 0, len(config))
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var unknown, invalid []string
+	for _, k := range keys {
+		validate, ok := schema[k]
+		if !ok {
+			unknown = append(unknown, k)
+		} else if validate != nil {
+			if err := validate(config[k]); err != nil {
+				invalid = append(invalid, fmt.Sprintf("%s: %v", k, err))
+			}
+		}
+	}
+
+	var problems []string
+	if len(unknown) > 0 {
+		problems = append(problems, fmt.Sprintf("unknown additionalConfig keys %v", unknown))
+	}
+	if len(invalid) > 0 {
+		problems = append(problems, fmt.Sprintf("invalid additionalConfig values: %s", strings.Join(invalid, ", ")))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+// standardConfigMapKeys are the keys written to every configmap generated for a claim.
+var standardConfigMapKeys = []string{bucketName, bucketHost, bucketPort, bucketRegion, bucketSubRegion}
+
+// missingConfigMapKeys returns the standard keys missing from the given configmap data, in the
+// order of standardConfigMapKeys.
+func missingConfigMapKeys(data map[string]string) []string {
+	var missing []string
+	for _, k := range standardConfigMapKeys {
+		if _, ok := data[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	return missing
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -30,6 +31,7 @@ import (
 
 	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
 	externalFake "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned/fake"
@@ -292,6 +294,89 @@ func TestStorageClassForClaim(t *testing.T) {
 	}
 }
 
+func TestComposeBucketName(t *testing.T) {
+	tests := []struct {
+		name               string
+		bucketName         string
+		generateBucketName string
+		wantName           string
+		wantPrefix         string
+		wantErr            error
+		wantInvalid        bool
+	}{
+		{
+			name:               "prefix",
+			generateBucketName: "photo-booth",
+			wantPrefix:         "photo-booth-",
+		},
+		{
+			name:               "explicit name overrides prefix",
+			bucketName:         "my-bucket",
+			generateBucketName: "photo-booth",
+			wantName:           "my-bucket",
+		},
+		{
+			name:       "explicit name",
+			bucketName: "my-bucket",
+			wantName:   "my-bucket",
+		},
+		{
+			name:    "neither",
+			wantErr: errBucketNameMissing,
+		},
+		{
+			name:               "prefix ending in a period",
+			generateBucketName: "photos.",
+			wantPrefix:         "photos-",
+		},
+		{
+			name:               "uppercase prefix",
+			generateBucketName: "Photos",
+			wantInvalid:        true,
+		},
+		{
+			name:               "prefix beginning with a hyphen",
+			generateBucketName: "-photos",
+			wantInvalid:        true,
+		},
+		{
+			name:               "prefix with adjacent periods",
+			generateBucketName: "photo..booth",
+			wantInvalid:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := &v1alpha1.ObjectBucketClaim{Spec: v1alpha1.ObjectBucketClaimSpec{
+				BucketName:         tt.bucketName,
+				GenerateBucketName: tt.generateBucketName,
+			}}
+			got, err := composeBucketName(obc)
+			switch {
+			case tt.wantErr != nil:
+				if err != tt.wantErr {
+					t.Errorf("wanted error %v, got %v", tt.wantErr, err)
+				}
+				return
+			case tt.wantInvalid:
+				if err == nil || err == errBucketNameMissing {
+					t.Errorf("wanted invalid prefix error, got %v", err)
+				}
+				return
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantName != "" && got != tt.wantName {
+				t.Errorf("wanted bucket name %q, got %q", tt.wantName, got)
+			}
+			if tt.wantPrefix != "" && !strings.HasPrefix(got, tt.wantPrefix) {
+				t.Errorf("wanted bucket name with prefix %q, got %q", tt.wantPrefix, got)
+			}
+		})
+	}
+}
+
 func TestGenerateBucketName(t *testing.T) {
 	type args struct {
 		prefix string
@@ -431,3 +516,438 @@ func TestAddFinalizers(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultStorageClass(t *testing.T) {
+	newClass := func(name string, annotations map[string]string) *storagev1.StorageClass {
+		return &storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Annotations: annotations,
+			},
+		}
+	}
+	libDefault := map[string]string{v1alpha1.DefaultStorageClassAnnotation: "true"}
+	clusterDefault := map[string]string{isDefaultStorageClassAnnotation: "true"}
+
+	tests := []struct {
+		name    string
+		classes []runtime.Object
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "no storage classes",
+			wantErr: true,
+		},
+		{
+			name:    "no default storage class",
+			classes: []runtime.Object{newClass("a", nil), newClass("b", map[string]string{isDefaultStorageClassAnnotation: "false"})},
+			wantErr: true,
+		},
+		{
+			name:    "objectbucket.io default",
+			classes: []runtime.Object{newClass("a", nil), newClass("b", libDefault)},
+			want:    "b",
+		},
+		{
+			name:    "cluster default",
+			classes: []runtime.Object{newClass("a", clusterDefault), newClass("b", nil)},
+			want:    "a",
+		},
+		{
+			name:    "beta cluster default",
+			classes: []runtime.Object{newClass("a", map[string]string{betaIsDefaultStorageClassAnnotation: "true"})},
+			want:    "a",
+		},
+		{
+			name:    "objectbucket.io default takes precedence over cluster default",
+			classes: []runtime.Object{newClass("a", clusterDefault), newClass("b", libDefault)},
+			want:    "b",
+		},
+		{
+			name:    "multiple defaults",
+			classes: []runtime.Object{newClass("a", libDefault), newClass("b", libDefault), newClass("c", clusterDefault)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := &v1alpha1.ObjectBucketClaim{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testName},
+			}
+			got, err := storageClassForClaim(fake.NewSimpleClientset(tt.classes...), obc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wantErr %v, error = %v ", tt.wantErr, err)
+			}
+			if err == nil && got.Name != tt.want {
+				t.Errorf("wanted StorageClass %q, got %q", tt.want, got.Name)
+			}
+		})
+	}
+}
+
+func TestBucketTags(t *testing.T) {
+	obc := &v1alpha1.ObjectBucketClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   testNamespace,
+			Name:        testName,
+			Labels:      map[string]string{"cost-center": "1234", "app": "photos"},
+			Annotations: map[string]string{"owner": "jane@example.com", "app": "ignored", "notes": "a\nb"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		want       map[string]string
+		wantErr    bool
+	}{
+		{
+			name: "no tags",
+		},
+		{
+			name:       "fixed tags",
+			parameters: map[string]string{v1alpha1.StorageClassTags: "team=storage, env=prod"},
+			want:       map[string]string{"team": "storage", "env": "prod"},
+		},
+		{
+			name: "labels and annotations override fixed tags",
+			parameters: map[string]string{
+				v1alpha1.StorageClassTags:      "cost-center=0000,env=prod",
+				v1alpha1.StorageClassTagLabels: "cost-center,app,owner,missing",
+			},
+			want: map[string]string{"cost-center": "1234", "env": "prod", "app": "photos", "owner": "jane@example.com"},
+		},
+		{
+			name:       "malformed fixed tags",
+			parameters: map[string]string{v1alpha1.StorageClassTags: "team"},
+			wantErr:    true,
+		},
+		{
+			name:       "invalid tag value",
+			parameters: map[string]string{v1alpha1.StorageClassTagLabels: "notes"},
+			wantErr:    true,
+		},
+		{
+			name:       "empty tag key",
+			parameters: map[string]string{v1alpha1.StorageClassTags: "=value"},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: className}, Parameters: tt.parameters}
+			got, err := bucketTags(obc, class)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("bucketTags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("bucketTags() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegionFromHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "s3.eu-west-1.amazonaws.com", want: "eu-west-1"},
+		{host: "https://S3-US-WEST-2.amazonaws.com:443", want: "us-west-2"},
+		{host: "my-bucket.s3.ap-southeast-2.amazonaws.com", want: "ap-southeast-2"},
+		{host: "s3.dualstack.us-gov-west-1.amazonaws.com", want: "us-gov-west-1"},
+		{host: "s3.cn-north-1.amazonaws.com.cn", want: "cn-north-1"},
+		{host: "s3.amazonaws.com", want: "us-east-1"},
+		{host: "s3.eu-central-1.wasabisys.com", want: "eu-central-1"},
+		{host: "s3.us-west-004.backblazeb2.com", want: "us-west-004"},
+		{host: "nyc3.digitaloceanspaces.com", want: "nyc3"},
+		{host: "rgw.storage.example.com"},
+		{host: "s3.eu-west-1.example.com"},
+		{host: "10.0.0.1"},
+		{host: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := regionFromHost(tt.host); got != tt.want {
+				t.Errorf("regionFromHost() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		old  map[string]string
+		new  map[string]string
+		want configDiff
+	}{
+		{
+			name: "unchanged",
+			old:  map[string]string{"a": "1"},
+			new:  map[string]string{"a": "1"},
+		},
+		{
+			name: "from empty",
+			new:  map[string]string{"b": "2", "a": "1"},
+			want: configDiff{Added: []string{"a", "b"}},
+		},
+		{
+			name: "to empty",
+			old:  map[string]string{"a": "1"},
+			want: configDiff{Removed: []string{"a"}},
+		},
+		{
+			name: "added, removed and modified",
+			old:  map[string]string{"a": "1", "b": "2", "c": "3"},
+			new:  map[string]string{"a": "1", "b": "20", "d": "4"},
+			want: configDiff{Added: []string{"d"}, Removed: []string{"c"}, Modified: []string{"b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, diffConfig(tt.old, tt.new)); diff != "" {
+				t.Errorf("diffConfig() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseLifecycleRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []api.LifecycleRule
+		wantErr bool
+	}{
+		{
+			name:  "expiration and transition",
+			value: `[{"id":"logs","prefix":"logs/","expirationDays":30},{"id":"archive","transitionDays":90,"transitionStorageClass":"GLACIER"}]`,
+			want: []api.LifecycleRule{
+				{ID: "logs", Prefix: "logs/", ExpirationDays: 30},
+				{ID: "archive", TransitionDays: 90, TransitionStorageClass: "GLACIER"},
+			},
+		},
+		{
+			name:  "no rules",
+			value: `[]`,
+			want:  []api.LifecycleRule{},
+		},
+		{
+			name:    "not JSON",
+			value:   `expire after 30 days`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			value:   `[{"id":"logs","expirationDay":30}]`,
+			wantErr: true,
+		},
+		{
+			name:    "missing id",
+			value:   `[{"expirationDays":30}]`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate id",
+			value:   `[{"id":"logs","expirationDays":30},{"id":"logs","expirationDays":60}]`,
+			wantErr: true,
+		},
+		{
+			name:    "negative days",
+			value:   `[{"id":"logs","expirationDays":-1}]`,
+			wantErr: true,
+		},
+		{
+			name:    "transition without storage class",
+			value:   `[{"id":"archive","transitionDays":90}]`,
+			wantErr: true,
+		},
+		{
+			name:    "no action",
+			value:   `[{"id":"logs","prefix":"logs/"}]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLifecycleRules(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLifecycleRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("parseLifecycleRules() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIsTLSEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint *v1alpha1.Endpoint
+		want     bool
+	}{
+		{
+			name: "no endpoint",
+		},
+		{
+			name:     "https port",
+			endpoint: &v1alpha1.Endpoint{BucketHost: "s3.test.com", BucketPort: 443},
+			want:     true,
+		},
+		{
+			name:     "http port",
+			endpoint: &v1alpha1.Endpoint{BucketHost: "s3.test.com", BucketPort: 80},
+		},
+		{
+			name:     "https scheme",
+			endpoint: &v1alpha1.Endpoint{BucketHost: "HTTPS://s3.test.com", BucketPort: 8443},
+			want:     true,
+		},
+		{
+			name:     "http scheme",
+			endpoint: &v1alpha1.Endpoint{BucketHost: "http://s3.test.com", BucketPort: 443},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ob := &v1alpha1.ObjectBucket{}
+			if tt.endpoint != nil {
+				ob.Spec.Connection = &v1alpha1.Connection{Endpoint: tt.endpoint}
+			}
+			if got := isTLSEndpoint(ob); got != tt.want {
+				t.Errorf("isTLSEndpoint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateGeneratedNames(t *testing.T) {
+	tests := []struct {
+		name          string
+		namespace     string
+		claimName     string
+		hashLongNames bool
+		wantErr       bool
+	}{
+		{
+			name:      "short name",
+			namespace: testNamespace,
+			claimName: testName,
+		},
+		{
+			name:      "name too long for the OB",
+			namespace: testNamespace,
+			claimName: strings.Repeat("a", 250),
+			wantErr:   true,
+		},
+		{
+			name:          "name too long for the OB is hashed",
+			namespace:     testNamespace,
+			claimName:     strings.Repeat("a", 250),
+			hashLongNames: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obc := &v1alpha1.ObjectBucketClaim{ObjectMeta: metav1.ObjectMeta{Namespace: tt.namespace, Name: tt.claimName}}
+			if errs := validateGeneratedNames(obc, tt.hashLongNames); (len(errs) > 0) != tt.wantErr {
+				t.Errorf("validateGeneratedNames() = %v, wantErr %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedactParameters(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		redacted   map[string]bool
+		want       map[string]string
+	}{
+		{
+			name:       "no sensitive parameters",
+			parameters: map[string]string{"region": "us-east-1"},
+			want:       map[string]string{"region": "us-east-1"},
+		},
+		{
+			name:       "sensitive words",
+			parameters: map[string]string{"region": "us-east-1", "secretAccessKey": "s3cr3t", "adminPassword": "hunter2", "sessionToken": "t0k3n"},
+			want:       map[string]string{"region": "us-east-1", "secretAccessKey": "<redacted>", "adminPassword": "<redacted>", "sessionToken": "<redacted>"},
+		},
+		{
+			name:       "configured keys",
+			parameters: map[string]string{"region": "us-east-1", "kmsKeyID": "key-1"},
+			redacted:   map[string]bool{"kmsKeyID": true},
+			want:       map[string]string{"region": "us-east-1", "kmsKeyID": "<redacted>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactParameters(tt.parameters, tt.redacted); !cmp.Equal(tt.want, got) {
+				t.Errorf(cmp.Diff(tt.want, got))
+			}
+			if tt.parameters["secretAccessKey"] == "<redacted>" {
+				t.Error("wanted the parameters left unmodified")
+			}
+		})
+	}
+}
+
+func TestReconcileRequested(t *testing.T) {
+	tests := []struct {
+		name     string
+		oldValue string
+		newValue string
+		want     bool
+	}{
+		{
+			name: "not annotated",
+		},
+		{
+			name:     "annotated",
+			newValue: "2020-01-01T00:00:00Z",
+			want:     true,
+		},
+		{
+			name:     "annotation changed",
+			oldValue: "2020-01-01T00:00:00Z",
+			newValue: "2020-01-01T00:05:00Z",
+			want:     true,
+		},
+		{
+			name:     "stale annotation",
+			oldValue: "2020-01-01T00:00:00Z",
+			newValue: "2020-01-01T00:00:00Z",
+		},
+		{
+			name:     "annotation removed",
+			oldValue: "2020-01-01T00:00:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotated := func(value string) *v1alpha1.ObjectBucketClaim {
+				obc := &v1alpha1.ObjectBucketClaim{}
+				if value != "" {
+					obc.Annotations = map[string]string{v1alpha1.ReconcileNowAnnotation: value}
+				}
+				return obc
+			}
+			if got := reconcileRequested(annotated(tt.oldValue), annotated(tt.newValue)); got != tt.want {
+				t.Errorf("reconcileRequested() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
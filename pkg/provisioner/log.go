@@ -17,8 +17,11 @@ limitations under the License.
 package provisioner
 
 import (
+	"time"
+
 	"github.com/go-logr/logr"
 	"k8s.io/klog/v2/klogr"
+	"k8s.io/utils/clock"
 
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
 )
@@ -43,5 +46,47 @@ func init() {
 // setLoggerWith request overwrites log and logD with a new logger.  The passed in request is injected into the loggers.
 func setLoggersWithRequest(key string) {
 	log = klogr.New().WithValues("key", key)
-	logD = klogr.New().WithValues("key", key)
+	logD = log.V(1)
+}
+
+// callerLogger returns a logger for code running on the goroutines of the controller's callers,
+// eg. setters, which must not read log and logD as workers overwrite them concurrently.
+func callerLogger() logr.Logger {
+	return klogr.New().WithName(api.Domain + "/claim-reconciler")
+}
+
+// stepTimer logs at debug level how long each step of a reconcile took. The clock is only read
+// when debug logging is enabled.
+type stepTimer struct {
+	clock   clock.Clock
+	enabled bool
+	start   time.Time
+	last    time.Time
+}
+
+func newStepTimer(clock clock.Clock) *stepTimer {
+	t := &stepTimer{clock: clock, enabled: logD.Enabled()}
+	if t.enabled {
+		t.start = clock.Now()
+		t.last = t.start
+	}
+	return t
+}
+
+// step logs the time since the previous step, or since the timer was created.
+func (t *stepTimer) step(name string) {
+	if !t.enabled {
+		return
+	}
+	now := t.clock.Now()
+	logD.Info("step finished", "step", name, "duration", now.Sub(t.last).String())
+	t.last = now
+}
+
+// total logs the time since the timer was created.
+func (t *stepTimer) total(name string) {
+	if !t.enabled {
+		return
+	}
+	logD.Info("finished", "operation", name, "duration", t.clock.Since(t.start).String())
 }
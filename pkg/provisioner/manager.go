@@ -21,7 +21,6 @@ import (
 	"flag"
 	"time"
 
-	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	klog "k8s.io/klog/v2"
@@ -66,11 +65,13 @@ func initFlags() {
 // respond to Add / Update / Delete events by calling the passed-in
 // provisioner's Provisioner and Delete methods.
 // The Provisioner will be restrict to operating only to the namespace given
+// Options may be given to enable optional behavior of the obcController.
 func NewProvisioner(
 	cfg *rest.Config,
 	provisionerName string,
 	provisioner api.Provisioner,
 	namespace string,
+	options ...Option,
 ) (*Provisioner, error) {
 
 	initFlags()
@@ -80,6 +81,7 @@ func NewProvisioner(
 	clientset := kubernetes.NewForConfigOrDie(cfg)
 
 	informerFactory := setupInformerFactory(libClientset, 0, namespace)
+	options = append([]Option{inNamespace(namespace)}, options...)
 
 	p := &Provisioner{
 		Name:            provisionerName,
@@ -91,7 +93,8 @@ func NewProvisioner(
 			clientset,
 			libClientset,
 			informerFactory.Objectbucket().V1alpha1().ObjectBucketClaims(),
-			informerFactory.Objectbucket().V1alpha1().ObjectBuckets()),
+			informerFactory.Objectbucket().V1alpha1().ObjectBuckets(),
+			options...),
 	}
 
 	return p, nil
@@ -100,20 +103,37 @@ func NewProvisioner(
 // SetLabels allows provisioner author to provide their own resource labels.  They will be set on all
 // managed resources by the provisioner (OBC, OB, CM, Secret)
 func (p *Provisioner) SetLabels(labels map[string]string) []string {
-	var errs []string
-	for _, v := range labels {
-		vErrs := validation.IsValidLabelValue(v)
-		if len(errs) > 0 {
-			errs = append(errs, vErrs...)
-		}
-	}
-	if len(errs) > 0 {
+	return setLabels(p.claimController, labels)
+}
+
+// SetProvisioner replaces the provisioner at runtime, for example after its backend configuration
+// is reloaded, without restarting the controllers. Reconciles in flight when it is called finish
+// against the previous provisioner, which SetProvisioner does not wait for, and all later
+// reconciles use p. The Init and Shutdown hooks of an api.LifecycleHandler are not called for
+// either provisioner: p must be ready to use, and the previous provisioner may still be in use
+// when SetProvisioner returns.
+func (p *Provisioner) SetProvisioner(provisioner api.Provisioner) {
+	p.claimController.SetProvisioner(provisioner)
+}
+
+// setLabels validates the labels and, if they are valid, sets them on the controller.
+// Otherwise, the validation errors are returned.
+func setLabels(c controller, labels map[string]string) []string {
+	if errs := validateLabels(labels); len(errs) > 0 {
 		return errs
 	}
-	p.claimController.SetLabels(labels)
+	c.SetLabels(labels)
 	return nil
 }
 
+// DeleteAllManaged deletes every OBC managed by the provisioner, for example while decommissioning
+// it. This is DANGEROUS: the bucket of each OBC whose reclaim policy is Delete is deleted along with
+// its data. It must be enabled with the WithBulkDeletion option. OBCs which the provisioner has not
+// yet labeled, because they were never synced, are not deleted.
+func (p *Provisioner) DeleteAllManaged(ctx context.Context) error {
+	return p.claimController.DeleteAllManaged(ctx)
+}
+
 // Run starts the claim and bucket controllers.
 func (p *Provisioner) Run(stopCh <-chan struct{}) (err error) {
 	defer klog.Flush()
@@ -0,0 +1,161 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// reconcileOutcome is the result of processing a single workqueue key. Every processed key is
+// recorded with exactly one outcome.
+type reconcileOutcome string
+
+const (
+	outcomeProvisioned        reconcileOutcome = "provisioned"
+	outcomeGranted            reconcileOutcome = "granted"
+	outcomeRevoked            reconcileOutcome = "revoked"
+	outcomeDeleted            reconcileOutcome = "deleted"
+	outcomeAbandoned          reconcileOutcome = "abandoned"
+	outcomeUpdated            reconcileOutcome = "updated"
+	outcomeSkippedUnsupported reconcileOutcome = "skipped-unsupported"
+	outcomeSkippedNotFound    reconcileOutcome = "skipped-not-found"
+	outcomeFailedTerminal     reconcileOutcome = "failed-terminal"
+	outcomeRequeuedTransient  reconcileOutcome = "requeued-transient"
+)
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "obc_reconcile_total",
+			Help: "Number of OBC reconciles, partitioned by outcome.",
+		},
+		[]string{"outcome"},
+	)
+	provisionerInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "obc_provisioner_info",
+			Help: "Information about the provisioner, always 1.",
+		},
+		[]string{"version"},
+	)
+	leakedBucketsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "obc_leaked_buckets_total",
+			Help: "Number of buckets left behind because their claim was released from a terminating namespace without cleanup.",
+		},
+	)
+	stuckPendingClaims = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "obc_stuck_pending_claims",
+			Help: "Number of claims found Pending without an ObjectBucket for longer than the configured threshold by the last check.",
+		},
+	)
+	provisionsInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "obc_provisions_in_flight",
+			Help: "Number of Provision and Grant calls running or waiting for a slot, partitioned by storage class.",
+		},
+		[]string{"storage_class"},
+	)
+	queueDeferralsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "obc_queue_deferrals_total",
+			Help: "Number of claims whose queueing was deferred because the queue was at its maximum depth.",
+		},
+	)
+	capacityBlockedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "obc_capacity_blocked_total",
+			Help: "Number of times provisioning a claim was deferred because the provisioner reported the object store to be at capacity.",
+		},
+	)
+	preflightSucceeded = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "obc_preflight_succeeded",
+			Help: "Whether the provisioner's preflight check at startup succeeded, 1 if so and 0 otherwise.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(reconcileTotal, provisionerInfo, leakedBucketsTotal, stuckPendingClaims, queueDeferralsTotal, provisionsInFlight, preflightSucceeded, capacityBlockedTotal)
+}
+
+func recordReconcile(outcome reconcileOutcome) {
+	reconcileTotal.WithLabelValues(string(outcome)).Inc()
+}
+
+// serveMetrics serves the Prometheus metrics at /metrics on the given address until stopCh is closed
+// or the returned function is called. If debug is set, the pprof profiles are also served at
+// /debug/pprof/ and, as JSON, the keys returned by activeWorkers at /debug/workers. The address
+// actually listened on is returned, eg. to learn the port chosen for ":0".
+func serveMetrics(address string, debug bool, activeWorkers func() map[int]string, stopCh <-chan struct{}) (net.Addr, func(), error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listening on metrics address %q: %v", address, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	if debug {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		mux.HandleFunc("/debug/workers", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(activeWorkers()); err != nil {
+				log.Error(err, "error writing active workers")
+			}
+		})
+	}
+	server := &http.Server{Handler: mux}
+
+	var once sync.Once
+	done := make(chan struct{})
+	shutdown := func() {
+		once.Do(func() {
+			close(done)
+			if err := server.Shutdown(context.Background()); err != nil {
+				log.Error(err, "error shutting down metrics server")
+			}
+		})
+	}
+	go func() {
+		select {
+		case <-stopCh:
+			shutdown()
+		case <-done:
+		}
+	}()
+	go func() {
+		log.Info("serving metrics", "address", listener.Addr().String())
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Error(err, "error serving metrics")
+		}
+	}()
+	return listener.Addr(), shutdown, nil
+}
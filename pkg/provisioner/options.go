@@ -0,0 +1,508 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
+)
+
+// Option configures optional behavior of the claim controller. Options are passed to
+// NewProvisioner or NewController. Omitting an option preserves the controller's default behavior.
+type Option func(*obcController)
+
+// KeySplitFunc parses a workqueue key generated by a cache.KeyFunc into the namespace and name of
+// the ObjectBucketClaim it refers to.
+type KeySplitFunc func(key string) (namespace, name string, err error)
+
+// WithKeyFunc replaces the function used to generate workqueue keys for OBCs, along with the
+// matching function used to parse those keys. This allows keys to carry extra information such as
+// a shard or partition hint. By default, keys are of the form namespace/name.
+func WithKeyFunc(keyFunc cache.KeyFunc, splitFunc KeySplitFunc) Option {
+	return func(c *obcController) {
+		c.keyFunc = keyFunc
+		c.splitKey = splitFunc
+	}
+}
+
+// WithProvisionTimeout bounds how long the controller waits for a call to the provisioner's
+// Provision or Grant method to return. If the timeout elapses the claim is marked Failed, and a
+// bucket which the call returns later is deleted or its access revoked. The
+// StorageClass parameter "provisionTimeout" overrides this value for claims of that class. By
+// default, the controller waits indefinitely.
+func WithProvisionTimeout(timeout time.Duration) Option {
+	return func(c *obcController) {
+		c.provisionTimeout = timeout
+	}
+}
+
+// WithDeleteTimeout bounds how long the controller waits for a call to the provisioner's Delete or
+// Revoke method to return, eg. longer than provisioning for backends which empty a bucket before
+// deleting it. If the timeout elapses a warning event is recorded and the deletion is retried; the
+// abandoned call may still complete. A timeout of 0 waits indefinitely. By default, the timeout of
+// WithProvisionTimeout applies.
+func WithDeleteTimeout(timeout time.Duration) Option {
+	return func(c *obcController) {
+		c.deleteTimeout = &timeout
+	}
+}
+
+// WithDeletionPriority processes claims which are being deleted ahead of all other queued claims,
+// so that backend resources are freed promptly while many claims are being created. Failed
+// deletions are backed off and then retried ahead of other claims as well. By default, claims are processed in the order in which
+// they are queued.
+func WithDeletionPriority() Option {
+	return func(c *obcController) {
+		c.priorityQueue = workqueue.New()
+		c.priorityLimiter = workqueue.DefaultControllerRateLimiter()
+		c.inFlight = make(map[string]bool)
+	}
+}
+
+// WithProvisionerVersion identifies the build of the provisioner. The version is annotated on the
+// OBC and on each resource generated for it, and is exported as the version label of the
+// obc_provisioner_info metric.
+func WithProvisionerVersion(version string) Option {
+	return func(c *obcController) {
+		c.provisionerVersion = version
+	}
+}
+
+// SecretDriftPolicy decides how the controller handles credentials in a generated secret which
+// differ from those returned by the provisioner, eg. because an external secret manager rotated them.
+type SecretDriftPolicy string
+
+const (
+	// SecretDriftFlag leaves the secret as it is and sets the CredentialsDrifted condition on the
+	// claim
+	SecretDriftFlag SecretDriftPolicy = "Flag"
+)
+
+// WithSecretDrift keeps the credentials of the secrets of bound claims which were changed outside
+// the controller, eg. rotated by an external secret manager, rather than restoring the credentials
+// returned by the provisioner. With SecretDriftFlag, the claim is marked with the CredentialsDrifted
+// condition while they differ. The OB's authentication is not stored, so the secret remains the
+// only record of the rotated credentials, and the provisioner is expected to return them once it
+// has caught up with the rotation. Combined with WithReconcileChildren, the claim is reconciled as
+// soon as its secret changes. Unknown policies are ignored. By default, the provisioner's
+// credentials are written to the secret.
+func WithSecretDrift(policy SecretDriftPolicy) Option {
+	return func(c *obcController) {
+		c.secretDrift = policy
+	}
+}
+
+// WithReconcileChildren reverts changes made to the data of the configmaps and secrets generated
+// for OBCs, keeping them authoritative. Labels and annotations added to them are preserved. By
+// default, changes to the generated configmaps and secrets are not reverted.
+func WithReconcileChildren() Option {
+	return func(c *obcController) {
+		c.reconcileChildren = true
+	}
+}
+
+// inNamespace restricts the controller to the given namespace, or to all namespaces if empty.
+func inNamespace(namespace string) Option {
+	return func(c *obcController) {
+		c.namespace = namespace
+	}
+}
+
+// WithStuckClaimBackoff limits the capacity used by claims which permanently fail to sync. Once an
+// unbound claim is older than maxAge, it is retried every interval, eg. 1h, rather than with the
+// default rate limiter's backoff, and its BackedOff condition is set. Claims being deleted are not
+// backed off. By default, all claims are retried using the default rate limiter.
+func WithStuckClaimBackoff(maxAge, interval time.Duration) Option {
+	return func(c *obcController) {
+		c.stuckClaimAge = maxAge
+		c.stuckClaimBackoff = interval
+	}
+}
+
+// WithNamespaceDeletionRetries keeps an unreachable object store from blocking the deletion of
+// namespaces. Once deleting a claim in a terminating namespace has failed retries times, the
+// finalizers of the claim and its configmap and secret are removed without deleting or revoking
+// the bucket. A BucketLeaked warning event is recorded, the obc_leaked_buckets_total metric is
+// incremented, and the OB is kept as a record of the leaked bucket. By default, deletions are
+// retried until they succeed.
+func WithNamespaceDeletionRetries(retries int) Option {
+	return func(c *obcController) {
+		c.namespaceDeletionRetries = retries
+	}
+}
+
+// WithStuckPendingCheck periodically looks for claims which have been Pending for longer than
+// maxAge without an OB, eg. because the controller crashed while provisioning them. Every interval,
+// such claims are requeued and a StuckPending warning event is recorded on each, and their number
+// is exported as the obc_stuck_pending_claims metric. By default, no check is made.
+func WithStuckPendingCheck(maxAge, interval time.Duration) Option {
+	return func(c *obcController) {
+		c.stuckPendingAge = maxAge
+		c.stuckPendingInterval = interval
+	}
+}
+
+// WithCreatedByLabel sets the label marking the OBs created by the controller, distinguishing them
+// from OBs created by admins, eg. for static binding. The controller never deletes or deprovisions
+// an OB without the label unless the OB's claimRef names the deleted claim. By default, the label
+// is "objectbucket.io/created-by: controller".
+func WithCreatedByLabel(key, value string) Option {
+	return func(c *obcController) {
+		c.createdByKey = key
+		c.createdByValue = value
+	}
+}
+
+// WithOrphanReconcileOnStartup has Start clean up, once its caches have synced and before any
+// claim is processed, the OBs of the provisioner whose claim no longer exists, eg. because it was
+// deleted while the controller was down and its finalizer removed by hand. The bucket of each
+// orphaned OB is deleted or access to it revoked according to its reclaim policy, as for a
+// deleted claim, and the OB is deleted. By default, orphaned OBs are left as they are.
+func WithOrphanReconcileOnStartup() Option {
+	return func(c *obcController) {
+		c.reconcileOrphansOnStartup = true
+	}
+}
+
+// WithOrphanSelector restricts the OBs which WithOrphanReconcileOnStartup may clean up to those
+// matching the selector, on top of the provisioner check, eg. "env=staging", so that the OBs of
+// other environments sharing the cluster are never deleted. Passing nil restores the default. By
+// default, every orphaned OB of the provisioner is cleaned up.
+func WithOrphanSelector(selector labels.Selector) Option {
+	return func(c *obcController) {
+		if selector == nil {
+			selector = labels.Everything()
+		}
+		c.orphanSelector = selector
+	}
+}
+
+// WithUnboundResync periodically requeues all claims which are Pending or not yet phased, so that
+// claims left Pending by a transient problem which has since been resolved, eg. a storage class
+// created after the claim, are reconciled again without waiting for an event. Failed claims are
+// only retried when their spec is edited. By default, claims are only reconciled on events.
+func WithUnboundResync(interval time.Duration) Option {
+	return func(c *obcController) {
+		c.unboundResyncInterval = interval
+	}
+}
+
+// WithBucketExistenceCheck periodically verifies that the buckets of bound claims still exist, if the
+// provisioner implements api.BucketChecker. Every interval, the bucket of each bound claim is
+// checked, at most checksPerSecond checks being made so that the object store is not flooded. A
+// claim whose bucket is missing gets the BucketMissing condition and a BucketMissing warning event
+// and, if reprovision is true and its bucket was provisioned rather than granted, is provisioned
+// again. By default, no check is made.
+func WithBucketExistenceCheck(interval time.Duration, checksPerSecond float64, reprovision bool) Option {
+	return func(c *obcController) {
+		c.bucketCheckInterval = interval
+		c.bucketCheckLimiter = flowcontrol.NewTokenBucketRateLimiter(float32(checksPerSecond), 1)
+		c.reprovisionMissingBuckets = reprovision
+	}
+}
+
+// WithErrorClassifier decides with the given classifier how claims whose reconcile returned an
+// error are retried, eg. to give up on errors of the backend which retrying cannot resolve, or to
+// retry throttled requests after a fixed delay. Passing nil restores the default. By default,
+// claims are retried as decided by DefaultErrorClassifier.
+func WithErrorClassifier(classifier ErrorClassifier) Option {
+	return func(c *obcController) {
+		c.classifyError = classifier
+		if classifier == nil {
+			c.classifyError = DefaultErrorClassifier
+		}
+	}
+}
+
+// WithEventRecorder records the controller's events with the given recorder, eg. one created from the
+// embedder's own broadcaster to route the events elsewhere. Passing nil disables events, eg. on
+// clusters where they are too noisy. By default, events are written to the API server.
+func WithEventRecorder(recorder record.EventRecorder) Option {
+	return func(c *obcController) {
+		if recorder == nil {
+			recorder = discardRecorder{}
+		}
+		c.recorder = recorder
+	}
+}
+
+// WithLongNameHashing provisions claims whose generated OB name, or configmap and secret names in a
+// resource namespace, would exceed the maximum length of a name. Such names are truncated and
+// suffixed with a hash of the full name, which is the same on every reconcile. The names of the
+// configmap and secret are recorded in the claim's status, and the OB's name in its spec. By
+// default, such claims are rejected with an InvalidName event.
+func WithLongNameHashing() Option {
+	return func(c *obcController) {
+		c.hashLongNames = true
+	}
+}
+
+// WithConflictRetries retries updates of a claim or OB which conflict with a concurrent update up to
+// the given number of times, reading the latest version and applying the controller's change to it
+// again, so that a transient conflict does not requeue the whole reconcile. By default, a conflict
+// fails the reconcile, which is retried by the rate limited queue.
+func WithConflictRetries(retries int) Option {
+	return func(c *obcController) {
+		c.conflictRetries = retries
+	}
+}
+
+// WithShutdownSummary calls fn when Start stops with a summary of the claims managed by the
+// provisioner, by phase, and of the keys left unprocessed in the queue, eg. to report what a
+// rolling restart left behind. The summary is always logged. By default, it is only logged.
+func WithShutdownSummary(fn func(ShutdownSummary)) Option {
+	return func(c *obcController) {
+		c.shutdownSummary = fn
+	}
+}
+
+// WithStrictAdditionalConfig rejects claims whose additionalConfig does not match the schema of a
+// provisioner implementing api.AdditionalConfigValidator. Unbound claims are marked Failed, and
+// changes to bound claims are not applied. By default, mismatches are only reported by a warning
+// event.
+func WithStrictAdditionalConfig() Option {
+	return func(c *obcController) {
+		c.strictAdditionalConfig = true
+	}
+}
+
+// WithMaxQueueDepth applies backpressure to bursts of claim events. While depth claims are waiting
+// to be processed, further claims are queued only after the deferral, eg. 10s, counted by the
+// obc_queue_deferrals_total metric. Claims being deleted are exempt when WithDeletionPriority is
+// used. The queue only holds the keys of claims, each at most once, so this bounds how much work
+// is ready at once rather than the controller's memory as a whole, and deferred claims are
+// processed later than they otherwise would be. By default, the queue is unbounded.
+func WithMaxQueueDepth(depth int, deferral time.Duration) Option {
+	return func(c *obcController) {
+		c.maxQueueDepth = depth
+		c.queueDeferral = deferral
+	}
+}
+
+// WithEventCoalescing delays the claims queued by events, eg. of the claim, its configmap and
+// secret, or its storage class, by the given delay plus up to half as much jitter. The events of a
+// claim which arrive during the delay are merged into a single reconcile, reducing churn when
+// several sources change a claim at once, at the cost of that much latency. By default, claims are
+// queued as soon as their events arrive, subject to the workqueue's rate limiter.
+func WithEventCoalescing(delay time.Duration) Option {
+	return func(c *obcController) {
+		c.eventCoalescing = delay
+	}
+}
+
+// WithStorageClassWatch reconciles the bound claims of a storage class when its parameters or
+// reclaim policy change, typically by the class being deleted and recreated, so that new
+// non-destructive settings such as a default region are passed to Provision or Grant. A change of
+// the class's bucketName is not applied and a StorageClassChangeRejected warning event is
+// recorded instead, and a bound bucket keeps the reclaim policy of its OB unless
+// WithReclaimPolicySync is passed. By default, changes to storage classes only affect claims
+// provisioned afterwards.
+func WithStorageClassWatch() Option {
+	return func(c *obcController) {
+		c.watchStorageClasses = true
+	}
+}
+
+// WithReclaimPolicySync applies the reclaim policy of a claim's storage class, or of its
+// BucketClass which overrides it, to the OB of the bound claim when the policy has changed since the
+// claim was bound, and records a ReclaimPolicyChanged event on the claim. The change is applied the
+// next time the claim is reconciled, which WithStorageClassWatch triggers as soon as the class
+// changes. As a Retain policy changed to Delete causes the bucket to be deleted with its claim, by
+// default a bound OB keeps its reclaim policy.
+func WithReclaimPolicySync() Option {
+	return func(c *obcController) {
+		c.syncReclaimPolicy = true
+	}
+}
+
+// WithRequireTLSEndpoint fails claims whose bucket endpoint, as returned by Provision or Grant, does
+// not use TLS, rather than exposing an insecure endpoint. The new bucket is deleted, or access to
+// an existing bucket is revoked, and an InsecureEndpoint warning event is recorded. The StorageClass
+// parameter "requireTLSEndpoint" overrides this for claims of that class. By default, endpoints
+// are not checked.
+func WithRequireTLSEndpoint() Option {
+	return func(c *obcController) {
+		c.requireTLSEndpoint = true
+	}
+}
+
+// WithObjectBucketLabels adds labels to the OBs only, eg. for tooling which selects the
+// cluster-scoped OBs, without cluttering the OBCs, configmaps and secrets. Labels set by SetLabels
+// take precedence. If any key or value is invalid, an error is logged and none of the labels are
+// added. By default, OBs are labeled like the other resources.
+func WithObjectBucketLabels(labels map[string]string) Option {
+	return func(c *obcController) {
+		if errs := validateLabels(labels); len(errs) > 0 {
+			log.Error(nil, "ignoring invalid OB labels", "errors", errs)
+			return
+		}
+		c.obLabels = make(map[string]string, len(labels))
+		for k, v := range labels {
+			c.obLabels[k] = v
+		}
+	}
+}
+
+// WithBulkDeletion enables DeleteAllManaged, which deletes every OBC managed by the provisioner and
+// so may delete every bucket it has provisioned. By default, DeleteAllManaged returns an error.
+func WithBulkDeletion() Option {
+	return func(c *obcController) {
+		c.bulkDeletion = true
+	}
+}
+
+// WithConfigMapFirst creates the configmap of a claim before its secret, for applications which
+// expect the configmap to exist once the secret does. Both are still created before the OB. By
+// default, the secret is created first.
+func WithConfigMapFirst() Option {
+	return func(c *obcController) {
+		c.configMapFirst = true
+	}
+}
+
+// WithCredentialsValidation has the provisioner, if it implements api.CredentialsValidator, check
+// the credentials of each bucket before they are written to the claim's secret, failing the claim
+// with an InvalidCredentials event if they do not work. Bound claims are only given the event. By
+// default, credentials are not validated, since doing so adds a call to the object store to each
+// reconcile.
+func WithCredentialsValidation() Option {
+	return func(c *obcController) {
+		c.validateCredentials = true
+	}
+}
+
+// WithChildValidators checks the data of the secret and configmap of a claim with the given
+// validators before either is created, eg. to enforce the size limits or forbidden keys of the
+// cluster. If a validator returns an error, the bucket is deleted or revoked and the claim is
+// failed with a ChildValidationFailed event, rather than with an obscure API rejection. Bound
+// claims are left as they are. Validators are added to those of earlier calls. By default, no
+// validation is done.
+func WithChildValidators(validators ...ChildValidator) Option {
+	return func(c *obcController) {
+		c.childValidators = append(c.childValidators, validators...)
+	}
+}
+
+// WithChildrenDeletedFirst deletes the secret and configmap of a deleted claim before its OB, so
+// that the bucket's credentials are gone before the OB is, eg. for provisioners which watch OBs to
+// finish their teardown. By default, the OB is deleted first and the secret and configmap in the
+// claim's namespace are garbage collected with the claim.
+func WithChildrenDeletedFirst() Option {
+	return func(c *obcController) {
+		c.childrenDeletedFirst = true
+	}
+}
+
+// WithPreflight has Start call the provisioner's Preflight, if it implements api.Preflighter,
+// before processing any claim, so that a misconfigured provisioner fails at startup rather than on
+// the first claim. Start returns an error if the preflight fails or does not complete within the
+// timeout, unless the timeout is 0. The result is exported as the obc_preflight_succeeded metric.
+// By default, no preflight is run.
+func WithPreflight(timeout time.Duration) Option {
+	return func(c *obcController) {
+		c.preflight = true
+		c.preflightTimeout = timeout
+	}
+}
+
+// WithMetricsBindAddress serves the controller's Prometheus metrics at /metrics on the given
+// address, eg. ":8080", for standalone deployments. The server is started by Start, which fails if
+// the address cannot be listened on, and is shut down when the stop channel is closed or Start
+// fails. By default, no server is started and metrics are only registered with the default
+// Prometheus registry.
+func WithMetricsBindAddress(address string) Option {
+	return func(c *obcController) {
+		c.metricsBindAddress = address
+	}
+}
+
+// WithDebugHandlers has the server started by WithMetricsBindAddress also serve the net/http/pprof
+// profiles at /debug/pprof/ and, as JSON, the keys returned by ActiveWorkers at /debug/workers.
+// The profiles expose the process' command line and can be costly to collect, so the address
+// should not be reachable by untrusted clients. By default, only the metrics are served.
+func WithDebugHandlers() Option {
+	return func(c *obcController) {
+		c.debugHandlers = true
+	}
+}
+
+// WithClock sets the clock read for the age of claims, the times of their status conditions and
+// the interval between progress updates, eg. a fake clock for deterministic tests. By default, the
+// real clock is used.
+func WithClock(clock clock.Clock) Option {
+	return func(c *obcController) {
+		c.clock = clock
+	}
+}
+
+// WithPreviousProvisionerNames sets names under which the provisioner previously ran, to migrate
+// a renamed provisioner. OBCs of storage classes naming a previous provisioner are not
+// provisioned, but are cleaned up by the controller when they are deleted, so that their buckets
+// are deleted or revoked according to their reclaim policy. The provisioner's current name is
+// ignored. By default, OBCs of other provisioner names are ignored entirely.
+func WithPreviousProvisionerNames(names ...string) Option {
+	return func(c *obcController) {
+		c.previousProvisionerNames = make(map[string]bool, len(names))
+		for _, name := range names {
+			if name != c.provisionerName {
+				c.previousProvisionerNames[name] = true
+			}
+		}
+	}
+}
+
+// WithRedactedParameters redacts the values of the given storage class parameters, in addition to
+// those whose keys contain "secret", "password", "token" or "credential", from the effective
+// parameters logged at debug level before each call to Provision or Grant. By default, only the
+// parameters with such keys are redacted.
+func WithRedactedParameters(keys ...string) Option {
+	return func(c *obcController) {
+		c.redactedParameters = make(map[string]bool, len(keys))
+		for _, key := range keys {
+			c.redactedParameters[key] = true
+		}
+	}
+}
+
+// WithResourceNamespace writes the configmaps and secrets generated for claims to the given
+// namespace, eg. for centralized secret management, named "obc-<claim namespace>-<claim name>".
+// Since they cannot have an owner reference to a claim in another namespace, they are annotated
+// with the claim and deleted by the controller when the claim is deleted. Changing the namespace
+// leaves the resources of existing claims behind. By default, they are written to the claim's
+// namespace, named after the claim.
+func WithResourceNamespace(namespace string) Option {
+	return func(c *obcController) {
+		c.resourceNamespace = namespace
+	}
+}
+
+// WithCacheSyncTimeout bounds how long Start waits for the informers' caches to sync, eg. while
+// the API server is briefly unavailable. Each attempt times out after timeout, which doubles with
+// each of the given number of retries, after which Start returns an error. By default, Start waits
+// until the caches sync or the stop channel is closed.
+func WithCacheSyncTimeout(timeout time.Duration, retries int) Option {
+	return func(c *obcController) {
+		c.cacheSyncTimeout = timeout
+		c.cacheSyncRetries = retries
+	}
+}
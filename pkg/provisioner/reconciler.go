@@ -0,0 +1,205 @@
+/*
+Copyright 2019 Red Hat Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned"
+	listers "github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/listers/objectbucket.io/v1alpha1"
+	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api"
+	bucketerrors "github.com/kube-object-storage/lib-bucket-provisioner/pkg/provisioner/api/errors"
+)
+
+// Request names the ObjectBucketClaim to reconcile. It has the layout of controller-runtime's
+// reconcile.Request, so that one converts to the other, eg. provisioner.Request(req).
+type Request struct {
+	types.NamespacedName
+}
+
+// Result describes when a reconciled claim should be reconciled again. It has the layout of
+// controller-runtime's reconcile.Result, so that one converts to the other, eg.
+// reconcile.Result(result).
+type Result struct {
+	Requeue      bool
+	RequeueAfter time.Duration
+}
+
+// Reconciler reconciles ObjectBucketClaims on behalf of an external control loop, eg. a
+// controller-runtime manager, rather than the informers and workqueue run by Provisioner.Run.
+// The control loop is responsible for watching OBCs and for retrying failed requests.
+type Reconciler struct {
+	Name            string
+	Provisioner     api.Provisioner
+	claimController *obcController
+}
+
+// NewReconciler returns a Reconciler which syncs the claims of the given provisioner. Like
+// NewProvisioner, it is restricted to the given namespace, or to all namespaces if empty. Options
+// which configure the controller's workqueue or informers, eg. WithDeletionPriority,
+// WithStuckClaimBackoff, WithNamespaceDeletionRetries, WithReconcileChildren and
+// WithStuckPendingCheck, have no effect, since neither is run. Claims and OBs which a reconcile
+// looks up besides its own, eg. for the maxClaims and bucketNameCollision parameters, are read
+// from the API server.
+func NewReconciler(
+	cfg *rest.Config,
+	provisionerName string,
+	provisioner api.Provisioner,
+	namespace string,
+	options ...Option,
+) (*Reconciler, error) {
+
+	initLoggers()
+
+	libClientset := versioned.NewForConfigOrDie(cfg)
+	clientset := kubernetes.NewForConfigOrDie(cfg)
+
+	// the informers are never started, they only satisfy the controller's constructor, and their
+	// listers are replaced by ones reading from the API server
+	informerFactory := setupInformerFactory(libClientset, 0, namespace)
+	options = append([]Option{inNamespace(namespace), withLiveListers(libClientset, namespace)}, options...)
+
+	return &Reconciler{
+		Name:        provisionerName,
+		Provisioner: provisioner,
+		claimController: NewController(
+			provisionerName,
+			provisioner,
+			clientset,
+			libClientset,
+			informerFactory.Objectbucket().V1alpha1().ObjectBucketClaims(),
+			informerFactory.Objectbucket().V1alpha1().ObjectBuckets(),
+			options...),
+	}, nil
+}
+
+// SetLabels allows provisioner author to provide their own resource labels.  They will be set on all
+// managed resources by the provisioner (OBC, OB, CM, Secret)
+func (r *Reconciler) SetLabels(labels map[string]string) []string {
+	return setLabels(r.claimController, labels)
+}
+
+// Reconcile syncs the claim named by the request. An error is returned if the claim should be
+// retried, eg. with the control loop's rate limiter. Claims of other provisioners are ignored.
+func (r *Reconciler) Reconcile(ctx context.Context, req Request) (Result, error) {
+	return r.claimController.reconcile(req)
+}
+
+// reconcile syncs the claim named by the request in place of a worker taking it from the queue.
+func (c *obcController) reconcile(req Request) (Result, error) {
+	key := namespacedKey(req.Namespace, req.Name)
+	setLoggersWithRequest(key)
+	logD.Info("reconciling claim")
+
+	outcome, err := c.syncClaim(key, key)
+	if after, ok := bucketerrors.RequeueAfter(err); ok {
+		log.Info("provisioner asked to requeue claim", "requeueAfter", after, "reason", err.Error())
+		recordReconcile(outcomeRequeuedTransient)
+		return Result{RequeueAfter: after}, nil
+	}
+	if ferr, ok := asForbiddenError(err); ok {
+		after := c.forbiddenBackoff.When(key)
+		log.Info("resource creation forbidden, requeuing claim", "requeueAfter", after, "reason", ferr.Error())
+		recordReconcile(outcomeRequeuedTransient)
+		return Result{RequeueAfter: after}, nil
+	}
+	c.forbiddenBackoff.Forget(key)
+	if err != nil {
+		recordReconcile(outcomeRequeuedTransient)
+		return Result{}, err
+	}
+	recordReconcile(outcome)
+	return Result{}, nil
+}
+
+// withLiveListers replaces the listers of the controller's informers by listers reading from the
+// API server, for controllers whose informers are not run.
+func withLiveListers(libClientset versioned.Interface, namespace string) Option {
+	return func(c *obcController) {
+		c.obcLister = liveClaimLister{libClientset: libClientset, namespace: namespace}
+		c.obLister = liveBucketLister{libClientset: libClientset}
+	}
+}
+
+// liveClaimLister implements listers.ObjectBucketClaimLister by listing the claims of the namespace,
+// or of all namespaces if empty, from the API server.
+type liveClaimLister struct {
+	libClientset versioned.Interface
+	namespace    string
+}
+
+var _ listers.ObjectBucketClaimLister = liveClaimLister{}
+
+func (l liveClaimLister) List(selector labels.Selector) ([]*v1alpha1.ObjectBucketClaim, error) {
+	return l.ObjectBucketClaims(l.namespace).List(selector)
+}
+
+func (l liveClaimLister) ObjectBucketClaims(namespace string) listers.ObjectBucketClaimNamespaceLister {
+	return liveClaimNamespaceLister{libClientset: l.libClientset, namespace: namespace}
+}
+
+type liveClaimNamespaceLister struct {
+	libClientset versioned.Interface
+	namespace    string
+}
+
+func (l liveClaimNamespaceLister) List(selector labels.Selector) ([]*v1alpha1.ObjectBucketClaim, error) {
+	list, err := l.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(l.namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	obcs := make([]*v1alpha1.ObjectBucketClaim, 0, len(list.Items))
+	for i := range list.Items {
+		obcs = append(obcs, &list.Items[i])
+	}
+	return obcs, nil
+}
+
+func (l liveClaimNamespaceLister) Get(name string) (*v1alpha1.ObjectBucketClaim, error) {
+	return l.libClientset.ObjectbucketV1alpha1().ObjectBucketClaims(l.namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+// liveBucketLister implements listers.ObjectBucketLister by reading OBs from the API server.
+type liveBucketLister struct {
+	libClientset versioned.Interface
+}
+
+var _ listers.ObjectBucketLister = liveBucketLister{}
+
+func (l liveBucketLister) List(selector labels.Selector) ([]*v1alpha1.ObjectBucket, error) {
+	list, err := l.libClientset.ObjectbucketV1alpha1().ObjectBuckets().List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	obs := make([]*v1alpha1.ObjectBucket, 0, len(list.Items))
+	for i := range list.Items {
+		obs = append(obs, &list.Items[i])
+	}
+	return obs, nil
+}
+
+func (l liveBucketLister) Get(name string) (*v1alpha1.ObjectBucket, error) {
+	return l.libClientset.ObjectbucketV1alpha1().ObjectBuckets().Get(context.TODO(), name, metav1.GetOptions{})
+}
@@ -18,15 +18,23 @@ package provisioner
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"k8s.io/client-go/kubernetes"
 
 	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/clock"
 
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/apis/objectbucket.io/v1alpha1"
 	"github.com/kube-object-storage/lib-bucket-provisioner/pkg/client/clientset/versioned"
@@ -39,9 +47,13 @@ const (
 	// defaultRetryTimeout defines how long in total to try to create an API object before ending the reconciliation
 	// attempt
 	defaultRetryTimeout = time.Second * 30
+	// defaultProgressInterval limits how often the progress reported by a provisioner is recorded
+	// in the status of a claim
+	defaultProgressInterval = time.Second * 5
 
 	bucketName      = "BUCKET_NAME"
 	bucketHost      = "BUCKET_HOST"
+	bucketHosts     = "BUCKET_HOSTS"
 	bucketPort      = "BUCKET_PORT"
 	bucketRegion    = "BUCKET_REGION"
 	bucketSubRegion = "BUCKET_SUBREGION"
@@ -50,12 +62,41 @@ const (
 	// label applied to all resources generated by the provisioner and to the obc
 	provisionerLabelKey    = "bucket-provisioner"
 	objectBucketNameFormat = "obc-%s-%s"
+	// generatedNameHashLength is the length of the hash suffixing generated names which are too long
+	generatedNameHashLength = 16
+	// annotation applied to all resources generated by the provisioner and to the obc when the
+	// provisioner's version is known
+	provisionerVersionAnnotation = api.Domain + "/provisioner-version"
+	// annotation recording the storage class of the claim on an OB whose storage class was set by
+	// the provisioner
+	claimStorageClassAnnotation = api.Domain + "/claim-storage-class"
+	// label applied to the OBs created by the controller, distinguishing them from OBs created by
+	// admins, eg. for static binding, unless configured otherwise
+	createdByLabelKey   = api.Domain + "/created-by"
+	createdByLabelValue = "controller"
+	// annotation recording on an OB the name of the provisioner which provisioned its bucket, which
+	// decides the controller cleaning up the bucket even if the storage class is edited
+	provisionerAnnotation = api.Domain + "/provisioner"
+	// annotation recording the namespace/name of the claim on a generated configmap or secret in
+	// another namespace, which cannot have an owner reference to the claim
+	claimAnnotation = api.Domain + "/claim"
+	// annotation recording on an OB that the provisioner's Delete or Revoke succeeded, holding
+	// the outcome
+	deprovisionedAnnotation = api.Domain + "/deprovisioned"
+	// annotation recording, as JSON, the additionalConfig keys changed by the last update applied
+	// to the claim's bucket
+	lastConfigDiffAnnotation = api.Domain + "/last-config-diff"
+	// annotations marking the cluster's default storage class
+	isDefaultStorageClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaIsDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
 )
 
 // newBucketConfigMap returns a config map from a given endpoint and ObjectBucketClaim.
 // A finalizer is added to reduce chances of the CM being accidentally deleted. An OwnerReference
 // is added so that the CM is automatically garbage collected when the parent OBC is deleted.
-func newBucketConfigMap(obc *v1alpha1.ObjectBucketClaim, ep *v1alpha1.Endpoint, labels map[string]string) (*corev1.ConfigMap, error) {
+// Any extra data supplied by the provisioner is added to, and takes precedence over, the data
+// derived from the endpoint.
+func newBucketConfigMap(obc *v1alpha1.ObjectBucketClaim, ep *v1alpha1.Endpoint, extraData, labels, annotations map[string]string) (*corev1.ConfigMap, error) {
 	if ep == nil {
 		return nil, fmt.Errorf("cannot construct configMap, got nil Endpoint")
 	}
@@ -63,12 +104,13 @@ func newBucketConfigMap(obc *v1alpha1.ObjectBucketClaim, ep *v1alpha1.Endpoint,
 		return nil, fmt.Errorf("cannot construct configMap, got nil OBC")
 	}
 
-	return &corev1.ConfigMap{
+	configMap := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:       composeConfigMapName(obc),
-			Namespace:  obc.Namespace,
-			Finalizers: []string{finalizer},
-			Labels:     labels,
+			Name:        composeConfigMapName(obc),
+			Namespace:   obc.Namespace,
+			Finalizers:  []string{finalizer},
+			Labels:      labels,
+			Annotations: annotations,
 			OwnerReferences: []metav1.OwnerReference{
 				makeOwnerReference(obc),
 			},
@@ -80,7 +122,23 @@ func newBucketConfigMap(obc *v1alpha1.ObjectBucketClaim, ep *v1alpha1.Endpoint,
 			bucketRegion:    ep.Region,
 			bucketSubRegion: ep.SubRegion,
 		},
-	}, nil
+	}
+	// clients address the bucket by its client-facing name, if the backend presents one
+	if ep.ClientBucketName != "" {
+		configMap.Data[bucketName] = ep.ClientBucketName
+	}
+	// BUCKET_HOST remains the primary host so that existing clients are unaffected
+	if len(ep.AdditionalBucketHosts) > 0 {
+		hosts := append([]string{ep.BucketHost}, ep.AdditionalBucketHosts...)
+		configMap.Data[bucketHosts] = strings.Join(hosts, ",")
+	}
+	for k, v := range extraData {
+		if errs := validation.IsConfigMapKey(k); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid configMap key %q: %s", k, strings.Join(errs, ", "))
+		}
+		configMap.Data[k] = v
+	}
+	return configMap, nil
 }
 
 // newCredentialsSecret returns a secret with data appropriate to the supported authenticaion
@@ -88,7 +146,7 @@ func newBucketConfigMap(obc *v1alpha1.ObjectBucketClaim, ep *v1alpha1.Endpoint,
 // A finalizer is added to reduce chances of the secret being accidentally deleted.
 // An OwnerReference is added so that the secret is automatically garbage collected when the
 // parent OBC is deleted.
-func newCredentialsSecret(obc *v1alpha1.ObjectBucketClaim, auth *v1alpha1.Authentication, labels map[string]string) (*corev1.Secret, error) {
+func newCredentialsSecret(obc *v1alpha1.ObjectBucketClaim, auth *v1alpha1.Authentication, labels, annotations map[string]string) (*corev1.Secret, error) {
 	if obc == nil {
 		return nil, fmt.Errorf("ObjectBucketClaim required to generate secret")
 	}
@@ -98,10 +156,11 @@ func newCredentialsSecret(obc *v1alpha1.ObjectBucketClaim, auth *v1alpha1.Authen
 
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:       composeSecretName(obc),
-			Namespace:  obc.Namespace,
-			Finalizers: []string{finalizer},
-			Labels:     labels,
+			Name:        composeSecretName(obc),
+			Namespace:   obc.Namespace,
+			Finalizers:  []string{finalizer},
+			Labels:      labels,
+			Annotations: annotations,
 			OwnerReferences: []metav1.OwnerReference{
 				makeOwnerReference(obc),
 			},
@@ -124,6 +183,10 @@ func createOrUpdateObjectBucket(ob *v1alpha1.ObjectBucket, c versioned.Interface
 			if err != nil {
 				return ob, fmt.Errorf("failed to update OB %s: failed to get current version of OB: %v", ob.Name, err)
 			}
+			if objectBucketUnchanged(currentOB, ob) {
+				logD.Info("ObjectBucket is up to date", "name", ob.Name)
+				return currentOB, nil
+			}
 			ob.ResourceVersion = currentOB.ResourceVersion // this must be set for updates
 			result, err = c.ObjectbucketV1alpha1().ObjectBuckets().Update(context.TODO(), ob, metav1.UpdateOptions{})
 			if err != nil {
@@ -139,17 +202,67 @@ func createOrUpdateObjectBucket(ob *v1alpha1.ObjectBucket, c versioned.Interface
 	return result, err
 }
 
-func createOrUpdateSecret(obc *v1alpha1.ObjectBucketClaim, auth *v1alpha1.Authentication, labels map[string]string, c kubernetes.Interface) error {
-	secret, err := newCredentialsSecret(obc, auth, labels)
+// objectBucketUnchanged returns whether updating the current OB with the desired one would leave
+// it as it is, in which case the update is skipped to save an API write.
+func objectBucketUnchanged(current, desired *v1alpha1.ObjectBucket) bool {
+	desiredSpec := desired.Spec.DeepCopy()
+	if desiredSpec.Connection != nil && current.Spec.Connection != nil {
+		// the credentials are not stored in the OB, so cannot be compared
+		desiredSpec.Connection.Authentication = current.Spec.Connection.Authentication
+	}
+	return apiequality.Semantic.DeepEqual(current.Spec, *desiredSpec) &&
+		apiequality.Semantic.DeepEqual(current.Labels, desired.Labels) &&
+		apiequality.Semantic.DeepEqual(current.Annotations, desired.Annotations) &&
+		apiequality.Semantic.DeepEqual(current.Finalizers, desired.Finalizers)
+}
+
+// unownedChildren returns the configmap and secret to be generated for the claim under the child
+// key which already exist but are not owned by it, eg. because a user created them. They must not
+// be overwritten.
+func unownedChildren(obc *v1alpha1.ObjectBucketClaim, childKey string, c kubernetes.Interface) ([]string, error) {
+	var unowned []string
+	configMap, err := configMapForClaimKey(childKey, c)
+	if err == nil && !childIsOwnedByClaim(obc, configMap) {
+		unowned = append(unowned, "configmap "+configMap.Namespace+"/"+configMap.Name)
+	} else if err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get configmap for obc %q: %v", obc.Name, err)
+	}
+	secret, err := secretForClaimKey(childKey, c)
+	if err == nil && !childIsOwnedByClaim(obc, secret) {
+		unowned = append(unowned, "secret "+secret.Namespace+"/"+secret.Name)
+	} else if err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get secret for obc %q: %v", obc.Name, err)
+	}
+	return unowned, nil
+}
+
+func createOrUpdateSecret(obc *v1alpha1.ObjectBucketClaim, auth *v1alpha1.Authentication, labels, annotations map[string]string, childKey string, c kubernetes.Interface) error {
+	secret, err := newCredentialsSecret(obc, auth, labels, annotations)
 	if err != nil {
 		return err
 	}
+	if err = placeChild(secret, obc, childKey); err != nil {
+		return err
+	}
 	logD.Info("creating Secret", "name", secret.Namespace+"/"+secret.Name)
-	_, err = c.CoreV1().Secrets(obc.Namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+	_, err = c.CoreV1().Secrets(secret.Namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
 	if err != nil {
 		if errors.IsAlreadyExists(err) {
 			logD.Info("updating Secret", "name", secret.Namespace+"/"+secret.Name)
-			_, err = c.CoreV1().Secrets(obc.Namespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+			var current *corev1.Secret
+			current, err = c.CoreV1().Secrets(secret.Namespace).Get(context.TODO(), secret.Name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to get secret %q for obc %q: %v", secret.Namespace+"/"+secret.Name, obc.Name, err)
+			}
+			if !childIsOwnedByClaim(obc, current) {
+				return fmt.Errorf("secret %q exists and is not owned by obc %q", secret.Namespace+"/"+secret.Name, obc.Name)
+			}
+			// Restore the generated data, removing any other keys, but preserve metadata which may
+			// have been added by users.
+			mergeGeneratedMeta(current, secret)
+			current.Data = nil
+			current.StringData = secret.StringData
+			_, err = c.CoreV1().Secrets(secret.Namespace).Update(context.TODO(), current, metav1.UpdateOptions{})
 			if err != nil {
 				return fmt.Errorf("failed to update secret %q for obc %q", secret.Namespace+"/"+secret.Name, obc.Name)
 			}
@@ -158,18 +271,33 @@ func createOrUpdateSecret(obc *v1alpha1.ObjectBucketClaim, auth *v1alpha1.Authen
 	return err
 }
 
-func createOrUpdateConfigMap(obc *v1alpha1.ObjectBucketClaim, ep *v1alpha1.Endpoint, labels map[string]string, c kubernetes.Interface) error {
-	configMap, err := newBucketConfigMap(obc, ep, labels)
+func createOrUpdateConfigMap(obc *v1alpha1.ObjectBucketClaim, ep *v1alpha1.Endpoint, extraData, labels, annotations map[string]string, childKey string, c kubernetes.Interface) error {
+	configMap, err := newBucketConfigMap(obc, ep, extraData, labels, annotations)
 	if err != nil {
 		return err
 	}
+	if err = placeChild(configMap, obc, childKey); err != nil {
+		return err
+	}
 
 	logD.Info("creating ConfigMap", "name", configMap.Namespace+"/"+configMap.Name)
-	_, err = c.CoreV1().ConfigMaps(obc.Namespace).Create(context.TODO(), configMap, metav1.CreateOptions{})
+	_, err = c.CoreV1().ConfigMaps(configMap.Namespace).Create(context.TODO(), configMap, metav1.CreateOptions{})
 	if err != nil {
 		if errors.IsAlreadyExists(err) {
 			logD.Info("updating ConfigMap", "name", configMap.Namespace+"/"+configMap.Name)
-			_, err = c.CoreV1().ConfigMaps(obc.Namespace).Update(context.TODO(), configMap, metav1.UpdateOptions{})
+			var current *corev1.ConfigMap
+			current, err = c.CoreV1().ConfigMaps(configMap.Namespace).Get(context.TODO(), configMap.Name, metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to get configmap %q for obc %q: %v", configMap.Namespace+"/"+configMap.Name, obc.Name, err)
+			}
+			if !childIsOwnedByClaim(obc, current) {
+				return fmt.Errorf("configmap %q exists and is not owned by obc %q", configMap.Namespace+"/"+configMap.Name, obc.Name)
+			}
+			// Restore the generated data, removing any other keys, but preserve metadata which may
+			// have been added by users.
+			mergeGeneratedMeta(current, configMap)
+			current.Data = configMap.Data
+			_, err = c.CoreV1().ConfigMaps(configMap.Namespace).Update(context.TODO(), current, metav1.UpdateOptions{})
 			if err != nil {
 				return fmt.Errorf("failed to update configmap %q for obc %q", configMap.Namespace+"/"+configMap.Name, obc.Name)
 			}
@@ -199,6 +327,46 @@ func releaseConfigMap(cm *corev1.ConfigMap, c kubernetes.Interface) (err error)
 	return nil
 }
 
+// orphanConfigMap removes the finalizer and the claim's owner reference from the configmap, so that
+// it is kept once the claim is deleted.
+func orphanConfigMap(cm *corev1.ConfigMap, obc *v1alpha1.ObjectBucketClaim, c kubernetes.Interface) (err error) {
+	if cm == nil {
+		logD.Info("got nil configmap, skipping")
+		return nil
+	}
+	cm, err = c.CoreV1().ConfigMaps(cm.Namespace).Get(context.TODO(), cm.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	logD.Info("orphaning configmap")
+	removeFinalizer(cm)
+	var owners []metav1.OwnerReference
+	for _, ref := range cm.OwnerReferences {
+		if !objectIsOwnedByClaim(obc, []metav1.OwnerReference{ref}) {
+			owners = append(owners, ref)
+		}
+	}
+	cm.OwnerReferences = owners
+	delete(cm.Annotations, claimAnnotation)
+	_, err = c.CoreV1().ConfigMaps(cm.Namespace).Update(context.TODO(), cm, metav1.UpdateOptions{})
+	return err
+}
+
+// deleteConfigMap deletes a configmap of the claim which is not garbage collected with the claim,
+// since it is in another namespace.
+func deleteConfigMap(cm *corev1.ConfigMap, c kubernetes.Interface) error {
+	if cm == nil {
+		logD.Info("got nil configmap, skipping")
+		return nil
+	}
+	logD.Info("deleting configmap", "name", cm.Namespace+"/"+cm.Name)
+	err := c.CoreV1().ConfigMaps(cm.Namespace).Delete(context.TODO(), cm.Name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
 // Only the finalizer needs to be removed. The Secret will be garbage collected since its
 // ownerReference refers to the parent OBC.
 func releaseSecret(sec *corev1.Secret, c kubernetes.Interface) (err error) {
@@ -220,26 +388,66 @@ func releaseSecret(sec *corev1.Secret, c kubernetes.Interface) (err error) {
 	return nil
 }
 
-// Remove the finalizer allowing the OBC to finally be deleted.
-func releaseOBC(obc *v1alpha1.ObjectBucketClaim, c versioned.Interface) (err error) {
+// deleteSecret deletes a secret of the claim which is not garbage collected with the claim, since
+// it is in another namespace.
+func deleteSecret(sec *corev1.Secret, c kubernetes.Interface) error {
+	if sec == nil {
+		logD.Info("got nil secret, skipping")
+		return nil
+	}
+	logD.Info("deleting secret", "name", sec.Namespace+"/"+sec.Name)
+	err := c.CoreV1().Secrets(sec.Namespace).Delete(context.TODO(), sec.Name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// Remove the finalizer allowing the OBC to finally be deleted. The OBC is read again and the
+// update retried when it conflicts with a concurrent update of the OBC. An OBC which is gone or
+// no longer has the finalizer is left as is.
+func releaseOBC(obc *v1alpha1.ObjectBucketClaim, c versioned.Interface) error {
 	if obc == nil {
 		logD.Info("got nil obc, skipping")
 		return nil
 	}
 	obcNsName := obc.Namespace + "/" + obc.Name
-	obc, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Get(context.TODO(), obc.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		finalizers := len(current.Finalizers)
+		removeFinalizer(current)
+		if len(current.Finalizers) == finalizers {
+			return nil
+		}
+		logD.Info("removing obc finalizer")
+		_, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Update(context.TODO(), current, metav1.UpdateOptions{})
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("unable to Get obc %q in order to remove finalizer: %v", obcNsName, err)
+		return fmt.Errorf("unable to remove finalizer of obc %q: %v", obcNsName, err)
 	}
-	logD.Info("removing obc finalizer")
-	removeFinalizer(obc)
+	return nil
+}
 
-	obc, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Update(context.TODO(), obc, metav1.UpdateOptions{})
+// markDeprovisioned annotates the OB with the outcome of the provisioner's Delete or Revoke, so
+// that the backend is not called again should the cleanup of the OB's resources be retried. The
+// input ob is returned on error.
+func markDeprovisioned(c versioned.Interface, ob *v1alpha1.ObjectBucket, outcome reconcileOutcome) (*v1alpha1.ObjectBucket, error) {
+	updateOB := ob.DeepCopy()
+	if updateOB.Annotations == nil {
+		updateOB.Annotations = make(map[string]string)
+	}
+	updateOB.Annotations[deprovisionedAnnotation] = string(outcome)
+	result, err := c.ObjectbucketV1alpha1().ObjectBuckets().Update(context.TODO(), updateOB, metav1.UpdateOptions{})
 	if err != nil {
-		return fmt.Errorf("unable to Update obc %q to reflect removed finalizer: %v", obcNsName, err)
+		return ob, fmt.Errorf("failed to mark OB %s as deprovisioned: %v", ob.Name, err)
 	}
-
-	return nil
+	return result, nil
 }
 
 // The OB does not have an ownerReference and must be explicitly deleted after its
@@ -278,11 +486,58 @@ func updateClaim(c versioned.Interface, obc *v1alpha1.ObjectBucketClaim) (result
 	result, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Update(context.TODO(), obc, metav1.UpdateOptions{})
 	if err != nil {
 		// return input obc here since result is nil on error returns
-		return obc, fmt.Errorf("failed to update OBC %s/%s: %v", obc.Namespace, obc.Name, err)
+		return obc, fmt.Errorf("failed to update OBC %s/%s: %w", obc.Namespace, obc.Name, err)
 	}
 	return result, err
 }
 
+// patchClaimMetadata adds the given labels, annotations and finalizers to the claim with a merge
+// patch, so that metadata set on the claim by others since it was read is preserved. The claim is
+// not patched if it already has them.
+func patchClaimMetadata(c versioned.Interface, obc *v1alpha1.ObjectBucketClaim, labels, annotations map[string]string, finalizers []string) (*v1alpha1.ObjectBucketClaim, error) {
+	metadata := make(map[string]interface{})
+	if changed := changedEntries(obc.Labels, labels); len(changed) > 0 {
+		metadata["labels"] = changed
+	}
+	if changed := changedEntries(obc.Annotations, annotations); len(changed) > 0 {
+		metadata["annotations"] = changed
+	}
+	updateOBC := obc.DeepCopy()
+	addFinalizers(updateOBC, finalizers)
+	if len(updateOBC.Finalizers) != len(obc.Finalizers) {
+		// a merge patch replaces lists as a whole, so the resourceVersion guards against dropping
+		// a finalizer added since the claim was read
+		metadata["finalizers"] = updateOBC.Finalizers
+		metadata["resourceVersion"] = obc.ResourceVersion
+	}
+	if len(metadata) == 0 {
+		return obc, nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+	if err != nil {
+		return obc, fmt.Errorf("failed to encode patch for OBC %s/%s: %v", obc.Namespace, obc.Name, err)
+	}
+	logD.Info("patching metadata", "obc", obc.Namespace+"/"+obc.Name, "patch", string(patch))
+	result, err := c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).Patch(context.TODO(), obc.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		// return input obc here since result is nil on error returns
+		return obc, fmt.Errorf("failed to patch OBC %s/%s: %v", obc.Namespace, obc.Name, err)
+	}
+	return result, nil
+}
+
+// bucketClassGetter returns a func getting BucketClasses through the REST client of the given
+// clientset, as BucketClass is not marked for client generation. BucketClasses are not watched, so
+// a change only applies to a claim when it is next synced.
+func bucketClassGetter(c versioned.Interface) func(name string) (*v1alpha1.BucketClass, error) {
+	return func(name string) (*v1alpha1.BucketClass, error) {
+		bucketClass := &v1alpha1.BucketClass{}
+		err := c.ObjectbucketV1alpha1().RESTClient().Get().Resource("bucketclasses").Name(name).Do(context.TODO()).Into(bucketClass)
+		return bucketClass, err
+	}
+}
+
 func updateObjectBucketClaimPhase(c versioned.Interface, obc *v1alpha1.ObjectBucketClaim, phase v1alpha1.ObjectBucketClaimStatusPhase) (result *v1alpha1.ObjectBucketClaim, err error) {
 	logD.Info("updating status:", "obc", obc.Namespace+"/"+obc.Name, "old status",
 		obc.Status.Phase, "new status", phase)
@@ -295,11 +550,79 @@ func updateObjectBucketClaimPhase(c versioned.Interface, obc *v1alpha1.ObjectBuc
 	result, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).UpdateStatus(context.TODO(), updateOBC, metav1.UpdateOptions{})
 	if err != nil {
 		// return input obc here since result is nil on error returns
-		return obc, fmt.Errorf("failed to update OBC %s/%s phase to %q: %v", obc.Namespace, obc.Name, phase, err)
+		return obc, fmt.Errorf("failed to update OBC %s/%s phase to %q: %w", obc.Namespace, obc.Name, phase, err)
+	}
+	return result, err
+}
+
+// updateObjectBucketClaimProgress records the reason and message describing what the controller is
+// waiting on for a claim which is not yet provisioned. The claim is not updated once it has moved
+// past Pending, or if the reason and message are unchanged.
+func updateObjectBucketClaimProgress(c versioned.Interface, obc *v1alpha1.ObjectBucketClaim, reason, message string) (result *v1alpha1.ObjectBucketClaim, err error) {
+	if obc.Status.Phase != "" && obc.Status.Phase != v1alpha1.ObjectBucketClaimStatusPhasePending {
+		return obc, nil
+	}
+	if obc.Status.Reason == reason && obc.Status.Message == message {
+		return obc, nil
+	}
+	logD.Info("updating status:", "obc", obc.Namespace+"/"+obc.Name, "reason", reason, "message", message)
+	updateOBC := obc.DeepCopy()
+	updateOBC.Status.Reason = reason
+	updateOBC.Status.Message = message
+
+	result, err = c.ObjectbucketV1alpha1().ObjectBucketClaims(obc.Namespace).UpdateStatus(context.TODO(), updateOBC, metav1.UpdateOptions{})
+	if err != nil {
+		// return input obc here since result is nil on error returns
+		return obc, fmt.Errorf("failed to update OBC %s/%s status reason to %q: %v", obc.Namespace, obc.Name, reason, err)
 	}
 	return result, err
 }
 
+// progressReporter records the steps reported by a provisioner during a Provision or Grant call in
+// the message of the claim's status, at most once per interval. The provisioner may report steps
+// from any goroutine, including after the call has timed out.
+type progressReporter struct {
+	client   versioned.Interface
+	interval time.Duration
+	clock    clock.PassiveClock
+	// lock serializes updates of the claim and guards the fields below
+	lock sync.Mutex
+	// obc is the latest version of the claim
+	obc     *v1alpha1.ObjectBucketClaim
+	last    time.Time
+	stopped bool
+}
+
+func newProgressReporter(c versioned.Interface, obc *v1alpha1.ObjectBucketClaim, interval time.Duration, clock clock.PassiveClock) *progressReporter {
+	return &progressReporter{client: c, obc: obc, interval: interval, clock: clock}
+}
+
+// report records message in the claim's status, unless a step was recorded within the interval or
+// the reporter is stopped. Errors are logged rather than failing the provisioner's call.
+func (r *progressReporter) report(message string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.stopped || r.clock.Since(r.last) < r.interval {
+		return
+	}
+	r.last = r.clock.Now()
+	obc, err := updateObjectBucketClaimProgress(r.client, r.obc, r.obc.Status.Reason, message)
+	if err != nil {
+		log.Error(err, "error recording provisioner progress")
+		return
+	}
+	r.obc = obc
+}
+
+// stop ignores steps reported from now on, and returns the latest version of the claim, which must
+// be used in place of the claim given to newProgressReporter.
+func (r *progressReporter) stop() *v1alpha1.ObjectBucketClaim {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stopped = true
+	return r.obc
+}
+
 func updateObjectBucketPhase(c versioned.Interface, ob *v1alpha1.ObjectBucket, phase v1alpha1.ObjectBucketStatusPhase) (result *v1alpha1.ObjectBucket, err error) {
 	logD.Info("updating status:", "ob", ob.Name, "old status", ob.Status.Phase, "new status", phase)
 	// Do not make changes directly to the ob used as input. If the update fails, we should return
@@ -311,7 +634,7 @@ func updateObjectBucketPhase(c versioned.Interface, ob *v1alpha1.ObjectBucket, p
 	result, err = c.ObjectbucketV1alpha1().ObjectBuckets().UpdateStatus(context.TODO(), updateOB, metav1.UpdateOptions{})
 	if err != nil {
 		// return input ob here since result is nil on error returns
-		return ob, fmt.Errorf("failed to update OB %s phase to %q: %v", ob.Name, phase, err)
+		return ob, fmt.Errorf("failed to update OB %s phase to %q: %w", ob.Name, phase, err)
 	}
 	return result, err
 }
@@ -344,11 +667,12 @@ func errIfObcConfigHasBeenModified(ob *v1alpha1.ObjectBucket, obc *v1alpha1.Obje
 		return nil
 	}
 
+	// the claim records the bucket's name in the object store, not its ClientBucketName
 	if obc.Spec.BucketName != ob.Spec.Endpoint.BucketName {
 		return fmt.Errorf("obc %q bucketName has changed compared to ob %q", obc.Name, ob.Name)
 	}
 	// actually don't care if generateBucketName changes since the bucketName is what really matters
-	if obc.Spec.StorageClassName != ob.Spec.StorageClassName {
+	if obc.Spec.StorageClassName != claimStorageClassName(ob) {
 		return fmt.Errorf("obc %q storageClassName has changed compared to ob %q", obc.Name, ob.Name)
 	}
 
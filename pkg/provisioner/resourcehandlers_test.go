@@ -133,7 +133,7 @@ func TestNewCredentialsSecret(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := newCredentialsSecret(tt.args.obc, tt.args.authentication, dummyLabels)
+			got, err := newCredentialsSecret(tt.args.obc, tt.args.authentication, dummyLabels, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewCredentailsSecret() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -176,8 +176,9 @@ func TestNewBucketConfigMap(t *testing.T) {
 	}
 
 	type args struct {
-		ep  *v1alpha1.Endpoint
-		obc *v1alpha1.ObjectBucketClaim
+		ep   *v1alpha1.Endpoint
+		obc  *v1alpha1.ObjectBucketClaim
+		data map[string]string
 	}
 	tests := []struct {
 		name    string
@@ -272,10 +273,122 @@ func TestNewBucketConfigMap(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "with multiple endpoints",
+			args: args{
+				ep: &v1alpha1.Endpoint{
+					BucketHost:            host,
+					BucketPort:            port,
+					BucketName:            name,
+					AdditionalBucketHosts: []string{"http://www2.test.com", "http://www3.test.com"},
+				},
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: objMeta,
+					Spec: v1alpha1.ObjectBucketClaimSpec{
+						BucketName: name,
+					},
+				},
+			},
+			want: &corev1.ConfigMap{
+				ObjectMeta: objMeta,
+				Data: map[string]string{
+					bucketName:      name,
+					bucketHost:      host,
+					bucketHosts:     host + ",http://www2.test.com,http://www3.test.com",
+					bucketPort:      strconv.Itoa(port),
+					bucketRegion:    "",
+					bucketSubRegion: "",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with client bucket name",
+			args: args{
+				ep: &v1alpha1.Endpoint{
+					BucketHost:       host,
+					BucketPort:       port,
+					BucketName:       "tenant-a-" + name,
+					ClientBucketName: name,
+				},
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: objMeta,
+					Spec: v1alpha1.ObjectBucketClaimSpec{
+						BucketName: name,
+					},
+				},
+			},
+			want: &corev1.ConfigMap{
+				ObjectMeta: objMeta,
+				Data: map[string]string{
+					bucketName:      name,
+					bucketHost:      host,
+					bucketPort:      strconv.Itoa(port),
+					bucketRegion:    "",
+					bucketSubRegion: "",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with provisioner data",
+			args: args{
+				ep: &v1alpha1.Endpoint{
+					BucketHost: host,
+					BucketPort: port,
+					BucketName: name,
+				},
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: objMeta,
+					Spec: v1alpha1.ObjectBucketClaimSpec{
+						BucketName: name,
+					},
+				},
+				data: map[string]string{
+					bucketHost:         "shard-0.test.com",
+					"BUCKET_HOST_1":    "shard-1.test.com",
+					"bucket.shards.io": "2",
+				},
+			},
+			want: &corev1.ConfigMap{
+				ObjectMeta: objMeta,
+				Data: map[string]string{
+					bucketName:         name,
+					bucketHost:         "shard-0.test.com",
+					bucketPort:         strconv.Itoa(port),
+					bucketRegion:       "",
+					bucketSubRegion:    "",
+					"BUCKET_HOST_1":    "shard-1.test.com",
+					"bucket.shards.io": "2",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "with invalid provisioner data key",
+			args: args{
+				ep: &v1alpha1.Endpoint{
+					BucketHost: host,
+					BucketPort: port,
+					BucketName: name,
+				},
+				obc: &v1alpha1.ObjectBucketClaim{
+					ObjectMeta: objMeta,
+					Spec: v1alpha1.ObjectBucketClaimSpec{
+						BucketName: name,
+					},
+				},
+				data: map[string]string{
+					"bucket/host": host,
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := newBucketConfigMap(tt.args.obc, tt.args.ep, dummyLabels)
+			got, err := newBucketConfigMap(tt.args.obc, tt.args.ep, tt.args.data, dummyLabels, nil)
 			if (err != nil) == !tt.wantErr {
 				t.Errorf("newBucketConfigMap() error = %v, wantErr %v", err, tt.wantErr)
 			} else if !cmp.Equal(tt.want, got) {